@@ -0,0 +1,426 @@
+package analytics
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+const (
+	spikeThreshold = 3.5 // robust z-score above which a reading is a spike
+	stepThreshold  = 3.0 // rolling z-score above which a change in level is a step
+	stepWindow     = 6   // readings compared on each side of a step
+	minStepChange  = 0.5 // relative change in level a step must also reach
+	minBaseline    = 5   // readings a baseline needs before anything is compared to it
+	minStandby     = 12  // readings needed before a load is called always on
+)
+
+var severityRanks = map[string]int{model.SeverityLow: 1, model.SeverityMedium: 2, model.SeverityHigh: 3}
+
+// baseline is the median of a set of readings and the spread robust
+// z-scores are measured in.
+type baseline struct {
+	median float64
+	scale  float64
+}
+
+// detector looks for anomalies in one appliance's readings, in time order.
+type detector struct {
+	appliance  string
+	unit       string
+	readings   []reading
+	timestamps []time.Time // nil when the table has no timestamps
+}
+
+// DetectAnomalies looks for unusual consumption in table, per appliance when
+// it has an appliance column:
+//
+//   - spikes, readings far above the appliance's own median for that hour of
+//     day, or overall when the hour has too few readings, by robust z-score
+//     (median and MAD);
+//   - steps, lasting jumps or drops in level, by comparing the readings after
+//     each point with the rolling mean and deviation of those before it;
+//   - standby loads, appliances whose readings never drop to zero.
+//
+// Timestamps are read in loc; without them readings are taken in row order.
+// Anomalies are returned in the order they happened.
+func DetectAnomalies(table map[string][]string, loc *time.Location) (model.AnomalyReport, error) {
+	columns, err := DetectColumns(table)
+	if err != nil {
+		return model.AnomalyReport{}, err
+	}
+
+	readings, unit, skipped := readEnergy(table, columns)
+	_, timestamps, err := DetectTimestamps(table, loc)
+	if err != nil {
+		timestamps = nil
+	}
+	if timestamps != nil {
+		readings = slices.DeleteFunc(readings, func(r reading) bool {
+			if timestamps[r.row].IsZero() {
+				skipped++
+				return true
+			}
+			return false
+		})
+		slices.SortStableFunc(readings, func(a, b reading) int {
+			return timestamps[a.row].Compare(timestamps[b.row])
+		})
+	}
+
+	report := model.AnomalyReport{
+		Unit:        unit,
+		Readings:    len(readings),
+		SkippedRows: skipped,
+		Anomalies:   []model.Anomaly{},
+	}
+	if len(readings) == 0 {
+		return report, ErrNoReadings
+	}
+
+	appliances := make(map[string][]reading)
+	var names []string
+	for _, r := range readings {
+		name := r.appliance
+		if name == "" {
+			name = "Unknown"
+		}
+		if _, ok := appliances[name]; !ok {
+			names = append(names, name)
+		}
+		appliances[name] = append(appliances[name], r)
+	}
+
+	for _, name := range names {
+		d := detector{appliance: name, unit: unit, readings: appliances[name], timestamps: timestamps}
+		report.Anomalies = append(report.Anomalies, d.spikes()...)
+		report.Anomalies = append(report.Anomalies, d.steps()...)
+		if anomaly, ok := d.standby(); ok {
+			report.Anomalies = append(report.Anomalies, anomaly)
+		}
+	}
+
+	slices.SortStableFunc(report.Anomalies, func(a, b model.Anomaly) int {
+		return cmp.Or(
+			compareTimes(a.Start, b.Start),
+			cmp.Compare(a.Row, b.Row),
+			cmp.Compare(severityRanks[b.Severity], severityRanks[a.Severity]),
+		)
+	})
+	return report, nil
+}
+
+// FilterAnomalies keeps the anomalies of report at or above
+// filter.MinSeverity, of filter.Type, and overlapping From–To, To being
+// exclusive. Anomalies without a time are dropped once a time range is
+// given.
+func FilterAnomalies(report model.AnomalyReport, filter model.AnomalyFilter) model.AnomalyReport {
+	minRank := severityRanks[filter.MinSeverity]
+	report.Anomalies = slices.DeleteFunc(slices.Clone(report.Anomalies), func(a model.Anomaly) bool {
+		switch {
+		case severityRanks[a.Severity] < minRank:
+			return true
+		case filter.Type != "" && a.Type != filter.Type:
+			return true
+		case filter.From.IsZero() && filter.To.IsZero():
+			return false
+		case a.Start == nil:
+			return true
+		case !filter.From.IsZero() && a.End.Before(filter.From):
+			return true
+		case !filter.To.IsZero() && !a.Start.Before(filter.To):
+			return true
+		}
+		return false
+	})
+	return report
+}
+
+// ValidSeverity reports whether severity is one of the model.Severity levels.
+func ValidSeverity(severity string) bool {
+	_, ok := severityRanks[severity]
+	return ok
+}
+
+// spikes flags the readings whose robust z-score against their hour's
+// baseline, or the appliance's overall one, reaches spikeThreshold. Only
+// readings above the baseline count.
+func (d detector) spikes() []model.Anomaly {
+	byHour := make(map[int][]float64)
+	all := make([]float64, 0, len(d.readings))
+	for _, r := range d.readings {
+		all = append(all, r.value)
+		if hour := d.hour(r); hour >= 0 {
+			byHour[hour] = append(byHour[hour], r.value)
+		}
+	}
+	if len(all) < minBaseline {
+		return nil
+	}
+
+	overall := robustBaseline(all)
+	baselines := make(map[int]baseline)
+	for hour, values := range byHour {
+		if len(values) >= minBaseline {
+			baselines[hour] = robustBaseline(values)
+		}
+	}
+
+	var result []model.Anomaly
+	for _, r := range d.readings {
+		base, seasonal := baselines[d.hour(r)]
+		if !seasonal || base.scale == 0 {
+			base, seasonal = overall, false
+		}
+		if base.scale == 0 {
+			continue
+		}
+		score := (r.value - base.median) / base.scale
+		if score < spikeThreshold {
+			continue
+		}
+
+		message := fmt.Sprintf("%s used %s against a usual %s", d.appliance, amount(round(r.value, 3), d.unit), amount(round(base.median, 3), d.unit))
+		if seasonal {
+			message += fmt.Sprintf(" around %02d:00", d.hour(r))
+		}
+		result = append(result, model.Anomaly{
+			Type:      model.AnomalySpike,
+			Severity:  severity(score / spikeThreshold),
+			Appliance: d.appliance,
+			Row:       r.row,
+			Start:     d.time(r),
+			End:       d.time(r),
+			Value:     round(r.value, 3),
+			Baseline:  round(base.median, 3),
+			Score:     round(score, 2),
+			Message:   message,
+		})
+	}
+	return result
+}
+
+// steps flags points where the median of the next stepWindow readings sits
+// at least stepThreshold standard deviations, and minStepChange of its
+// level, away from the mean of the previous stepWindow. Of a run of such
+// points the one with the largest change is kept, so a single step is
+// reported once. Severity grows with how many times the level changed:
+// 1.5 is low, 2.25 medium and 3 or more high.
+func (d detector) steps() []model.Anomaly {
+	type candidate struct {
+		index         int
+		before, after float64
+		score, factor float64
+	}
+
+	var result []model.Anomaly
+	var best *candidate
+	flush := func() {
+		if best == nil {
+			return
+		}
+		r := d.readings[best.index]
+		direction := "rose"
+		if best.after < best.before {
+			direction = "fell"
+		}
+		result = append(result, model.Anomaly{
+			Type:      model.AnomalyStep,
+			Severity:  severity(best.factor / 1.5),
+			Appliance: d.appliance,
+			Row:       r.row,
+			Start:     d.time(r),
+			End:       d.time(d.readings[best.index+stepWindow-1]),
+			Value:     round(best.after, 3),
+			Baseline:  round(best.before, 3),
+			Score:     round(best.score, 2),
+			Message: fmt.Sprintf("%s's use %s from about %s to %s", d.appliance, direction,
+				amount(round(best.before, 3), d.unit), amount(round(best.after, 3), d.unit)),
+		})
+		best = nil
+	}
+
+	for i := stepWindow; i+stepWindow <= len(d.readings); i++ {
+		before := values(d.readings[i-stepWindow : i])
+		after := median(values(d.readings[i : i+stepWindow]))
+		mean, deviation := meanDeviation(before)
+
+		score := rollingScore(after-mean, deviation)
+		change := relativeChange(after, mean)
+		if math.Abs(score) < stepThreshold || math.Abs(change) < minStepChange {
+			flush()
+			continue
+		}
+		if best == nil || math.Abs(after-mean) > math.Abs(best.after-best.before) {
+			// An infinite score can't be stored as JSON, so a step off a
+			// perfectly flat level is scored at the threshold's double
+			if math.IsInf(score, 0) {
+				score = math.Copysign(2*stepThreshold, score)
+			}
+			best = &candidate{index: i, before: mean, after: after, score: score, factor: factor(after, mean)}
+		}
+	}
+	flush()
+	return result
+}
+
+// standby flags an appliance whose readings never drop to zero, rated by the
+// share of its consumption the always-on floor accounts for. With timestamps
+// the readings must cover at least a day.
+func (d detector) standby() (model.Anomaly, bool) {
+	if len(d.readings) < minStandby {
+		return model.Anomaly{}, false
+	}
+	first, last := d.readings[0], d.readings[len(d.readings)-1]
+	if d.timestamps != nil && d.timestamps[last.row].Sub(d.timestamps[first.row]) < 24*time.Hour {
+		return model.Anomaly{}, false
+	}
+
+	floor, total := d.readings[0], 0.0
+	for _, r := range d.readings {
+		total += r.value
+		if r.value < floor.value {
+			floor = r
+		}
+	}
+	if floor.value <= 0 {
+		return model.Anomaly{}, false
+	}
+
+	percent := share(floor.value*float64(len(d.readings)), total)
+	return model.Anomaly{
+		Type:      model.AnomalyStandby,
+		Severity:  severity(percent / 40),
+		Appliance: d.appliance,
+		Row:       floor.row,
+		Start:     d.time(first),
+		End:       d.time(last),
+		Value:     round(floor.value, 3),
+		Message: fmt.Sprintf("%s never used less than %s, %s%% of its consumption", d.appliance,
+			amount(round(floor.value, 3), d.unit), utility.FormatNumber(percent)),
+	}, true
+}
+
+// hour is the hour of day r was recorded at, or -1 when unknown.
+func (d detector) hour(r reading) int {
+	if d.timestamps != nil {
+		return d.timestamps[r.row].Hour()
+	}
+	return r.hour
+}
+
+func (d detector) time(r reading) *time.Time {
+	if d.timestamps == nil {
+		return nil
+	}
+	t := d.timestamps[r.row]
+	return &t
+}
+
+// robustBaseline returns the median of values and the scale robust z-scores
+// are measured in: the MAD scaled to a standard deviation, or the scaled mean
+// absolute deviation when more than half the values equal the median.
+func robustBaseline(values []float64) baseline {
+	m := median(values)
+	deviations := make([]float64, len(values))
+	meanDeviation := 0.0
+	for i, value := range values {
+		deviations[i] = math.Abs(value - m)
+		meanDeviation += deviations[i]
+	}
+	meanDeviation /= float64(len(values))
+
+	if mad := median(deviations); mad > 0 {
+		return baseline{median: m, scale: mad / 0.6745}
+	}
+	return baseline{median: m, scale: meanDeviation * 1.253314}
+}
+
+// severity rates how far past its threshold a finding is, as the ratio of
+// its score to the threshold.
+func severity(ratio float64) string {
+	switch {
+	case ratio >= 2:
+		return model.SeverityHigh
+	case ratio >= 1.5:
+		return model.SeverityMedium
+	}
+	return model.SeverityLow
+}
+
+func rollingScore(difference, deviation float64) float64 {
+	if deviation == 0 {
+		if difference == 0 {
+			return 0
+		}
+		return math.Inf(int(math.Copysign(1, difference)))
+	}
+	return difference / deviation
+}
+
+// factor is how many times larger the larger of two levels is.
+func factor(a, b float64) float64 {
+	low, high := min(a, b), max(a, b)
+	if low <= 0 {
+		return math.Inf(1)
+	}
+	return high / low
+}
+
+func relativeChange(value, level float64) float64 {
+	if level == 0 {
+		if value == 0 {
+			return 0
+		}
+		return math.Inf(int(math.Copysign(1, value)))
+	}
+	return (value - level) / math.Abs(level)
+}
+
+func values(readings []reading) []float64 {
+	result := make([]float64, len(readings))
+	for i, r := range readings {
+		result[i] = r.value
+	}
+	return result
+}
+
+func median(values []float64) float64 {
+	sorted := slices.Sorted(slices.Values(values))
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
+
+func meanDeviation(values []float64) (float64, float64) {
+	mean := 0.0
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
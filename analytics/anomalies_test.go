@@ -0,0 +1,143 @@
+package analytics_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/analytics"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var _ = Describe("DetectAnomalies", func() {
+	wib := time.FixedZone("WIB", 7*60*60)
+
+	// hourly builds a table of one appliance's readings, an hour apart from
+	// midnight on 1 January 2024
+	hourly := func(table map[string][]string, appliance string, readings ...string) {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, wib)
+		for i, reading := range readings {
+			table["Timestamp"] = append(table["Timestamp"], start.Add(time.Duration(i)*time.Hour).Format("2006-01-02 15:04"))
+			table["Appliance"] = append(table["Appliance"], appliance)
+			table["Energy_Consumption (kWh)"] = append(table["Energy_Consumption (kWh)"], reading)
+		}
+	}
+
+	It("should flag a reading far above the appliance's median", func() {
+		table := map[string][]string{
+			"Appliance":                {"TV", "TV", "TV", "TV", "TV", "TV", "TV", "TV"},
+			"Energy_Consumption (kWh)": {"1", "1.1", "0.9", "1", "1.05", "0.95", "1", "10"},
+		}
+
+		report, err := analytics.DetectAnomalies(table, wib)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Unit).To(Equal("kWh"))
+		Expect(report.Anomalies).To(HaveLen(1))
+
+		spike := report.Anomalies[0]
+		Expect(spike.Type).To(Equal(model.AnomalySpike))
+		Expect(spike.Severity).To(Equal(model.SeverityHigh))
+		Expect(spike.Row).To(Equal(7))
+		Expect(spike.Start).To(BeNil())
+		Expect(spike.Value).To(Equal(10.0))
+		Expect(spike.Baseline).To(Equal(1.0))
+		Expect(spike.Message).To(Equal("TV used 10 kWh against a usual 1 kWh"))
+	})
+
+	It("should compare a reading with its own hour of day", func() {
+		table := map[string][]string{}
+		for day, night := range []string{"0.5", "0.6", "0.4", "0.5", "0.6", "0.4", "2"} {
+			afternoon := []string{"5", "5.2", "4.8", "5.1", "4.9", "5", "5.1"}[day]
+			date := fmt.Sprintf("2024-01-%02d", day+1)
+			table["Timestamp"] = append(table["Timestamp"], date+" 02:00", date+" 14:00")
+			table["Appliance"] = append(table["Appliance"], "AC", "AC")
+			table["Energy_Consumption (kWh)"] = append(table["Energy_Consumption (kWh)"], night, afternoon)
+		}
+
+		report, err := analytics.DetectAnomalies(table, wib)
+		Expect(err).NotTo(HaveOccurred())
+
+		spikes := analytics.FilterAnomalies(report, model.AnomalyFilter{Type: model.AnomalySpike}).Anomalies
+		Expect(spikes).To(HaveLen(1))
+		Expect(spikes[0].Row).To(Equal(12))
+		Expect(*spikes[0].Start).To(BeTemporally("==", time.Date(2024, 1, 7, 2, 0, 0, 0, wib)))
+		Expect(spikes[0].Baseline).To(Equal(0.5))
+		Expect(spikes[0].Message).To(HaveSuffix("around 02:00"))
+	})
+
+	It("should report a lasting change in level once", func() {
+		table := map[string][]string{}
+		var readings []string
+		for i := range 24 {
+			level := []float64{1, 3}[i/12] + float64(i%2)/10
+			readings = append(readings, fmt.Sprint(level))
+		}
+		hourly(table, "Heater", readings...)
+
+		report, err := analytics.DetectAnomalies(table, wib)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Anomalies).To(HaveLen(1))
+
+		step := report.Anomalies[0]
+		Expect(step.Type).To(Equal(model.AnomalyStep))
+		Expect(step.Severity).To(Equal(model.SeverityMedium))
+		Expect(step.Row).To(Equal(12))
+		Expect(*step.Start).To(BeTemporally("==", time.Date(2024, 1, 1, 12, 0, 0, 0, wib)))
+		Expect(step.Baseline).To(Equal(1.05))
+		Expect(step.Value).To(Equal(3.05))
+		Expect(step.Message).To(Equal("Heater's use rose from about 1.05 kWh to 3.05 kWh"))
+	})
+
+	Describe("standby loads", func() {
+		var report model.AnomalyReport
+
+		BeforeEach(func() {
+			table := map[string][]string{}
+			var fridge, lamp []string
+			for i := range 48 {
+				fridge = append(fridge, []string{"0.5", "0.6"}[i%2])
+				lamp = append(lamp, []string{"0", "1"}[i%2])
+			}
+			hourly(table, "Fridge", fridge...)
+			hourly(table, "Lamp", lamp...)
+
+			var err error
+			report, err = analytics.DetectAnomalies(table, wib)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should flag an appliance that never drops to zero", func() {
+			Expect(report.Readings).To(Equal(96))
+			Expect(report.Anomalies).To(HaveLen(1))
+
+			standby := report.Anomalies[0]
+			Expect(standby.Type).To(Equal(model.AnomalyStandby))
+			Expect(standby.Appliance).To(Equal("Fridge"))
+			Expect(standby.Severity).To(Equal(model.SeverityHigh))
+			Expect(standby.Value).To(Equal(0.5))
+			Expect(*standby.Start).To(BeTemporally("==", time.Date(2024, 1, 1, 0, 0, 0, 0, wib)))
+			Expect(*standby.End).To(BeTemporally("==", time.Date(2024, 1, 2, 23, 0, 0, 0, wib)))
+		})
+
+		It("should filter by severity, type and time range", func() {
+			Expect(analytics.FilterAnomalies(report, model.AnomalyFilter{MinSeverity: model.SeverityHigh}).Anomalies).To(HaveLen(1))
+			Expect(analytics.FilterAnomalies(report, model.AnomalyFilter{Type: model.AnomalySpike}).Anomalies).To(BeEmpty())
+
+			inRange := model.AnomalyFilter{From: time.Date(2024, 1, 2, 0, 0, 0, 0, wib), To: time.Date(2024, 1, 3, 0, 0, 0, 0, wib)}
+			Expect(analytics.FilterAnomalies(report, inRange).Anomalies).To(HaveLen(1))
+
+			before := model.AnomalyFilter{To: time.Date(2024, 1, 1, 0, 0, 0, 0, wib)}
+			Expect(analytics.FilterAnomalies(report, before).Anomalies).To(BeEmpty())
+
+			later := model.AnomalyFilter{From: time.Date(2024, 1, 3, 0, 0, 0, 0, wib)}
+			Expect(analytics.FilterAnomalies(report, later).Anomalies).To(BeEmpty())
+			Expect(report.Anomalies).To(HaveLen(1))
+		})
+	})
+
+	It("should fail without energy readings", func() {
+		_, err := analytics.DetectAnomalies(map[string][]string{"Appliance": {"TV"}}, wib)
+		Expect(err).To(MatchError(analytics.ErrNoEnergyColumn))
+	})
+})
@@ -0,0 +1,226 @@
+package analytics
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+// daysPerMonth is the month length monthly estimates are scaled to.
+const daysPerMonth = 30
+
+var ErrNotKWh = errors.New("energy column is not in kWh")
+
+// EstimateCost prices the kWh readings of table under tariff. Readings are
+// grouped into calendar months in loc when the table has timestamps, each
+// month paying the fixed charge and, for tiered tariffs, its own tiers;
+// without timestamps the whole table counts as one month. Time-of-use
+// windows need a time of day, and readings without one pay tariff.Rate.
+func EstimateCost(table map[string][]string, tariff model.Tariff, loc *time.Location) (model.CostEstimate, error) {
+	columns, err := DetectColumns(table)
+	if err != nil {
+		return model.CostEstimate{}, err
+	}
+
+	readings, unit, skipped := readEnergy(table, columns)
+	if unit != "kWh" && unit != "" {
+		return model.CostEstimate{}, ErrNotKWh
+	}
+
+	timeColumns, timestamps, err := DetectTimestamps(table, loc)
+	if err != nil && !errors.Is(err, ErrNoTimestampColumn) {
+		return model.CostEstimate{}, err
+	}
+	if timestamps != nil {
+		readings = slices.DeleteFunc(readings, func(r reading) bool {
+			if timestamps[r.row].IsZero() {
+				skipped++
+				return true
+			}
+			return false
+		})
+	}
+
+	estimate := model.CostEstimate{
+		Tariff:      tariff.Name,
+		Currency:    tariff.Currency,
+		SkippedRows: skipped,
+	}
+	if len(readings) == 0 {
+		return estimate, ErrNoReadings
+	}
+
+	// Per month, then per appliance within the month
+	type usage struct {
+		kWh  float64
+		cost float64 // before tiers, which are applied to the month as a whole
+	}
+	months := make(map[string]*usage)
+	appliances := make(map[string]map[string]*usage)
+	var monthKeys []string
+	var first, last time.Time
+	for _, r := range readings {
+		month, minute := "", -1
+		if timestamps != nil {
+			t := timestamps[r.row]
+			month = t.Format("2006-01")
+			if timeColumns.Timestamp != "" || timeColumns.Time != "" {
+				minute = t.Hour()*60 + t.Minute()
+			}
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if t.After(last) {
+				last = t
+			}
+		}
+		if minute < 0 && columns.Time != "" {
+			if hour := hourOfDay(cell(table, columns.Time, r.row)); hour >= 0 {
+				minute = hour * 60
+			}
+		}
+
+		if months[month] == nil {
+			months[month] = &usage{}
+			appliances[month] = make(map[string]*usage)
+			monthKeys = append(monthKeys, month)
+		}
+		cost := r.value * rateAt(tariff, minute)
+		months[month].kWh += r.value
+		months[month].cost += cost
+
+		name := r.appliance
+		if name == "" {
+			name = "Unknown"
+		}
+		if appliances[month][name] == nil {
+			appliances[month][name] = &usage{}
+		}
+		appliances[month][name].kWh += r.value
+		appliances[month][name].cost += cost
+	}
+	slices.Sort(monthKeys)
+
+	applianceTotals := make(map[string]*usage)
+	for _, key := range monthKeys {
+		month := months[key]
+		energyCost := month.cost
+		if len(tariff.Tiers) > 0 {
+			energyCost = tieredCost(tariff.Tiers, month.kWh)
+		}
+
+		estimate.KWh += month.kWh
+		estimate.EnergyCost += energyCost
+		estimate.FixedCost += tariff.FixedMonthlyCharge
+		if key != "" {
+			estimate.Months = append(estimate.Months, model.MonthlyCost{
+				Month:      key,
+				KWh:        round(month.kWh, 3),
+				EnergyCost: round(energyCost, 2),
+				FixedCost:  round(tariff.FixedMonthlyCharge, 2),
+				Total:      round(energyCost+tariff.FixedMonthlyCharge, 2),
+			})
+		}
+
+		for name, appliance := range appliances[key] {
+			if applianceTotals[name] == nil {
+				applianceTotals[name] = &usage{}
+			}
+			applianceTotals[name].kWh += appliance.kWh
+			if len(tariff.Tiers) > 0 && month.kWh > 0 {
+				// Each appliance pays the month's average tiered rate
+				applianceTotals[name].cost += energyCost * appliance.kWh / month.kWh
+			} else {
+				applianceTotals[name].cost += appliance.cost
+			}
+		}
+	}
+	estimate.Total = estimate.EnergyCost + estimate.FixedCost
+
+	// Scale the usage seen to a whole month
+	projected := -1.0
+	if timestamps != nil {
+		firstDay := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc)
+		lastDay := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, loc)
+		estimate.Days = int(lastDay.Sub(firstDay).Hours()/24+0.5) + 1
+
+		scale := float64(daysPerMonth) / float64(estimate.Days)
+		projected = estimate.EnergyCost * scale
+		if len(tariff.Tiers) > 0 {
+			projected = tieredCost(tariff.Tiers, estimate.KWh*scale)
+		}
+		monthly := round(projected+tariff.FixedMonthlyCharge, 2)
+		estimate.MonthlyEstimate = &monthly
+	}
+
+	if columns.Appliance != "" {
+		for name, appliance := range applianceTotals {
+			cost := model.ApplianceCost{
+				Name:  name,
+				KWh:   round(appliance.kWh, 3),
+				Cost:  round(appliance.cost, 2),
+				Share: share(appliance.cost, estimate.EnergyCost),
+			}
+			if projected >= 0 && estimate.EnergyCost > 0 {
+				monthly := round(projected*appliance.cost/estimate.EnergyCost, 2)
+				cost.MonthlyEstimate = &monthly
+			}
+			estimate.Appliances = append(estimate.Appliances, cost)
+		}
+		slices.SortFunc(estimate.Appliances, func(a, b model.ApplianceCost) int {
+			return cmp.Or(cmp.Compare(b.Cost, a.Cost), cmp.Compare(a.Name, b.Name))
+		})
+	}
+
+	estimate.KWh = round(estimate.KWh, 3)
+	estimate.EnergyCost = round(estimate.EnergyCost, 2)
+	estimate.FixedCost = round(estimate.FixedCost, 2)
+	estimate.Total = round(estimate.Total, 2)
+	return estimate, nil
+}
+
+// rateAt returns the price per kWh at minute of the day, which is -1 when
+// unknown. Tiered tariffs are priced per month instead; see tieredCost.
+func rateAt(tariff model.Tariff, minute int) float64 {
+	if minute < 0 {
+		return tariff.Rate
+	}
+	for _, window := range tariff.Windows {
+		start, startOK := clockMinute(window.Start)
+		end, endOK := clockMinute(window.End)
+		if !startOK || !endOK {
+			continue
+		}
+		if (start < end && minute >= start && minute < end) || (start > end && (minute >= start || minute < end)) {
+			return window.Rate
+		}
+	}
+	return tariff.Rate
+}
+
+// tieredCost prices a month's kWh block by block.
+func tieredCost(tiers []model.TariffTier, kWh float64) float64 {
+	cost, from := 0.0, 0.0
+	for _, tier := range tiers {
+		if tier.UpTo == nil {
+			return cost + (kWh-from)*tier.Rate
+		}
+		if kWh <= *tier.UpTo {
+			return cost + (kWh-from)*tier.Rate
+		}
+		cost += (*tier.UpTo - from) * tier.Rate
+		from = *tier.UpTo
+	}
+	return cost
+}
+
+func clockMinute(value string) (int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -0,0 +1,96 @@
+package analytics_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/analytics"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var _ = Describe("EstimateCost", func() {
+	wib := time.FixedZone("WIB", 7*60*60)
+	table := map[string][]string{
+		"Date":                     {"2024-01-01", "2024-01-01", "2024-01-10", "2024-02-09"},
+		"Time":                     {"07:00", "19:00", "20:00", "10:00"},
+		"Appliance":                {"Fridge", "AC", "AC", "Fridge"},
+		"Energy_Consumption (kWh)": {"2", "3", "5", "10"},
+	}
+	amount := func(value float64) *float64 { return &value }
+
+	It("should price a flat tariff per month with its fixed charge", func() {
+		tariff := model.Tariff{Name: "Flat", Currency: "IDR", Rate: 1000, FixedMonthlyCharge: 5000}
+
+		estimate, err := analytics.EstimateCost(table, tariff, wib)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(estimate.KWh).To(Equal(20.0))
+		Expect(estimate.EnergyCost).To(Equal(20000.0))
+		Expect(estimate.FixedCost).To(Equal(10000.0))
+		Expect(estimate.Total).To(Equal(30000.0))
+		Expect(estimate.Months).To(Equal([]model.MonthlyCost{
+			{Month: "2024-01", KWh: 10, EnergyCost: 10000, FixedCost: 5000, Total: 15000},
+			{Month: "2024-02", KWh: 10, EnergyCost: 10000, FixedCost: 5000, Total: 15000},
+		}))
+		Expect(estimate.Days).To(Equal(40))
+		Expect(estimate.MonthlyEstimate).To(Equal(amount(20000)))
+		Expect(estimate.Appliances).To(Equal([]model.ApplianceCost{
+			{Name: "Fridge", KWh: 12, Cost: 12000, Share: 60, MonthlyEstimate: amount(9000)},
+			{Name: "AC", KWh: 8, Cost: 8000, Share: 40, MonthlyEstimate: amount(6000)},
+		}))
+	})
+
+	It("should price readings by their time-of-use window", func() {
+		tariff := model.Tariff{Currency: "IDR", Rate: 1000, Windows: []model.TariffWindow{
+			{Start: "17:00", End: "22:00", Rate: 2000},
+			{Start: "22:00", End: "06:00", Rate: 500},
+		}}
+
+		estimate, err := analytics.EstimateCost(table, tariff, wib)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(estimate.EnergyCost).To(Equal(28000.0))
+		Expect(estimate.Appliances[0].Name).To(Equal("AC"))
+		Expect(estimate.Appliances[0].Cost).To(Equal(16000.0))
+	})
+
+	It("should apply tiers to each month's consumption", func() {
+		tariff := model.Tariff{Currency: "IDR", Tiers: []model.TariffTier{{UpTo: amount(5), Rate: 100}, {Rate: 200}}}
+
+		estimate, err := analytics.EstimateCost(table, tariff, wib)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(estimate.EnergyCost).To(Equal(3000.0))
+		Expect(estimate.Months[0].EnergyCost).To(Equal(1500.0))
+		Expect(estimate.Appliances[0]).To(Equal(model.ApplianceCost{Name: "Fridge", KWh: 12, Cost: 1800, Share: 60, MonthlyEstimate: amount(1500)}))
+		// 15 kWh in a 30-day month: 5 at 100 and 10 at 200
+		Expect(estimate.MonthlyEstimate).To(Equal(amount(2500)))
+	})
+
+	It("should treat a table without timestamps as one month", func() {
+		tariff := model.Tariff{Currency: "USD", Rate: 0.2, FixedMonthlyCharge: 10}
+
+		estimate, err := analytics.EstimateCost(map[string][]string{
+			"Appliance":                {"TV", "Oven"},
+			"Energy_Consumption (kWh)": {"5", "15"},
+		}, tariff, wib)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(estimate.Total).To(Equal(14.0))
+		Expect(estimate.Months).To(BeEmpty())
+		Expect(estimate.MonthlyEstimate).To(BeNil())
+		Expect(estimate.Appliances[0].MonthlyEstimate).To(BeNil())
+	})
+
+	It("should refuse to price power readings", func() {
+		_, err := analytics.EstimateCost(map[string][]string{"Power (kW)": {"1", "2"}}, model.Tariff{Rate: 1}, wib)
+		Expect(err).To(MatchError(analytics.ErrNotKWh))
+	})
+
+	It("should describe the cost for the chat model", func() {
+		tariff := model.Tariff{Name: "Flat", Currency: "IDR", Rate: 1000, FixedMonthlyCharge: 5000}
+
+		estimate, err := analytics.EstimateCost(table, tariff, wib)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(analytics.DescribeCost(estimate, 1)).To(Equal("Electricity cost under the Flat tariff: 20 kWh cost 30000 IDR in total " +
+			"(20000 IDR for energy, 10000 IDR in fixed charges) over 40 days, about 20000 IDR per month.\n" +
+			"Energy cost by appliance: Fridge 12000 IDR (60%, about 9000 IDR per month)."))
+	})
+})
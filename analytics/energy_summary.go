@@ -0,0 +1,303 @@
+// Package analytics computes energy consumption statistics directly from a
+// parsed CSV table, without going through a table QA model.
+package analytics
+
+import (
+	"cmp"
+	"errors"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+var (
+	ErrNoEnergyColumn = errors.New("no energy consumption column found")
+	ErrNoReadings     = errors.New("no energy readings found")
+)
+
+var (
+	energyColumnPattern    = regexp.MustCompile(`energy|consumption|kwh|usage|power|electricity|watt`)
+	applianceColumnPattern = regexp.MustCompile(`appliance|device|equipment`)
+	roomColumnPattern      = regexp.MustCompile(`room|location|area|zone`)
+	timeColumnPattern      = regexp.MustCompile(`time|hour`)
+)
+
+// reading is one row with a usable energy value.
+type reading struct {
+	row       int
+	value     float64
+	appliance string
+	room      string
+	hour      int // -1 when unknown
+}
+
+// DetectColumns finds the energy, appliance, room and time columns of table
+// from their names. The energy column must hold numbers.
+func DetectColumns(table map[string][]string) (model.EnergyColumns, error) {
+	var columns model.EnergyColumns
+	for _, name := range slices.Sorted(maps.Keys(table)) {
+		lower := strings.ToLower(name)
+		switch {
+		case columns.Energy == "" && energyColumnPattern.MatchString(lower) && isNumeric(table[name]):
+			columns.Energy = name
+		case columns.Appliance == "" && applianceColumnPattern.MatchString(lower):
+			columns.Appliance = name
+		case columns.Room == "" && roomColumnPattern.MatchString(lower):
+			columns.Room = name
+		case columns.Time == "" && timeColumnPattern.MatchString(lower):
+			columns.Time = name
+		}
+	}
+
+	if columns.Energy == "" {
+		return columns, ErrNoEnergyColumn
+	}
+	return columns, nil
+}
+
+// Summarize totals the energy column of table per appliance, room and hour
+// of day. topN limits the appliance, room and peak hour rankings; 0 keeps
+// them whole. Rows whose energy cell can't be read, or is in another unit
+// than most rows, are counted in SkippedRows.
+func Summarize(table map[string][]string, topN int) (model.EnergySummary, error) {
+	columns, err := DetectColumns(table)
+	if err != nil {
+		return model.EnergySummary{}, err
+	}
+
+	readings, unit, skipped := readEnergy(table, columns)
+	summary := model.EnergySummary{
+		Columns:     columns,
+		Unit:        unit,
+		Readings:    len(readings),
+		SkippedRows: skipped,
+	}
+	if len(readings) == 0 {
+		return summary, ErrNoReadings
+	}
+
+	total := 0.0
+	for _, r := range readings {
+		total += r.value
+	}
+	summary.Total = round(total, 3)
+	summary.Average = round(total/float64(len(readings)), 3)
+
+	if columns.Appliance != "" {
+		summary.Appliances = limit(groupBy(readings, total, func(r reading) string { return r.appliance }), topN)
+	}
+	if columns.Room != "" {
+		summary.Rooms = limit(groupBy(readings, total, func(r reading) string { return r.room }), topN)
+	}
+	if columns.Time != "" {
+		summary.Hours = hourly(readings, total)
+		summary.PeakHours = slices.Clone(summary.Hours)
+		slices.SortStableFunc(summary.PeakHours, func(a, b model.HourlyUsage) int {
+			return cmp.Compare(b.Total, a.Total)
+		})
+		summary.PeakHours = limit(summary.PeakHours, topN)
+	}
+
+	return summary, nil
+}
+
+// readEnergy parses the energy column and keeps the rows in its most common
+// unit, returning them with that unit and the number of rows left out.
+func readEnergy(table map[string][]string, columns model.EnergyColumns) ([]reading, string, int) {
+	values := table[columns.Energy]
+	decimalMark := utility.DetectDecimalMark(values)
+	headerUnit := utility.ColumnUnit(columns.Energy)
+
+	numbers := make([]*utility.Number, len(values))
+	unitCounts := make(map[string]int)
+	for row, value := range values {
+		number, err := utility.ParseNumber(value, decimalMark)
+		if err != nil {
+			continue
+		}
+		if number.Unit == "" && headerUnit != "" {
+			number = utility.ConvertUnit(number.Value, headerUnit)
+		}
+		numbers[row] = &number
+		unitCounts[number.Unit]++
+	}
+
+	// The most common unit wins; ties go to the one seen first
+	unit, best := "", 0
+	for _, number := range numbers {
+		if number != nil && unitCounts[number.Unit] > best {
+			unit, best = number.Unit, unitCounts[number.Unit]
+		}
+	}
+
+	var readings []reading
+	for row, number := range numbers {
+		if number == nil || number.Unit != unit {
+			continue
+		}
+		readings = append(readings, reading{
+			row:       row,
+			value:     number.Value,
+			appliance: cell(table, columns.Appliance, row),
+			room:      cell(table, columns.Room, row),
+			hour:      hourOfDay(cell(table, columns.Time, row)),
+		})
+	}
+	return readings, unit, len(values) - len(readings)
+}
+
+// groupBy totals readings per key, ranked by total, highest first. Readings
+// with an empty key are grouped under "Unknown".
+func groupBy(readings []reading, total float64, key func(reading) string) []model.EnergyGroup {
+	type group struct {
+		total    float64
+		readings int
+		hours    map[int]float64
+	}
+
+	groups := make(map[string]*group)
+	var names []string
+	for _, r := range readings {
+		name := key(r)
+		if name == "" {
+			name = "Unknown"
+		}
+		g, ok := groups[name]
+		if !ok {
+			g = &group{hours: make(map[int]float64)}
+			groups[name] = g
+			names = append(names, name)
+		}
+		g.total += r.value
+		g.readings++
+		if r.hour >= 0 {
+			g.hours[r.hour] += r.value
+		}
+	}
+
+	result := make([]model.EnergyGroup, 0, len(names))
+	for _, name := range names {
+		g := groups[name]
+		result = append(result, model.EnergyGroup{
+			Name:     name,
+			Total:    round(g.total, 3),
+			Average:  round(g.total/float64(g.readings), 3),
+			Readings: g.readings,
+			Share:    share(g.total, total),
+			PeakHour: peakHour(g.hours),
+		})
+	}
+
+	slices.SortStableFunc(result, func(a, b model.EnergyGroup) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Name, b.Name))
+	})
+	for i := range result {
+		result[i].Rank = i + 1
+	}
+	return result
+}
+
+// hourly totals readings per hour of day, for the hours that have any.
+func hourly(readings []reading, total float64) []model.HourlyUsage {
+	var totals [24]float64
+	var counts [24]int
+	for _, r := range readings {
+		if r.hour >= 0 {
+			totals[r.hour] += r.value
+			counts[r.hour]++
+		}
+	}
+
+	var hours []model.HourlyUsage
+	for hour := range 24 {
+		if counts[hour] == 0 {
+			continue
+		}
+		hours = append(hours, model.HourlyUsage{
+			Hour:     hour,
+			Total:    round(totals[hour], 3),
+			Average:  round(totals[hour]/float64(counts[hour]), 3),
+			Readings: counts[hour],
+			Share:    share(totals[hour], total),
+		})
+	}
+	return hours
+}
+
+func peakHour(hours map[int]float64) *int {
+	peak, found := 0, false
+	for hour, total := range hours {
+		if !found || total > hours[peak] || (total == hours[peak] && hour < peak) {
+			peak, found = hour, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return &peak
+}
+
+// hourOfDay reads the hour from a time or timestamp cell, or a bare hour
+// such as "13", returning -1 when it can't.
+func hourOfDay(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return -1
+	}
+
+	for _, layout := range slices.Concat(datetimeLayouts, clockLayouts) {
+		if t, err := time.Parse(layout, strings.ToUpper(value)); err == nil {
+			return t.Hour()
+		}
+	}
+
+	if hour, err := strconv.Atoi(value); err == nil && hour >= 0 && hour < 24 {
+		return hour
+	}
+	return -1
+}
+
+func isNumeric(values []string) bool {
+	decimalMark := utility.DetectDecimalMark(values)
+	found := 0
+	for _, value := range values {
+		if _, err := utility.ParseNumber(value, decimalMark); err == nil {
+			found++
+		}
+	}
+	// A few bad cells shouldn't hide the column; mostly text should
+	return found > 0 && found*2 >= len(values)
+}
+
+func cell(table map[string][]string, column string, row int) string {
+	if column == "" || row >= len(table[column]) {
+		return ""
+	}
+	return strings.TrimSpace(table[column][row])
+}
+
+func limit[T any](items []T, n int) []T {
+	if n > 0 && len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+func share(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round(part/total*100, 2)
+}
+
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,106 @@
+package analytics_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/analytics"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var _ = Describe("Summarize", func() {
+	table := map[string][]string{
+		"Date":                     {"2024-01-01", "2024-01-01", "2024-01-01", "2024-01-01", "2024-01-02"},
+		"Time":                     {"07:00", "18:30", "18:00", "7:15 PM", "18:45"},
+		"Appliance":                {"Fridge", "Heater", "TV", "Heater", "Fridge"},
+		"Energy_Consumption (kWh)": {"1.5", "4.0", "0.5", "3.0", "1.0"},
+		"Room":                     {"Kitchen", "Living Room", "Living Room", "Bedroom", "Kitchen"},
+	}
+	hour := func(h int) *int { return &h }
+
+	It("should detect the columns by name", func() {
+		columns, err := analytics.DetectColumns(table)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(columns).To(Equal(model.EnergyColumns{
+			Energy:    "Energy_Consumption (kWh)",
+			Appliance: "Appliance",
+			Room:      "Room",
+			Time:      "Time",
+		}))
+	})
+
+	It("should total, average and rank the consumption", func() {
+		summary, err := analytics.Summarize(table, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Unit).To(Equal("kWh"))
+		Expect(summary.Readings).To(Equal(5))
+		Expect(summary.Total).To(Equal(10.0))
+		Expect(summary.Average).To(Equal(2.0))
+
+		Expect(summary.Appliances).To(Equal([]model.EnergyGroup{
+			{Rank: 1, Name: "Heater", Total: 7, Average: 3.5, Readings: 2, Share: 70, PeakHour: hour(18)},
+			{Rank: 2, Name: "Fridge", Total: 2.5, Average: 1.25, Readings: 2, Share: 25, PeakHour: hour(7)},
+			{Rank: 3, Name: "TV", Total: 0.5, Average: 0.5, Readings: 1, Share: 5, PeakHour: hour(18)},
+		}))
+		Expect(summary.Rooms).To(HaveLen(3))
+		Expect(summary.Rooms[0].Name).To(Equal("Living Room"))
+		Expect(summary.Rooms[0].Total).To(Equal(4.5))
+	})
+
+	It("should profile consumption by hour of day", func() {
+		summary, err := analytics.Summarize(table, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Hours).To(Equal([]model.HourlyUsage{
+			{Hour: 7, Total: 1.5, Average: 1.5, Readings: 1, Share: 15},
+			{Hour: 18, Total: 5.5, Average: 1.833, Readings: 3, Share: 55},
+			{Hour: 19, Total: 3, Average: 3, Readings: 1, Share: 30},
+		}))
+		Expect(summary.PeakHours[0].Hour).To(Equal(18))
+	})
+
+	It("should limit the rankings to the top entries", func() {
+		summary, err := analytics.Summarize(table, 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Appliances).To(HaveLen(1))
+		Expect(summary.Rooms).To(HaveLen(1))
+		Expect(summary.PeakHours).To(Equal([]model.HourlyUsage{{Hour: 18, Total: 5.5, Average: 1.833, Readings: 3, Share: 55}}))
+		Expect(summary.Hours).To(HaveLen(3))
+	})
+
+	It("should normalize units and skip unusable rows", func() {
+		summary, err := analytics.Summarize(map[string][]string{
+			"Device": {"Oven", "Oven", "Lamp", "Lamp"},
+			"Usage":  {"1,5 kWh", "500 Wh", "n/a", "2 kW"},
+		}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Unit).To(Equal("kWh"))
+		Expect(summary.Total).To(Equal(2.0))
+		Expect(summary.SkippedRows).To(Equal(2))
+		Expect(summary.Appliances).To(Equal([]model.EnergyGroup{{Rank: 1, Name: "Oven", Total: 2, Average: 1, Readings: 2, Share: 100}}))
+		Expect(summary.Rooms).To(BeNil())
+		Expect(summary.Hours).To(BeNil())
+	})
+
+	It("should read hours from timestamps", func() {
+		summary, err := analytics.Summarize(map[string][]string{
+			"Timestamp":   {"2024-01-01 13:05:00", "2024-01-01T23:00:00Z", "21"},
+			"Power (kWh)": {"1", "2", "3"},
+		}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Hours).To(HaveLen(3))
+		Expect(summary.PeakHours[0].Hour).To(Equal(21))
+	})
+
+	It("should fail without an energy column", func() {
+		_, err := analytics.Summarize(map[string][]string{"Appliance": {"TV"}, "Energy": {"high"}}, 0)
+		Expect(err).To(MatchError(analytics.ErrNoEnergyColumn))
+	})
+
+	It("should describe the summary for the chat model", func() {
+		summary, err := analytics.Summarize(table, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(analytics.Describe(summary)).To(Equal("Energy data summary: 5 readings totalling 10 kWh, 2 kWh per reading on average.\n" +
+			"Appliances by consumption: 1. Heater 7 kWh (70%), 2. Fridge 2.5 kWh (25%).\n" +
+			"Rooms by consumption: 1. Living Room 4.5 kWh (45%), 2. Bedroom 3 kWh (30%).\n" +
+			"Peak hours: 18:00 (5.5 kWh, 55%), 19:00 (3 kWh, 30%)."))
+	})
+})
@@ -0,0 +1,78 @@
+package analytics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+// Describe writes summary as a few plain-text lines, to give the chat
+// model exact figures to ground its answers about the dataset in.
+func Describe(summary model.EnergySummary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Energy data summary: %d readings totalling %s, %s per reading on average.",
+		summary.Readings, amount(summary.Total, summary.Unit), amount(summary.Average, summary.Unit))
+
+	if len(summary.Appliances) > 0 {
+		b.WriteString("\nAppliances by consumption: " + describeGroups(summary.Appliances, summary.Unit) + ".")
+	}
+	if len(summary.Rooms) > 0 {
+		b.WriteString("\nRooms by consumption: " + describeGroups(summary.Rooms, summary.Unit) + ".")
+	}
+	if len(summary.PeakHours) > 0 {
+		hours := make([]string, len(summary.PeakHours))
+		for i, hour := range summary.PeakHours {
+			hours[i] = fmt.Sprintf("%02d:00 (%s, %s%%)", hour.Hour, amount(hour.Total, summary.Unit), utility.FormatNumber(hour.Share))
+		}
+		b.WriteString("\nPeak hours: " + strings.Join(hours, ", ") + ".")
+	}
+	return b.String()
+}
+
+func describeGroups(groups []model.EnergyGroup, unit string) string {
+	parts := make([]string, len(groups))
+	for i, group := range groups {
+		parts[i] = fmt.Sprintf("%d. %s %s (%s%%)", group.Rank, group.Name, amount(group.Total, unit), utility.FormatNumber(group.Share))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func money(value float64, currency string) string {
+	return utility.FormatNumber(value) + " " + currency
+}
+
+func amount(value float64, unit string) string {
+	if unit == "" {
+		return utility.FormatNumber(value)
+	}
+	return utility.FormatNumber(value) + " " + unit
+}
+
+// DescribeCost writes estimate as plain text for the chat model, listing
+// at most topN appliances.
+func DescribeCost(estimate model.CostEstimate, topN int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Electricity cost under the %s tariff: %s kWh cost %s in total (%s for energy, %s in fixed charges)",
+		estimate.Tariff, utility.FormatNumber(estimate.KWh), money(estimate.Total, estimate.Currency),
+		money(estimate.EnergyCost, estimate.Currency), money(estimate.FixedCost, estimate.Currency))
+	if estimate.MonthlyEstimate != nil {
+		fmt.Fprintf(&b, " over %d days, about %s per month", estimate.Days, money(*estimate.MonthlyEstimate, estimate.Currency))
+	}
+	b.WriteString(".")
+
+	if len(estimate.Appliances) > 0 {
+		appliances := limit(estimate.Appliances, topN)
+		parts := make([]string, len(appliances))
+		for i, appliance := range appliances {
+			parts[i] = fmt.Sprintf("%s %s (%s%%", appliance.Name, money(appliance.Cost, estimate.Currency), utility.FormatNumber(appliance.Share))
+			if appliance.MonthlyEstimate != nil {
+				parts[i] += ", about " + money(*appliance.MonthlyEstimate, estimate.Currency) + " per month"
+			}
+			parts[i] += ")"
+		}
+		b.WriteString("\nEnergy cost by appliance: " + strings.Join(parts, ", ") + ".")
+	}
+	return b.String()
+}
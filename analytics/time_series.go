@@ -0,0 +1,157 @@
+package analytics
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+// MaxBuckets bounds a resampled series so a long dataset can't be expanded
+// into millions of hourly buckets.
+const MaxBuckets = 5000
+
+var (
+	ErrUnknownInterval = errors.New("unknown interval")
+	ErrTooManyBuckets  = errors.New("too many buckets for the interval")
+)
+
+// Resample sums the energy readings of table into buckets of interval
+// (model.IntervalHour, IntervalDay or IntervalMonth) in loc, per appliance
+// when the table has an appliance column. Runs of empty buckets are
+// reported as gaps.
+func Resample(table map[string][]string, interval string, loc *time.Location) (model.TimeSeries, error) {
+	if !slices.Contains([]string{model.IntervalHour, model.IntervalDay, model.IntervalMonth}, interval) {
+		return model.TimeSeries{}, ErrUnknownInterval
+	}
+
+	energyColumns, err := DetectColumns(table)
+	if err != nil {
+		return model.TimeSeries{}, err
+	}
+	columns, timestamps, err := DetectTimestamps(table, loc)
+	if err != nil {
+		return model.TimeSeries{}, err
+	}
+	columns.Energy, columns.Appliance = energyColumns.Energy, energyColumns.Appliance
+
+	readings, unit, skipped := readEnergy(table, energyColumns)
+	readings = slices.DeleteFunc(readings, func(r reading) bool {
+		if timestamps[r.row].IsZero() {
+			skipped++
+			return true
+		}
+		return false
+	})
+
+	series := model.TimeSeries{
+		Columns:     columns,
+		Interval:    interval,
+		TimeZone:    loc.String(),
+		Unit:        unit,
+		SkippedRows: skipped,
+	}
+	if len(readings) == 0 {
+		return series, ErrNoReadings
+	}
+
+	first, last := timestamps[readings[0].row], timestamps[readings[0].row]
+	for _, r := range readings {
+		if timestamps[r.row].Before(first) {
+			first = timestamps[r.row]
+		}
+		if timestamps[r.row].After(last) {
+			last = timestamps[r.row]
+		}
+	}
+
+	index := make(map[int64]int) // bucket start in Unix seconds
+	for bucket := truncate(first, interval); !bucket.After(last); bucket = next(bucket, interval) {
+		if len(series.Buckets) == MaxBuckets {
+			return model.TimeSeries{}, ErrTooManyBuckets
+		}
+		index[bucket.Unix()] = len(series.Buckets)
+		series.Buckets = append(series.Buckets, bucket)
+	}
+
+	series.Total = make([]float64, len(series.Buckets))
+	counts := make([]int, len(series.Buckets))
+	lines := make(map[string]*model.TimeSeriesLine)
+	for _, r := range readings {
+		i := index[truncate(timestamps[r.row], interval).Unix()]
+		series.Total[i] += r.value
+		counts[i]++
+
+		if columns.Appliance == "" {
+			continue
+		}
+		name := r.appliance
+		if name == "" {
+			name = "Unknown"
+		}
+		line, ok := lines[name]
+		if !ok {
+			line = &model.TimeSeriesLine{Name: name, Values: make([]float64, len(series.Buckets))}
+			lines[name] = line
+		}
+		line.Values[i] += r.value
+		line.Total += r.value
+	}
+
+	for i := range series.Total {
+		series.Total[i] = round(series.Total[i], 3)
+	}
+	for _, line := range lines {
+		for i := range line.Values {
+			line.Values[i] = round(line.Values[i], 3)
+		}
+		line.Total = round(line.Total, 3)
+		series.Series = append(series.Series, *line)
+	}
+	slices.SortFunc(series.Series, func(a, b model.TimeSeriesLine) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Name, b.Name))
+	})
+
+	series.Gaps = gaps(series.Buckets, counts)
+	return series, nil
+}
+
+// gaps returns the runs of buckets without readings.
+func gaps(buckets []time.Time, counts []int) []model.TimeGap {
+	var result []model.TimeGap
+	start := -1
+	for i, count := range counts {
+		switch {
+		case count == 0 && start < 0:
+			start = i
+		case count > 0 && start >= 0:
+			result = append(result, model.TimeGap{Start: buckets[start], End: buckets[i], Buckets: i - start})
+			start = -1
+		}
+	}
+	return result
+}
+
+// truncate returns the start of the bucket t falls in, in t's location.
+func truncate(t time.Time, interval string) time.Time {
+	switch interval {
+	case model.IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case model.IntervalDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// next returns the start of the bucket after bucket.
+func next(bucket time.Time, interval string) time.Time {
+	switch interval {
+	case model.IntervalHour:
+		return truncate(bucket.Add(time.Hour), interval)
+	case model.IntervalDay:
+		return bucket.AddDate(0, 0, 1)
+	}
+	return bucket.AddDate(0, 1, 0)
+}
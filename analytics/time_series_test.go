@@ -0,0 +1,106 @@
+package analytics_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/analytics"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var _ = Describe("Time series", func() {
+	wib := time.FixedZone("WIB", 7*60*60)
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, wib)
+	}
+
+	DescribeTable("DetectTimestamps",
+		func(table map[string][]string, columns model.TimeSeriesColumns, first time.Time) {
+			detected, timestamps, err := analytics.DetectTimestamps(table, wib)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detected).To(Equal(columns))
+			Expect(timestamps[0].Equal(first)).To(BeTrue(), "got %v", timestamps[0])
+			Expect(timestamps[0].Location()).To(Equal(wib))
+		},
+		Entry("ISO timestamps", map[string][]string{"Timestamp": {"2024-01-02 13:00:00", "2024-01-02 14:00:00"}},
+			model.TimeSeriesColumns{Timestamp: "Timestamp"}, at(time.January, 2, 13)),
+		Entry("timestamps with an offset", map[string][]string{"When": {"2024-01-02T06:00:00Z", "2024-01-02T07:00:00Z"}},
+			model.TimeSeriesColumns{Timestamp: "When"}, at(time.January, 2, 13)),
+		Entry("day-first dates with a separate time", map[string][]string{"Date": {"2/1/2024", "13/1/2024"}, "Time": {"1:00 PM", "09:30"}},
+			model.TimeSeriesColumns{Date: "Date", Time: "Time"}, at(time.January, 2, 13)),
+		Entry("month-first dates", map[string][]string{"Date": {"1/2/2024", "1/13/2024", "1/14/2024"}},
+			model.TimeSeriesColumns{Date: "Date"}, at(time.January, 2, 0)),
+	)
+
+	It("should fail without a timestamp column", func() {
+		_, _, err := analytics.DetectTimestamps(map[string][]string{"Appliance": {"TV"}, "Energy": {"1"}}, wib)
+		Expect(err).To(MatchError(analytics.ErrNoTimestampColumn))
+	})
+
+	Describe("Resample", func() {
+		table := map[string][]string{
+			"Date":                     {"2024-01-01", "2024-01-01", "2024-01-01", "2024-01-04", "2024-01-04", "bad"},
+			"Time":                     {"07:00", "07:30", "18:00", "09:00", "21:15", "10:00"},
+			"Appliance":                {"Fridge", "Heater", "Heater", "Fridge", "TV", "TV"},
+			"Energy_Consumption (kWh)": {"1.5", "4", "3", "1", "0.5", "9"},
+		}
+
+		It("should sum readings per day and appliance", func() {
+			series, err := analytics.Resample(table, model.IntervalDay, wib)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(series.Columns).To(Equal(model.TimeSeriesColumns{Date: "Date", Time: "Time", Energy: "Energy_Consumption (kWh)", Appliance: "Appliance"}))
+			Expect(series.TimeZone).To(Equal("WIB"))
+			Expect(series.Unit).To(Equal("kWh"))
+			Expect(series.SkippedRows).To(Equal(1))
+			Expect(series.Buckets).To(Equal([]time.Time{at(time.January, 1, 0), at(time.January, 2, 0), at(time.January, 3, 0), at(time.January, 4, 0)}))
+			Expect(series.Total).To(Equal([]float64{8.5, 0, 0, 1.5}))
+			Expect(series.Series).To(Equal([]model.TimeSeriesLine{
+				{Name: "Heater", Total: 7, Values: []float64{7, 0, 0, 0}},
+				{Name: "Fridge", Total: 2.5, Values: []float64{1.5, 0, 0, 1}},
+				{Name: "TV", Total: 0.5, Values: []float64{0, 0, 0, 0.5}},
+			}))
+		})
+
+		It("should report runs of empty buckets as gaps", func() {
+			series, err := analytics.Resample(table, model.IntervalDay, wib)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(series.Gaps).To(Equal([]model.TimeGap{{Start: at(time.January, 2, 0), End: at(time.January, 4, 0), Buckets: 2}}))
+		})
+
+		It("should bucket by hour and month", func() {
+			hourly, err := analytics.Resample(table, model.IntervalHour, wib)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hourly.Buckets[0]).To(Equal(at(time.January, 1, 7)))
+			Expect(hourly.Total[0]).To(Equal(5.5))
+			Expect(hourly.Buckets).To(HaveLen(3*24 + 15))
+
+			monthly, err := analytics.Resample(table, model.IntervalMonth, wib)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(monthly.Total).To(Equal([]float64{10}))
+			Expect(monthly.Gaps).To(BeEmpty())
+		})
+
+		It("should bucket in the requested time zone", func() {
+			series, err := analytics.Resample(map[string][]string{
+				"Timestamp": {"2024-01-01T20:00:00Z", "2024-01-02T01:00:00Z"},
+				"Energy":    {"1", "2"},
+			}, model.IntervalDay, wib)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(series.Buckets).To(Equal([]time.Time{at(time.January, 2, 0)}))
+			Expect(series.Total).To(Equal([]float64{3}))
+			Expect(series.Series).To(BeEmpty())
+		})
+
+		It("should reject unknown intervals and oversized ranges", func() {
+			_, err := analytics.Resample(table, "week", wib)
+			Expect(err).To(MatchError(analytics.ErrUnknownInterval))
+
+			_, err = analytics.Resample(map[string][]string{
+				"Date":   {"2020-01-01", "2024-01-01"},
+				"Energy": {"1", "2"},
+			}, model.IntervalHour, wib)
+			Expect(err).To(MatchError(analytics.ErrTooManyBuckets))
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package analytics
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var ErrNoTimestampColumn = errors.New("no timestamp column found")
+
+// datetimeLayouts read cells holding both a date and a time of day. Slashed
+// dates are tried day first, as written in Indonesia; a column is read with
+// whichever layout parses most of its cells, so US dates still work once a
+// day past the 12th shows up.
+var datetimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	time.DateTime,
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2/1/2006 15:04:05",
+	"2/1/2006 15:04",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+	"2-1-2006 15:04",
+}
+
+var dateLayouts = []string{
+	time.DateOnly,
+	"2006/01/02",
+	"2/1/2006",
+	"1/2/2006",
+	"2-1-2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+}
+
+var clockLayouts = []string{
+	"15:04:05",
+	"15:04",
+	"3:04 PM",
+	"3:04PM",
+	"3 PM",
+	"3PM",
+}
+
+// DetectTimestamps finds when each row of table was recorded: from a column
+// of full timestamps, else a date column combined with a time column, else a
+// date column alone. Cells without an offset are read in loc, and every
+// timestamp is returned in loc; rows that can't be read get the zero time.
+func DetectTimestamps(table map[string][]string, loc *time.Location) (model.TimeSeriesColumns, []time.Time, error) {
+	var columns model.TimeSeriesColumns
+	names := slices.Sorted(maps.Keys(table))
+
+	if column, layout := bestColumn(table, names, datetimeLayouts); column != "" {
+		columns.Timestamp = column
+		return columns, parseColumn(table[column], layout, loc), nil
+	}
+
+	date, dateLayout := bestColumn(table, names, dateLayouts)
+	if date == "" {
+		return columns, nil, ErrNoTimestampColumn
+	}
+	columns.Date = date
+	timestamps := parseColumn(table[date], dateLayout, loc)
+
+	clock, _ := bestColumn(table, names, clockLayouts)
+	if clock == "" {
+		return columns, timestamps, nil
+	}
+	columns.Time = clock
+	for row, value := range table[clock] {
+		// Times of day read the same in every layout, so each cell may use any
+		t, ok := parseClock(value)
+		if !ok || timestamps[row].IsZero() {
+			timestamps[row] = time.Time{}
+			continue
+		}
+		day := timestamps[row]
+		timestamps[row] = time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+	return columns, timestamps, nil
+}
+
+// bestColumn returns the column and layout that parse the most cells, if
+// they parse at least half of that column.
+func bestColumn(table map[string][]string, names, layouts []string) (string, string) {
+	bestName, bestLayout, best := "", "", 0
+	for _, name := range names {
+		for _, layout := range layouts {
+			parsed := 0
+			for _, value := range table[name] {
+				if _, err := time.Parse(layout, strings.ToUpper(strings.TrimSpace(value))); err == nil {
+					parsed++
+				}
+			}
+			if parsed > best && parsed*2 >= len(table[name]) {
+				bestName, bestLayout, best = name, layout, parsed
+			}
+		}
+	}
+	return bestName, bestLayout
+}
+
+func parseColumn(values []string, layout string, loc *time.Location) []time.Time {
+	timestamps := make([]time.Time, len(values))
+	for row, value := range values {
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(strings.TrimSpace(value)), loc); err == nil {
+			timestamps[row] = t.In(loc)
+		}
+	}
+	return timestamps
+}
+
+func parseClock(value string) (time.Time, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
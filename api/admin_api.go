@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+func (api *API) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := api.userService.ListUsers()
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to list users")
+		log.Printf("ListUsers error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", users)
+}
+
+// UpdateUser changes a user's role and/or disabled flag. Disabling an account
+// also ends all of its sessions and revokes its API keys.
+func (api *API) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
+		return
+	}
+
+	// Admins can't lock themselves out
+	if userID == r.Context().Value(middleware.UserIDKey).(uint) {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "You cannot change your own account")
+		return
+	}
+
+	if req.Role != nil {
+		if err := api.userService.SetRole(userID, *req.Role); err != nil {
+			writeUserError(w, err)
+			return
+		}
+	}
+
+	if req.Disabled != nil {
+		if err := api.userService.SetDisabled(userID, *req.Disabled); err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		if *req.Disabled {
+			if err := api.sessionService.RevokeAllSessions(userID); err != nil {
+				utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to revoke sessions")
+				log.Printf("RevokeAllSessions error: %v", err)
+				return
+			}
+
+			if err := api.apiKeyService.RevokeAllAPIKeys(userID); err != nil {
+				utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to revoke API keys")
+				log.Printf("RevokeAllAPIKeys error: %v", err)
+				return
+			}
+		}
+	}
+
+	user, err := api.userService.GetUser(userID)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", user)
+}
+
+func (api *API) ExpireUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := api.userService.GetUser(userID); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := api.sessionService.RevokeAllSessions(userID); err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to revoke sessions")
+		log.Printf("RevokeAllSessions error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", "Sessions expired successfully")
+}
+
+func userIDFromRequest(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid user ID")
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		utility.JSONResponse(w, http.StatusNotFound, "failed", "User not found")
+	case errors.Is(err, service.ErrInvalidRole):
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid role")
+	default:
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to update user")
+		log.Printf("Update user error: %v", err)
+	}
+}
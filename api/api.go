@@ -1,52 +1,108 @@
 package api
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gorilla/mux"
 	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/service"
 )
 
 type API struct {
-	token          string
 	userService    service.UserService
 	sessionService service.SessionService
+	apiKeyService  service.APIKeyService
 	fileService    service.FileService
 	aiService      service.AIService
 	chatService    service.ChatService
+	circuitBreaker service.CircuitBreaker
+	insightService service.InsightTemplateService
+	dataLocation   *time.Location
+	tariffService  service.TariffService
 }
 
-func NewAPI(token string, userService service.UserService, sessionService service.SessionService, fileService service.FileService, aiService service.AIService, chatService service.ChatService) API {
+func NewAPI(userService service.UserService, sessionService service.SessionService, apiKeyService service.APIKeyService, fileService service.FileService, aiService service.AIService, chatService service.ChatService, circuitBreaker service.CircuitBreaker, insightService service.InsightTemplateService, dataLocation *time.Location, tariffService service.TariffService) API {
 	api := API{
-		token,
 		userService,
 		sessionService,
+		apiKeyService,
 		fileService,
 		aiService,
 		chatService,
+		circuitBreaker,
+		insightService,
+		dataLocation,
+		tariffService,
 	}
 
 	return api
 }
 
-func RegisterRoutes(token string, router *mux.Router, userService service.UserService, sessionService service.SessionService, fileService service.FileService, aiService service.AIService, chatService service.ChatService) {
-	api := NewAPI(token, userService, sessionService, fileService, aiService, chatService)
+func RegisterRoutes(router *mux.Router, userService service.UserService, sessionService service.SessionService, apiKeyService service.APIKeyService, fileService service.FileService, aiService service.AIService, chatService service.ChatService, circuitBreaker service.CircuitBreaker, insightService service.InsightTemplateService, dataLocation *time.Location, tariffService service.TariffService) {
+	api := NewAPI(userService, sessionService, apiKeyService, fileService, aiService, chatService, circuitBreaker, insightService, dataLocation, tariffService)
 
-	authMiddleware := middleware.AuthMiddleware(sessionService)
+	authMiddleware := middleware.AuthMiddleware(sessionService, apiKeyService)
 	securedRoutes := router.PathPrefix("/").Subrouter()
 	securedRoutes.Use(authMiddleware)
 
+	adminRoutes := securedRoutes.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(middleware.RequireSession, middleware.RequireRole(userService, model.RoleAdmin))
+
 	router.HandleFunc("/register", api.Register).Methods("POST")
 	router.HandleFunc("/login", api.Login).Methods("POST")
+	router.HandleFunc("/refresh", api.Refresh).Methods("POST")
 	router.HandleFunc("/validate-session", api.ValidateSession).Methods("GET")
 
-	securedRoutes.HandleFunc("/logout", api.Logout).Methods("POST")
+	securedRoutes.Handle("/logout", sessionOnly(api.Logout)).Methods("POST")
+	securedRoutes.Handle("/sessions", sessionOnly(api.ListSessions)).Methods("GET")
+	securedRoutes.Handle("/sessions", sessionOnly(api.RevokeAllSessions)).Methods("DELETE")
+	securedRoutes.Handle("/sessions/{sessionId}", sessionOnly(api.RevokeSession)).Methods("DELETE")
+
+	securedRoutes.Handle("/api-keys", sessionOnly(api.ListAPIKeys)).Methods("GET")
+	securedRoutes.Handle("/api-keys", sessionOnly(api.CreateAPIKey)).Methods("POST")
+	securedRoutes.Handle("/api-keys/{keyId}", sessionOnly(api.RevokeAPIKey)).Methods("DELETE")
+
+	securedRoutes.Handle("/upload", withScope(model.ScopeUpload, api.Upload)).Methods("POST")
+	securedRoutes.Handle("/chat-with-ai", withScope(model.ScopeChatWrite, api.ChatWithAI)).Methods("POST")
+
+	securedRoutes.Handle("/chats", withScope(model.ScopeChatRead, api.ListUserChats)).Methods("GET")
+	securedRoutes.Handle("/chats/{chatId}", withScope(model.ScopeChatRead, api.GetChat)).Methods("GET")
+	securedRoutes.Handle("/chats", withScope(model.ScopeChatWrite, api.CreateChat)).Methods("POST")
+	securedRoutes.Handle("/chats/{chatId}", withScope(model.ScopeChatWrite, api.AddMessage)).Methods("PATCH")
+	securedRoutes.Handle("/chats/{chatId}/stream", withScope(model.ScopeChatWrite, api.StreamChat)).Methods("POST")
+
+	securedRoutes.Handle("/datasets", withScope(model.ScopeUpload, api.ListDatasets)).Methods("GET")
+	securedRoutes.Handle("/datasets/{datasetId}", withScope(model.ScopeUpload, api.GetDataset)).Methods("GET")
+	securedRoutes.Handle("/datasets/{datasetId}", withScope(model.ScopeUpload, api.DeleteDataset)).Methods("DELETE")
+	securedRoutes.Handle("/datasets/{datasetId}/summary", withScope(model.ScopeUpload, api.GetDatasetSummary)).Methods("GET")
+	securedRoutes.Handle("/datasets/{datasetId}/timeseries", withScope(model.ScopeUpload, api.GetDatasetTimeSeries)).Methods("GET")
+	securedRoutes.Handle("/datasets/{datasetId}/cost", withScope(model.ScopeUpload, api.GetDatasetCost)).Methods("GET")
+	securedRoutes.Handle("/datasets/{datasetId}/anomalies", withScope(model.ScopeUpload, api.GetDatasetAnomalies)).Methods("GET")
 
-	securedRoutes.HandleFunc("/upload", api.Upload).Methods("POST")
-	securedRoutes.HandleFunc("/chat-with-ai", api.ChatWithAI).Methods("POST")
+	securedRoutes.Handle("/tariff", withScope(model.ScopeUpload, api.GetTariff)).Methods("GET")
+	securedRoutes.Handle("/tariff", withScope(model.ScopeUpload, api.SetTariff)).Methods("PUT")
+	securedRoutes.Handle("/tariff", withScope(model.ScopeUpload, api.DeleteTariff)).Methods("DELETE")
+
+	securedRoutes.Handle("/insight-templates", withScope(model.ScopeUpload, api.ListInsightTemplates)).Methods("GET")
+	securedRoutes.Handle("/insight-templates", withScope(model.ScopeUpload, api.CreateInsightTemplate)).Methods("POST")
+	securedRoutes.Handle("/insight-templates/{templateId}", withScope(model.ScopeUpload, api.UpdateInsightTemplate)).Methods("PATCH")
+	securedRoutes.Handle("/insight-templates/{templateId}", withScope(model.ScopeUpload, api.DeleteInsightTemplate)).Methods("DELETE")
+
+	adminRoutes.HandleFunc("/users", api.ListUsers).Methods("GET")
+	adminRoutes.HandleFunc("/users/{userId}", api.UpdateUser).Methods("PATCH")
+	adminRoutes.HandleFunc("/users/{userId}/sessions", api.ExpireUserSessions).Methods("DELETE")
+	adminRoutes.HandleFunc("/status/ai", api.AIStatus).Methods("GET")
+}
+
+// withScope restricts handler to API keys carrying scope; session logins
+// pass through unchanged.
+func withScope(scope string, handler http.HandlerFunc) http.Handler {
+	return middleware.RequireScope(scope)(handler)
+}
 
-	securedRoutes.HandleFunc("/chats", api.ListUserChats).Methods("GET")
-	securedRoutes.HandleFunc("/chats/{chatId}", api.GetChat).Methods("GET")
-	securedRoutes.HandleFunc("/chats", api.CreateChat).Methods("POST")
-	securedRoutes.HandleFunc("/chats/{chatId}", api.AddMessage).Methods("PATCH")
-	// securedRoutes.HandleFunc("/remove-session", api.RemoveSession).Methods("POST")
+// sessionOnly keeps API keys away from account and credential management.
+func sessionOnly(handler http.HandlerFunc) http.Handler {
+	return middleware.RequireSession(handler)
 }
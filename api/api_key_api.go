@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+func (api *API) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	apiKeys, err := api.apiKeyService.ListAPIKeys(userID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to list API keys")
+		log.Printf("ListAPIKeys error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", apiKeys)
+}
+
+// CreateAPIKey issues a new key. The raw key is only ever returned here.
+func (api *API) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	var req model.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
+		return
+	}
+
+	created, err := api.apiKeyService.CreateAPIKey(userID, req.Name, req.Scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAPIKeyName):
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", "API key name must be between 1 and 100 characters")
+		case errors.Is(err, service.ErrInvalidScope):
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", "Scopes must be one or more of upload, chat:read, chat:write")
+		default:
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to create API key")
+			log.Printf("CreateAPIKey error: %v", err)
+		}
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusCreated, "success", created)
+}
+
+func (api *API) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	keyID, err := strconv.ParseUint(mux.Vars(r)["keyId"], 10, 64)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid API key ID")
+		return
+	}
+
+	if err := api.apiKeyService.RevokeAPIKey(userID, uint(keyID)); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "API key not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to revoke API key")
+		}
+		log.Printf("RevokeAPIKey error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", "API key revoked successfully")
+}
@@ -1,24 +1,22 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"path/filepath"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/z4fL/fp-ai-golang-neurons/middleware"
 	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
 	"github.com/z4fL/fp-ai-golang-neurons/utility"
-	"github.com/z4fL/fp-ai-golang-neurons/utility/projectpath"
 )
 
-const dataFilePath = "upload/data-series.csv"
-
-var dir = filepath.Join(projectpath.Root, dataFilePath)
-
 func (h *API) ChatWithAI(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -36,47 +34,80 @@ func (h *API) ChatWithAI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Ambil userID dari context
+	userIDUint := r.Context().Value(middleware.UserIDKey).(uint)
+	userID := strconv.FormatUint(uint64(userIDUint), 10)
+	chatID := strconv.FormatUint(uint64(chatReq.ChatID), 10)
+
 	var answer string
+	var reply any // the response body; table QA answers also carry the structured result
+	var meta any  // only table QA answers carry metadata
 	switch chatReq.Type {
 	case "tapas":
-		filePath := dir
-
-		if !h.fileService.GetRepo().FileExists(filePath) {
-			utility.JSONResponse(w, http.StatusNotFound, "failed", "Data file not found")
-			log.Printf("File not found: %s", filePath)
+		dataset, parsedData, err := h.fileService.LoadDataset(userIDUint, chatReq.DatasetID)
+		if err != nil {
+			if errors.Is(err, service.ErrDatasetNotFound) {
+				utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+			} else {
+				utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load dataset")
+			}
+			log.Printf("LoadDataset error: %v", err)
 			return
 		}
 
-		contentFile, err := h.fileService.GetRepo().ReadFile(filePath)
+		result, analysisMeta, err := h.aiService.AnalyzeData(r.Context(), parsedData, dataset.Checksum, chatReq.Query, chatReq.Engine)
 		if err != nil {
-			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to read data file")
-			log.Printf("ReadFile error: %v", err)
+			switch {
+			case errors.Is(err, service.ErrUnknownTableQAProvider):
+				utility.JSONResponse(w, http.StatusBadRequest, "failed", "Unknown table QA engine: "+chatReq.Engine)
+			case errors.Is(err, service.ErrUnansweredQuery):
+				utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The question could not be answered from this dataset")
+			default:
+				writeUpstreamError(w, r, err, "Failed to analyze data with AI")
+			}
+			log.Printf("AnalyzeData error: %v", err)
 			return
 		}
+		answer, meta = result.Text, analysisMeta
+		reply = model.TableQAReply{Answer: result.Text, Result: result}
+		log.Println("Chat request processed successfully with the table QA provider")
 
-		parsedData, err := h.fileService.ParseCSV(string(contentFile))
-		if err != nil {
-			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to parse CSV data")
-			log.Printf("ParseCSV error: %v", err)
-			return
+	case "phi":
+		var history []model.Message
+		if chatReq.ChatID != 0 {
+			history, err = h.chatService.GetConversation(userID, chatID)
+			if err != nil {
+				utility.JSONResponse(w, http.StatusNotFound, "failed", "Chat history not found")
+				log.Printf("GetConversation error: %v", err)
+				return
+			}
+		} else if chatReq.PreviousChat != "" {
+			history = []model.Message{{Role: "assistant", Content: chatReq.PreviousChat}}
 		}
 
-		answer, err = h.aiService.AnalyzeData(parsedData, chatReq.Query, h.token)
-		if err != nil {
-			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to analyze data with AI")
-			log.Printf("AnalyzeData error: %v", err)
-			return
+		// Questions about a dataset are answered from its computed summary
+		var grounding string
+		if chatReq.DatasetID != 0 {
+			grounding, err = h.datasetGrounding(userIDUint, chatReq.DatasetID)
+			if err != nil {
+				if errors.Is(err, service.ErrDatasetNotFound) {
+					utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+				} else {
+					utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load dataset")
+				}
+				log.Printf("LoadDataset error: %v", err)
+				return
+			}
 		}
-		log.Println("Chat request processed successfully with google/tapas-base-finetuned-wtq")
 
-	case "phi":
-		answer, err = h.aiService.ChatWithAI(chatReq.PreviousChat, chatReq.Query, h.token)
+		answer, err = h.aiService.ChatWithAI(r.Context(), history, chatReq.Query, grounding)
 		if err != nil {
-			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to chat with AI Phi")
+			writeUpstreamError(w, r, err, "Failed to chat with AI Phi")
 			log.Printf("ChatWithAI error: %v", err)
 			return
 		}
-		log.Println("Chat request processed successfully with microsoft/Phi-3.5-mini-instruct")
+		reply = answer
+		log.Println("Chat request processed successfully with the chat provider")
 
 	default:
 		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid chat type: "+chatReq.Type)
@@ -84,7 +115,46 @@ func (h *API) ChatWithAI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utility.JSONResponse(w, http.StatusOK, "success", answer)
+	// Save the exchange so the next question sees it as history
+	if chatReq.ChatID != 0 {
+		if err := h.chatService.AppendExchange(userID, chatID, chatReq.Query, answer); err != nil {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to save chat")
+			log.Printf("AppendExchange error: %v", err)
+			return
+		}
+	}
+
+	utility.JSONResponseWithMeta(w, http.StatusOK, "success", reply, meta)
+}
+
+// writeUpstreamError answers a failed model call: 504 when the provider's
+// deadline passed, 503 or 429 with Retry-After while the model is loading,
+// rate limited or cut off by its circuit breaker, 502 for other model server
+// errors, nothing when the client has already gone away, and a 500 with
+// message otherwise.
+func writeUpstreamError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var netErr net.Error
+	var upstreamErr *service.UpstreamError
+	if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(upstreamErr.RetryAfter)))
+	}
+
+	switch {
+	case r.Context().Err() != nil:
+		// The client disconnected, which cancelled the upstream call
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		utility.JSONResponse(w, http.StatusGatewayTimeout, "failed", "The AI model took too long to respond")
+	case errors.Is(err, service.ErrCircuitOpen):
+		utility.JSONResponse(w, http.StatusServiceUnavailable, "failed", "The AI model is temporarily unavailable after repeated failures, please try again later")
+	case errors.Is(err, service.ErrModelLoading):
+		utility.JSONResponse(w, http.StatusServiceUnavailable, "failed", "The AI model is still loading, please try again shortly")
+	case errors.Is(err, service.ErrRateLimited):
+		utility.JSONResponse(w, http.StatusTooManyRequests, "failed", "The AI model is receiving too many requests, please try again shortly")
+	case errors.Is(err, service.ErrUpstream):
+		utility.JSONResponse(w, http.StatusBadGateway, "failed", "The AI model returned an error")
+	default:
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", message)
+	}
 }
 
 func (h *API) CreateChat(w http.ResponseWriter, r *http.Request) {
@@ -167,20 +237,3 @@ func (h *API) ListUserChats(w http.ResponseWriter, r *http.Request) {
 
 	utility.JSONResponse(w, http.StatusOK, "success", chatHistory)
 }
-
-func (h *API) RemoveSession(w http.ResponseWriter, r *http.Request) {
-	if !h.fileService.GetRepo().FileExists(dir) {
-		utility.JSONResponse(w, http.StatusNotFound, "failed", "File not found")
-		log.Printf("File not found: %s", dir)
-		return
-	}
-
-	if err := h.fileService.GetRepo().RemoveFile(dir); err != nil {
-		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to delete file")
-		log.Printf("Failed to delete file %s: %v", dir, err)
-		return
-	}
-
-	utility.JSONResponse(w, http.StatusOK, "success", "File deleted successfully")
-	log.Println("Session file deleted successfully")
-}
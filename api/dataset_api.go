@@ -0,0 +1,342 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/z4fL/fp-ai-golang-neurons/analytics"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+// groundingTopN bounds the rankings sent to the chat model with a dataset.
+const groundingTopN = 5
+
+func (api *API) ListDatasets(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	datasets, err := api.fileService.ListDatasets(userID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to list datasets")
+		log.Printf("ListDatasets error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", datasets)
+}
+
+func (api *API) GetDataset(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	datasetID, ok := datasetIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	dataset, err := api.fileService.GetDataset(userID, datasetID)
+	if err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to get dataset")
+		}
+		log.Printf("GetDataset error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", dataset)
+}
+
+func (api *API) DeleteDataset(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	datasetID, ok := datasetIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := api.fileService.DeleteDataset(userID, datasetID); err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to delete dataset")
+		}
+		log.Printf("DeleteDataset error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", "Dataset deleted successfully")
+}
+
+// GetDatasetSummary computes the energy statistics of a dataset. The
+// optional top query parameter limits the rankings to their first entries.
+func (api *API) GetDatasetSummary(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	datasetID, ok := datasetIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	topN := 0
+	if top := r.URL.Query().Get("top"); top != "" {
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 0 {
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", "top must be a non-negative integer")
+			return
+		}
+		topN = n
+	}
+
+	_, parsedData, err := api.fileService.LoadDataset(userID, datasetID)
+	if err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load dataset")
+		}
+		log.Printf("LoadDataset error: %v", err)
+		return
+	}
+
+	summary, err := analytics.Summarize(parsedData, topN)
+	if err != nil {
+		if errors.Is(err, analytics.ErrNoEnergyColumn) || errors.Is(err, analytics.ErrNoReadings) {
+			utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The dataset has no energy consumption readings to summarize")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to summarize dataset")
+		}
+		log.Printf("Summarize error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", summary)
+}
+
+// GetDatasetTimeSeries resamples a dataset's consumption for charting. The
+// interval query parameter is hour, day (the default) or month, and tz an
+// IANA time zone overriding DATA_TIMEZONE.
+func (api *API) GetDatasetTimeSeries(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	datasetID, ok := datasetIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = model.IntervalDay
+	}
+
+	loc := api.dataLocation
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", "Unknown time zone: "+tz)
+			return
+		}
+	}
+
+	_, parsedData, err := api.fileService.LoadDataset(userID, datasetID)
+	if err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load dataset")
+		}
+		log.Printf("LoadDataset error: %v", err)
+		return
+	}
+
+	series, err := analytics.Resample(parsedData, interval, loc)
+	if err != nil {
+		switch {
+		case errors.Is(err, analytics.ErrUnknownInterval):
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", "interval must be one of hour, day, month")
+		case errors.Is(err, analytics.ErrTooManyBuckets):
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", "The dataset spans too many buckets, use a coarser interval")
+		case errors.Is(err, analytics.ErrNoTimestampColumn):
+			utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The dataset has no timestamp column")
+		case errors.Is(err, analytics.ErrNoEnergyColumn) || errors.Is(err, analytics.ErrNoReadings):
+			utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The dataset has no timestamped energy consumption readings")
+		default:
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to resample dataset")
+		}
+		log.Printf("Resample error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", series)
+}
+
+// GetDatasetCost prices a dataset's consumption under the user's tariff.
+func (api *API) GetDatasetCost(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	datasetID, ok := datasetIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	_, parsedData, err := api.fileService.LoadDataset(userID, datasetID)
+	if err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load dataset")
+		}
+		log.Printf("LoadDataset error: %v", err)
+		return
+	}
+
+	tariff, err := api.tariffService.GetTariff(userID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to get tariff")
+		log.Printf("GetTariff error: %v", err)
+		return
+	}
+
+	estimate, err := analytics.EstimateCost(parsedData, tariff, api.dataLocation)
+	if err != nil {
+		switch {
+		case errors.Is(err, analytics.ErrNotKWh):
+			utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The dataset's energy column is not in kWh")
+		case errors.Is(err, analytics.ErrNoEnergyColumn) || errors.Is(err, analytics.ErrNoReadings):
+			utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The dataset has no energy consumption readings to price")
+		default:
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to estimate cost")
+		}
+		log.Printf("EstimateCost error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", estimate)
+}
+
+// GetDatasetAnomalies reports unusual consumption in a dataset. The
+// severity query parameter keeps anomalies at or above low, medium or high,
+// type keeps spike, step or standby ones, and from and to (RFC 3339 or
+// YYYY-MM-DD, in DATA_TIMEZONE) keep those overlapping that range. A
+// date-only to includes its whole day.
+func (api *API) GetDatasetAnomalies(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	datasetID, ok := datasetIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := model.AnomalyFilter{MinSeverity: query.Get("severity"), Type: query.Get("type")}
+	if filter.MinSeverity != "" && !analytics.ValidSeverity(filter.MinSeverity) {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "severity must be one of low, medium, high")
+		return
+	}
+	if !slices.Contains([]string{"", model.AnomalySpike, model.AnomalyStep, model.AnomalyStandby}, filter.Type) {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "type must be one of spike, step, standby")
+		return
+	}
+	for _, name := range []string{"from", "to"} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value, api.dataLocation, name == "to")
+		if err != nil {
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", name+" must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			return
+		}
+		if name == "from" {
+			filter.From = t
+		} else {
+			filter.To = t
+		}
+	}
+
+	_, parsedData, err := api.fileService.LoadDataset(userID, datasetID)
+	if err != nil {
+		if errors.Is(err, service.ErrDatasetNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load dataset")
+		}
+		log.Printf("LoadDataset error: %v", err)
+		return
+	}
+
+	report, err := analytics.DetectAnomalies(parsedData, api.dataLocation)
+	if err != nil {
+		if errors.Is(err, analytics.ErrNoEnergyColumn) || errors.Is(err, analytics.ErrNoReadings) {
+			utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The dataset has no energy consumption readings")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to detect anomalies")
+		}
+		log.Printf("DetectAnomalies error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", analytics.FilterAnomalies(report, filter))
+}
+
+// datasetGrounding describes a dataset and what it costs for the chat
+// model's system prompt. Datasets that can't be summarized yield no
+// grounding, so the chat still works on them.
+func (api *API) datasetGrounding(userID, datasetID uint) (string, error) {
+	_, parsedData, err := api.fileService.LoadDataset(userID, datasetID)
+	if err != nil {
+		return "", err
+	}
+
+	summary, err := analytics.Summarize(parsedData, groundingTopN)
+	if err != nil {
+		log.Printf("Summarize error for dataset %d: %v", datasetID, err)
+		return "", nil
+	}
+	content := analytics.Describe(summary)
+
+	tariff, err := api.tariffService.GetTariff(userID)
+	if err != nil {
+		return "", err
+	}
+	if estimate, err := analytics.EstimateCost(parsedData, tariff, api.dataLocation); err == nil {
+		content += "\n" + analytics.DescribeCost(estimate, groundingTopN)
+	} else {
+		log.Printf("EstimateCost error for dataset %d: %v", datasetID, err)
+	}
+	content += "\nUse these figures when the user asks about their data."
+
+	return content, nil
+}
+
+// datasetIDFromRequest reads the {datasetId} URL parameter, writing a 400
+// response when it is not a valid ID.
+func datasetIDFromRequest(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	datasetID, err := strconv.ParseUint(mux.Vars(r)["datasetId"], 10, 64)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid dataset ID")
+		return 0, false
+	}
+	return uint(datasetID), true
+}
+
+// parseTimeParam reads a time query parameter as an RFC 3339 timestamp, or
+// as a date in loc. A date given as an exclusive upper bound covers the whole
+// day, so it is read as the start of the next one.
+func parseTimeParam(value string, loc *time.Location, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil || !upper {
+		return day, err
+	}
+	return day.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,123 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/api"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+)
+
+type MockFileService struct {
+	ProcessFileFunc   func(userID uint, filename, fileContent string) (*model.Dataset, map[string][]string, error)
+	LoadDatasetFunc   func(userID, datasetID uint) (*model.Dataset, map[string][]string, error)
+	GetDatasetFunc    func(userID, datasetID uint) (*model.Dataset, error)
+	ListDatasetsFunc  func(userID uint) ([]model.Dataset, error)
+	DeleteDatasetFunc func(userID, datasetID uint) error
+	ParseCSVFunc      func(fileContent string) (map[string][]string, error)
+	GetRepoFunc       func() repository.FileRepository
+}
+
+func (m *MockFileService) ProcessFile(userID uint, filename, fileContent string) (*model.Dataset, map[string][]string, error) {
+	return m.ProcessFileFunc(userID, filename, fileContent)
+}
+
+func (m *MockFileService) LoadDataset(userID, datasetID uint) (*model.Dataset, map[string][]string, error) {
+	return m.LoadDatasetFunc(userID, datasetID)
+}
+
+func (m *MockFileService) GetDataset(userID, datasetID uint) (*model.Dataset, error) {
+	return m.GetDatasetFunc(userID, datasetID)
+}
+
+func (m *MockFileService) ListDatasets(userID uint) ([]model.Dataset, error) {
+	return m.ListDatasetsFunc(userID)
+}
+
+func (m *MockFileService) DeleteDataset(userID, datasetID uint) error {
+	return m.DeleteDatasetFunc(userID, datasetID)
+}
+
+func (m *MockFileService) ParseCSV(fileContent string) (map[string][]string, error) {
+	return m.ParseCSVFunc(fileContent)
+}
+
+func (m *MockFileService) GetRepo() repository.FileRepository {
+	return m.GetRepoFunc()
+}
+
+var _ = Describe("GetDatasetAnomalies", func() {
+	wib := time.FixedZone("WIB", 7*60*60)
+
+	var handler api.API
+
+	BeforeEach(func() {
+		// A TV that jumps to 10 kWh at 07:00 on 5 January
+		table := map[string][]string{}
+		for hour, reading := range []string{"1", "1.1", "0.9", "1", "1.05", "0.95", "1", "10"} {
+			table["Timestamp"] = append(table["Timestamp"], time.Date(2024, 1, 5, hour, 0, 0, 0, wib).Format("2006-01-02 15:04"))
+			table["Appliance"] = append(table["Appliance"], "TV")
+			table["Energy_Consumption (kWh)"] = append(table["Energy_Consumption (kWh)"], reading)
+		}
+
+		fileService := &MockFileService{
+			LoadDatasetFunc: func(userID, datasetID uint) (*model.Dataset, map[string][]string, error) {
+				if datasetID != 1 {
+					return nil, nil, service.ErrDatasetNotFound
+				}
+				return &model.Dataset{UserID: userID}, table, nil
+			},
+		}
+		handler = api.NewAPI(nil, nil, nil, fileService, nil, nil, nil, nil, wib, nil)
+	})
+
+	get := func(query string) (int, model.AnomalyReport) {
+		req := httptest.NewRequest(http.MethodGet, "/datasets/1/anomalies?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"datasetId": "1"})
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, uint(1)))
+		rec := httptest.NewRecorder()
+
+		handler.GetDatasetAnomalies(rec, req)
+
+		var body struct {
+			Answer model.AnomalyReport `json:"answer"`
+		}
+		if rec.Code == http.StatusOK {
+			Expect(json.NewDecoder(rec.Body).Decode(&body)).To(Succeed())
+		}
+		return rec.Code, body.Answer
+	}
+
+	It("should include the whole day of a date-only upper bound", func() {
+		code, report := get("from=2024-01-05&to=2024-01-05")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Anomalies).To(HaveLen(1))
+		Expect(*report.Anomalies[0].Start).To(BeTemporally("==", time.Date(2024, 1, 5, 7, 0, 0, 0, wib)))
+	})
+
+	It("should leave out anomalies after a date-only upper bound", func() {
+		code, report := get("to=2024-01-04")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Anomalies).To(BeEmpty())
+	})
+
+	It("should treat a timestamp upper bound as exclusive", func() {
+		code, report := get("to=2024-01-05T07:00:00%2B07:00")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Anomalies).To(BeEmpty())
+	})
+
+	It("should reject a malformed bound", func() {
+		code, _ := get("to=05-01-2024")
+		Expect(code).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -2,11 +2,16 @@ package api
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"strings"
 
+	"github.com/z4fL/fp-ai-golang-neurons/analytics"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
 	"github.com/z4fL/fp-ai-golang-neurons/utility"
 )
 
@@ -43,27 +48,55 @@ func (api *API) Upload(w http.ResponseWriter, r *http.Request) {
 	}
 	fileContent := buf.String()
 
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	// Reject an unknown engine before the dataset is saved
+	engine := r.FormValue("engine")
+	if err := api.aiService.CheckTableQAEngine(engine); err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Unknown table QA engine: "+engine)
+		log.Printf("CheckTableQAEngine error: %v", err)
+		return
+	}
+
 	// process file
-	parsedData, err := api.fileService.ProcessFile(fileContent)
+	dataset, parsedData, err := api.fileService.ProcessFile(userID, handler.Filename, fileContent)
 	if err != nil {
 		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to process file content")
 		log.Printf("ProcessFile error: %v", err)
 		return
 	}
 
-	queries := []string{
-		"Find the least electricity usage appliance.",
-		"Find the most electricity usage appliance.",
+	insights, err := api.insightService.ResolveInsightTemplates(userID, dataset.ID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load insight templates")
+		log.Printf("ResolveInsightTemplates error: %v", err)
+		return
 	}
 
 	// analyze data
-	answer, err := api.aiService.AnalyzeFile(parsedData, queries, api.token)
+	analysis, meta, err := api.aiService.AnalyzeFile(r.Context(), parsedData, dataset.Checksum, insights, engine)
 	if err != nil {
-		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to analyze data")
+		switch {
+		case errors.Is(err, service.ErrUnknownTableQAProvider):
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", "Unknown table QA engine: "+engine)
+		case errors.Is(err, service.ErrUnansweredQuery):
+			utility.JSONResponse(w, http.StatusUnprocessableEntity, "failed", "The uploaded data could not be analyzed")
+		default:
+			writeUpstreamError(w, r, err, "Failed to analyze data")
+		}
 		log.Printf("AnalyzeFile error: %v", err)
 		return
 	}
 
-	utility.JSONResponse(w, http.StatusOK, "success", answer)
+	response := model.UploadResponse{DatasetID: dataset.ID, Answer: analysis.Answer, Results: analysis.Results}
+	// Anomalies are a bonus on upload: data without energy readings still
+	// gets its answer
+	if report, err := analytics.DetectAnomalies(parsedData, api.dataLocation); err == nil {
+		response.Anomalies = &report
+	} else {
+		log.Printf("DetectAnomalies error for dataset %d: %v", dataset.ID, err)
+	}
+
+	utility.JSONResponseWithMeta(w, http.StatusOK, "success", response, meta)
 	log.Println("Success to upload file")
 }
@@ -0,0 +1,50 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/api"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+)
+
+var _ = Describe("Upload", func() {
+	It("should reject an unknown engine without saving the dataset", func() {
+		processed := false
+		fileService := &MockFileService{
+			ProcessFileFunc: func(userID uint, filename, fileContent string) (*model.Dataset, map[string][]string, error) {
+				processed = true
+				return &model.Dataset{}, nil, nil
+			},
+		}
+		tableQA := map[string]service.TableQAProvider{
+			"tapas": service.NewHuggingFaceTableQAProvider(nil, model.TableQAConfig{}),
+		}
+		handler := api.NewAPI(nil, nil, nil, fileService, service.NewAIService(nil, tableQA, "tapas", nil), nil, nil, nil, nil, nil)
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", "usage.csv")
+		Expect(err).NotTo(HaveOccurred())
+		part.Write([]byte("Appliance,Energy_Consumption (kWh)\nTV,1\n"))
+		Expect(form.WriteField("engine", "nope")).To(Succeed())
+		Expect(form.Close()).To(Succeed())
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, uint(1)))
+		rec := httptest.NewRecorder()
+
+		handler.Upload(rec, req)
+
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(processed).To(BeFalse())
+	})
+})
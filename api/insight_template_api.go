@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+func (api *API) ListInsightTemplates(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	templates, err := api.insightService.ListInsightTemplates(userID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to list insight templates")
+		log.Printf("ListInsightTemplates error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", templates)
+}
+
+func (api *API) CreateInsightTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	var req model.CreateInsightTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
+		return
+	}
+
+	template, err := api.insightService.CreateInsightTemplate(userID, req)
+	if err != nil {
+		writeInsightTemplateError(w, err, "Failed to create insight template")
+		log.Printf("CreateInsightTemplate error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusCreated, "success", template)
+}
+
+func (api *API) UpdateInsightTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	templateID, ok := templateIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.UpdateInsightTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
+		return
+	}
+
+	template, err := api.insightService.UpdateInsightTemplate(userID, templateID, req)
+	if err != nil {
+		writeInsightTemplateError(w, err, "Failed to update insight template")
+		log.Printf("UpdateInsightTemplate error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", template)
+}
+
+func (api *API) DeleteInsightTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	templateID, ok := templateIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := api.insightService.DeleteInsightTemplate(userID, templateID); err != nil {
+		writeInsightTemplateError(w, err, "Failed to delete insight template")
+		log.Printf("DeleteInsightTemplate error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", "Insight template deleted successfully")
+}
+
+func writeInsightTemplateError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInsightTemplateNotFound):
+		utility.JSONResponse(w, http.StatusNotFound, "failed", "Insight template not found")
+	case errors.Is(err, service.ErrDatasetNotFound):
+		utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+	case errors.Is(err, service.ErrInsightQuery), errors.Is(err, service.ErrInsightLabel):
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", err.Error())
+	case errors.Is(err, service.ErrTooManyInsightTemplates):
+		utility.JSONResponse(w, http.StatusConflict, "failed", "A template set can hold at most "+strconv.Itoa(service.MaxInsightTemplates)+" insights")
+	default:
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", message)
+	}
+}
+
+// templateIDFromRequest reads the {templateId} URL parameter, writing a 400
+// response when it is not a valid ID.
+func templateIDFromRequest(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	templateID, err := strconv.ParseUint(mux.Vars(r)["templateId"], 10, 64)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid insight template ID")
+		return 0, false
+	}
+	return uint(templateID), true
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+func (api *API) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+	currentSessionID := r.Context().Value(middleware.SessionIDKey).(uint)
+
+	sessions, err := api.sessionService.ListSessions(userID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to list sessions")
+		log.Printf("ListSessions error: %v", err)
+		return
+	}
+
+	sessionInfos := make([]model.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		sessionInfos = append(sessionInfos, model.SessionInfo{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Expiry:     session.Expiry,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", sessionInfos)
+}
+
+func (api *API) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	sessionID, err := strconv.ParseUint(mux.Vars(r)["sessionId"], 10, 64)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid session ID")
+		return
+	}
+
+	if err := api.sessionService.RevokeSession(userID, uint(sessionID)); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "Session not found")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to revoke session")
+		}
+		log.Printf("RevokeSession error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", "Session revoked successfully")
+}
+
+// RevokeAllSessions logs the user out everywhere, including the session
+// making the request.
+func (api *API) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	if err := api.sessionService.RevokeAllSessions(userID); err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to revoke sessions")
+		log.Printf("RevokeAllSessions error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", "All sessions revoked successfully")
+}
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+// AIStatus reports the circuit state of every model endpoint called so far,
+// so operators can tell an outage from a slow answer. It is admin only, as
+// the endpoints include internal model URLs.
+func (api *API) AIStatus(w http.ResponseWriter, r *http.Request) {
+	utility.JSONResponse(w, http.StatusOK, "success", api.circuitBreaker.Status())
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+// StreamChat answers a question in an existing chat over Server-Sent Events.
+// It sends a "delta" event per piece of the reply, then "done" with the full
+// answer once it has been saved to the chat, or "error" if anything fails
+// after the stream started. Nothing is saved if the client disconnects.
+func (h *API) StreamChat(w http.ResponseWriter, r *http.Request) {
+	chatID := mux.Vars(r)["chatId"]
+
+	// Ambil userID dari context
+	userIDUint := r.Context().Value(middleware.UserIDKey).(uint)
+	userID := strconv.FormatUint(uint64(userIDUint), 10)
+
+	var req model.StreamChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Query) == "" {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
+		return
+	}
+
+	history, err := h.chatService.GetConversation(userID, chatID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusNotFound, "failed", "Chat history not found")
+		return
+	}
+
+	var grounding string
+	if req.DatasetID != 0 {
+		grounding, err = h.datasetGrounding(userIDUint, req.DatasetID)
+		if err != nil {
+			if errors.Is(err, service.ErrDatasetNotFound) {
+				utility.JSONResponse(w, http.StatusNotFound, "failed", "Dataset not found")
+			} else {
+				utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to load dataset")
+			}
+			log.Printf("LoadDataset error: %v", err)
+			return
+		}
+	}
+
+	flusher, ok := utility.StartSSE(w)
+	if !ok {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Streaming is not supported")
+		return
+	}
+
+	answer, err := h.aiService.StreamChatWithAI(r.Context(), history, req.Query, grounding, func(delta string) error {
+		return utility.WriteSSE(w, flusher, "delta", map[string]string{"content": delta})
+	})
+	if err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("StreamChat client disconnected from chat %s", chatID)
+			return
+		}
+		utility.WriteSSE(w, flusher, "error", map[string]string{"message": "Failed to chat with AI"})
+		log.Printf("StreamChatWithAI error: %v", err)
+		return
+	}
+
+	if err := h.chatService.AppendExchange(userID, chatID, req.Query, answer); err != nil {
+		utility.WriteSSE(w, flusher, "error", map[string]string{"message": "Failed to save chat"})
+		log.Printf("AppendExchange error: %v", err)
+		return
+	}
+
+	utility.WriteSSE(w, flusher, "done", map[string]string{"content": answer})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/z4fL/fp-ai-golang-neurons/middleware"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+// GetTariff returns the user's tariff, or the default one with ID 0.
+func (api *API) GetTariff(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	tariff, err := api.tariffService.GetTariff(userID)
+	if err != nil {
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to get tariff")
+		log.Printf("GetTariff error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", tariff)
+}
+
+func (api *API) SetTariff(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	var req model.TariffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
+		return
+	}
+
+	tariff, err := api.tariffService.SetTariff(userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTariff) {
+			utility.JSONResponse(w, http.StatusBadRequest, "failed", err.Error())
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to save tariff")
+		}
+		log.Printf("SetTariff error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", tariff)
+}
+
+// DeleteTariff goes back to the default tariff.
+func (api *API) DeleteTariff(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.UserIDKey).(uint)
+
+	if err := api.tariffService.DeleteTariff(userID); err != nil {
+		if errors.Is(err, service.ErrTariffNotFound) {
+			utility.JSONResponse(w, http.StatusNotFound, "failed", "No tariff has been set")
+		} else {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to delete tariff")
+		}
+		log.Printf("DeleteTariff error: %v", err)
+		return
+	}
+
+	utility.JSONResponse(w, http.StatusOK, "success", "Tariff deleted successfully")
+}
@@ -2,25 +2,45 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
-	"github.com/google/uuid"
 	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
 	"github.com/z4fL/fp-ai-golang-neurons/utility"
 )
 
 func (api *API) Register(w http.ResponseWriter, r *http.Request) {
-	var user model.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var credentials model.Credentials
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
 		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
 		return
 	}
 
+	user := model.User{Username: credentials.Username, Password: credentials.Password}
 	if err := api.userService.Register(user); err != nil {
-		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to register user")
+		var validationErr *service.ValidationError
+		if !errors.As(err, &validationErr) {
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to register user")
+			log.Printf("Register error: %v", err)
+			return
+		}
+
+		statusCode := http.StatusBadRequest
+		if errors.Is(err, service.ErrUsernameTaken) {
+			statusCode = http.StatusConflict
+		}
+
+		utility.JSONResponse(w, statusCode, "failed", model.ValidationErrorResponse{
+			Message: validationErr.Error(),
+			Fields:  validationErr.Fields,
+		})
 		return
 	}
 
@@ -28,38 +48,67 @@ func (api *API) Register(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) Login(w http.ResponseWriter, r *http.Request) {
-	var credentials model.User
+	var credentials model.Credentials
 
 	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
 		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
 		return
 	}
 
-	user, err := api.userService.Login(credentials.Username, credentials.Password)
+	user, err := api.userService.Login(credentials.Username, credentials.Password, utility.ClientIP(r))
 	if err != nil {
-		utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Invalid username or password")
+		var blockedErr *service.LoginBlockedError
+		switch {
+		case errors.As(err, &blockedErr):
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(blockedErr.RetryAfter)))
+			if errors.Is(err, service.ErrAccountLocked) {
+				utility.JSONResponse(w, http.StatusLocked, "failed", "Too many failed login attempts, account is temporarily locked")
+			} else {
+				utility.JSONResponse(w, http.StatusTooManyRequests, "failed", "Too many failed login attempts, please wait before trying again")
+			}
+		case errors.Is(err, service.ErrAccountDisabled):
+			utility.JSONResponse(w, http.StatusForbidden, "failed", "Account is disabled")
+		case errors.Is(err, service.ErrAuthenticationFailed):
+			utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Invalid username or password")
+		default:
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Internal Server Error")
+			log.Printf("Login error: %v", err)
+		}
 		return
 	}
 
-	sessionToken := uuid.NewString()
-	expiresAt := time.Now().Add(5 * time.Hour)
-	session := model.Session{Token: sessionToken, UserID: user.ID, Expiry: expiresAt}
-
-	err = api.sessionService.SessionAvailID(user.ID)
+	tokens, err := api.sessionService.StartSession(user.ID, truncate(r.UserAgent(), 255), utility.ClientIP(r))
 	if err != nil {
-		log.Print("Add session")
-		err = api.sessionService.AddSession(session)
-	} else {
-		log.Print("Update session")
-		err = api.sessionService.UpdateSession(session)
+		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Internal Server Error")
+		log.Printf("StartSession error: %v", err)
+		return
 	}
 
+	utility.JSONResponse(w, http.StatusOK, "success", tokens)
+}
+
+func (api *API) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req model.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		utility.JSONResponse(w, http.StatusBadRequest, "failed", "Invalid request payload")
+		return
+	}
+
+	tokens, err := api.sessionService.RefreshSession(req.RefreshToken, truncate(r.UserAgent(), 255), utility.ClientIP(r))
 	if err != nil {
-		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Internal Server Error")
+		switch {
+		case errors.Is(err, service.ErrRefreshTokenReused):
+			utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Refresh token reuse detected, please log in again")
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Invalid or expired refresh token")
+		default:
+			utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to refresh session")
+		}
+		log.Printf("RefreshSession error: %v", err)
 		return
 	}
 
-	utility.JSONResponse(w, http.StatusOK, "success", sessionToken)
+	utility.JSONResponse(w, http.StatusOK, "success", tokens)
 }
 
 func (api *API) Logout(w http.ResponseWriter, r *http.Request) {
@@ -73,13 +122,13 @@ func (api *API) Logout(w http.ResponseWriter, r *http.Request) {
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 
 	// Validasi token
-	_, err := api.sessionService.SessionAvailToken(token)
+	session, err := api.sessionService.SessionAvailToken(token)
 	if err != nil {
 		utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Invalid token")
 		return
 	}
 
-	err = api.sessionService.DeleteSession(token)
+	err = api.sessionService.RevokeSession(session.UserID, session.ID)
 	if err != nil {
 		utility.JSONResponse(w, http.StatusInternalServerError, "failed", "Failed to logout")
 		return
@@ -109,3 +158,18 @@ func (api *API) ValidateSession(w http.ResponseWriter, r *http.Request) {
 
 	utility.JSONResponse(w, http.StatusOK, "success", "Token is valid")
 }
+
+// retryAfterSeconds rounds d up to whole seconds for the Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+// truncate shortens s to at most n characters, dropping invalid UTF-8, so
+// client-supplied values fit their varchar columns.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
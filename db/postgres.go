@@ -17,7 +17,7 @@ func NewDB() *Postgres {
 func (p *Postgres) Connect(creds *model.DBCredential) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=Asia/Jakarta", creds.Host, creds.Username, creds.Password, creds.DatabaseName, creds.Port)
 
-	dbConn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	dbConn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -38,3 +38,54 @@ func (p *Postgres) Reset(db *gorm.DB, table string) error {
 		return nil
 	})
 }
+
+// DropPlaintextTokens invalidates sessions and refresh tokens created before
+// tokens were stored as SHA-256 digests, then drops the plaintext columns.
+// It must run after AutoMigrate has added the token_hash columns and is a
+// no-op once the old columns are gone.
+func (p *Postgres) DropPlaintextTokens(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []any{&model.Session{}, &model.RefreshToken{}} {
+			if !tx.Migrator().HasColumn(table, "token") {
+				continue
+			}
+
+			if err := tx.Unscoped().Where("token_hash IS NULL OR token_hash = ''").Delete(table).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Migrator().DropColumn(table, "token"); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeduplicateDatasets soft-deletes all but the oldest of the datasets a user
+// uploaded with the same content, moving their insight templates to the
+// kept one, so AutoMigrate can add the unique (user_id, checksum) index. It
+// must run before AutoMigrate and is a no-op on a fresh database.
+func (p *Postgres) DeduplicateDatasets(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&model.Dataset{}) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		duplicates := `SELECT id, MIN(id) OVER (PARTITION BY user_id, checksum) AS kept_id
+			FROM datasets WHERE deleted_at IS NULL`
+
+		if tx.Migrator().HasTable(&model.InsightTemplate{}) {
+			if err := tx.Exec(`UPDATE insight_templates SET dataset_id = d.kept_id
+				FROM (` + duplicates + `) d
+				WHERE insight_templates.dataset_id = d.id AND d.id <> d.kept_id`).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Exec(`UPDATE datasets SET deleted_at = NOW()
+			FROM (` + duplicates + `) d
+			WHERE datasets.id = d.id AND d.id <> d.kept_id`).Error
+	})
+}
@@ -102,6 +102,9 @@ const ChatLayout = () => {
         console.log("createNewChat");
 
         await createNewChat(responseChat);
+      } else if (chatId && chatHistory.at(-1).type === "text") {
+        // the server already saved this exchange to the chat
+        console.log("chat saved by server");
       } else {
         console.log("updateChat");
         await updateChat(responseChat);
@@ -163,10 +166,16 @@ const ChatLayout = () => {
     if (file) setFile(null); // remove file
     if (!res.ok) throw new Error(data.answer);
 
+    // upload responses carry the dataset the following /file queries run on
+    if (data.answer && data.answer.datasetId) {
+      localStorage.setItem("dataset_id", data.answer.datasetId);
+    }
+
     return {
       id: chatHistory.length + 1,
       role: "assistant",
-      content: data.answer,
+      // uploads and /file questions answer with an object holding the text
+      content: typeof data.answer === "string" ? data.answer : data.answer.answer,
       type: "text",
     };
   }
@@ -175,10 +184,16 @@ const ChatLayout = () => {
     const lastChat = chatHistory[chatHistory.length - 1];
     const previousChat = chatHistory[chatHistory.length - 2];
 
+    const isTapas = lastChat.content.includes("/file");
+    const datasetId = Number(localStorage.getItem("dataset_id"));
+
     const payload = {
-      type: lastChat.content.includes("/file") ? "tapas" : "phi",
+      type: isTapas ? "tapas" : "phi",
       query: lastChat.content.replace("/file", "").trim(),
-      ...(previousChat.id !== 1 && { prevChat: previousChat.content }),
+      ...(chatId
+        ? { chatId: Number(chatId) }
+        : previousChat.id !== 1 && { prevChat: previousChat.content }),
+      ...(isTapas && datasetId && { datasetId }),
     };
 
     const res = await fetchWithToken(
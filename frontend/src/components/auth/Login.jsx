@@ -4,7 +4,7 @@ import { useNavigate } from "react-router";
 const Login = () => {
   const [username, setUsername] = useState("");
   const [password, setPassword] = useState("");
-  const [isError, setIsError] = useState(false);
+  const [errorMessage, setErrorMessage] = useState("");
 
   const navigate = useNavigate();
 
@@ -34,10 +34,11 @@ const Login = () => {
 
       const res = await req.json();
       if (!req.ok) throw new Error(res.answer);
-      localStorage.setItem("session_token", res.answer);
+      localStorage.setItem("session_token", res.answer.session_token);
+      localStorage.setItem("refresh_token", res.answer.refresh_token);
       navigate("/");
     } catch (error) {
-      setIsError(true);
+      setErrorMessage(error.message || "Invalid username or password");
       setUsername("");
       setPassword("");
       console.log(error);
@@ -50,9 +51,9 @@ const Login = () => {
         <div className="md:w-1/2 flex justify-center font-noto">
           <div className="w-3/4 sm:w-1/2 md:w-3/4">
             <h2 className="text-2xl text-center font-bold mb-4">Login</h2>
-            {isError && (
+            {errorMessage && (
               <div className="mb-4 text-red-500 text-center">
-                Invalid username or password
+                {errorMessage}
               </div>
             )}
             <form onSubmit={handleSubmit}>
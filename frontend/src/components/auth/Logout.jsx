@@ -16,6 +16,7 @@ const Logout = () => {
         });
         if (response.ok) {
           localStorage.removeItem("session_token");
+          localStorage.removeItem("refresh_token");
           navigate("/login");
         } else {
           console.error("Logout failed");
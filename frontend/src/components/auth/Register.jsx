@@ -5,7 +5,9 @@ const Register = () => {
   const [username, setUsername] = useState("");
   const [password, setPassword] = useState("");
   const [isError, setIsError] = useState(false);
-  
+  const [errorMessage, setErrorMessage] = useState("");
+  const [fieldErrors, setFieldErrors] = useState({});
+
   const navigate = useNavigate();
 
   const golangBaseUrl = import.meta.env.VITE_GOLANG_URL;
@@ -26,11 +28,15 @@ const Register = () => {
       });
 
       const res = await req.json();
-      if (!req.ok) throw new Error(res.answer);
+      if (!req.ok) {
+        // validation failures come back as { message, fields }
+        setFieldErrors(res.answer?.fields ?? {});
+        throw new Error(res.answer?.message ?? res.answer);
+      }
       navigate("/login");
     } catch (error) {
-      setUsername("");
       setPassword("");
+      setErrorMessage(error.message);
       setIsError(true);
       console.log(error);
     }
@@ -44,7 +50,7 @@ const Register = () => {
             <h2 className="text-2xl text-center font-bold mb-4">Register</h2>
             {isError && (
               <div className="mb-4 text-red-500 text-center">
-                Invalid username or password
+                {errorMessage || "Failed to register"}
               </div>
             )}
             <form onSubmit={handleSubmit}>
@@ -64,6 +70,11 @@ const Register = () => {
                   onChange={(e) => setUsername(e.target.value)}
                   required
                 />
+                {fieldErrors.username && (
+                  <p className="mt-1 text-sm text-red-500">
+                    {fieldErrors.username}
+                  </p>
+                )}
               </div>
               <div className="mb-4">
                 <label
@@ -81,6 +92,11 @@ const Register = () => {
                   onChange={(e) => setPassword(e.target.value)}
                   required
                 />
+                {fieldErrors.password && (
+                  <p className="mt-1 text-sm text-red-500">
+                    {fieldErrors.password}
+                  </p>
+                )}
               </div>
               <button
                 className="w-full bg-lime-500 hover:bg-lime-600 active:bg-lime-700 disabled:bg-lime-500 text-white py-2 rounded"
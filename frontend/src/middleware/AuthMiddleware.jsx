@@ -6,6 +6,24 @@ const AuthMiddleware = ({ children }) => {
   const [error, setError] = useState(null);
   const navigate = useNavigate();
 
+  // Exchange the stored refresh token for a new session, returning whether it worked
+  const refreshSession = async () => {
+    const refreshToken = localStorage.getItem("refresh_token");
+    if (!refreshToken) return false;
+
+    const response = await fetch("http://localhost:8080/refresh", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!response.ok) return false;
+
+    const res = await response.json();
+    localStorage.setItem("session_token", res.answer.session_token);
+    localStorage.setItem("refresh_token", res.answer.refresh_token);
+    return true;
+  };
+
   useEffect(() => {
     const checkToken = async () => {
       const token = localStorage.getItem("session_token");
@@ -23,8 +41,11 @@ const AuthMiddleware = ({ children }) => {
           },
         });
 
-        if (response.status === 401) {
+        if (response.status === 401 && (await refreshSession())) {
+          setIsLoading(false);
+        } else if (response.status === 401) {
           localStorage.removeItem("session_token");
+          localStorage.removeItem("refresh_token");
           setIsLoading(false);
           setError("Session expired. Please log in again.");
           setTimeout(() => {
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // DATA_TIMEZONE must load on hosts without a zoneinfo database
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -16,7 +22,10 @@ import (
 	"github.com/z4fL/fp-ai-golang-neurons/utility"
 )
 
-const defaultPort = "8080"
+const (
+	defaultPort     = "8080"
+	shutdownTimeout = 10 * time.Second
+)
 
 func main() {
 	// Load the .env file
@@ -35,28 +44,90 @@ func main() {
 		panic(err)
 	}
 
-	conn.AutoMigrate(&model.User{}, &model.Session{}, &model.Chat{})
+	if err := db.DeduplicateDatasets(conn); err != nil {
+		log.Fatalf("Error removing duplicate datasets: %v", err)
+	}
+
+	conn.AutoMigrate(&model.User{}, &model.Session{}, &model.RefreshToken{}, &model.APIKey{}, &model.LoginAttempt{}, &model.LoginLockout{}, &model.Chat{}, &model.Dataset{}, &model.TableQACacheEntry{}, &model.InsightTemplate{}, &model.Tariff{})
 
-	// Retrieve the Hugging Face token from the environment variables
-	token := os.Getenv("HUGGINGFACE_TOKEN")
-	if token == "" {
+	if err := db.DropPlaintextTokens(conn); err != nil {
+		log.Fatalf("Error invalidating plaintext session tokens: %v", err)
+	}
+
+	chatProviderConfig := utility.GetChatProviderConfig()
+	tableQAConfig := utility.GetTableQAConfig()
+
+	// The Hugging Face token is only required when one of the default
+	// providers runs on Hugging Face
+	if os.Getenv("HUGGINGFACE_TOKEN") == "" && (chatProviderConfig.Provider == "huggingface" || tableQAConfig.Provider == "huggingface") {
 		log.Fatal("Environment variable HUGGINGFACE_TOKEN isn't set in the .env file")
 	}
 
 	userRepo := repository.NewUserRepository(conn)
 	sessionRepo := repository.NewSessionRepo(conn)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(conn)
 	fileRepo := repository.NewFileRepository()
 	chatRepo := repository.NewChatRepository(conn)
+	datasetRepo := repository.NewDatasetRepository(conn)
+	apiKeyRepo := repository.NewAPIKeyRepository(conn)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(conn)
+	tableQACacheRepo := repository.NewTableQACacheRepository(conn)
+	insightTemplateRepo := repository.NewInsightTemplateRepository(conn)
+	tariffRepo := repository.NewTariffRepository(conn)
+
+	sessionConfig := utility.GetSessionConfig()
+
+	loginGuard := service.NewLoginGuard(loginAttemptRepo, utility.GetLoginGuardConfig(), time.Now)
+	userService := service.NewUserService(userRepo, loginGuard)
+	sessionService := service.NewSessionService(sessionRepo, refreshTokenRepo, sessionConfig)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	fileService := service.NewFileService(fileRepo, datasetRepo)
+	// Each provider bounds its own calls; the client timeout is only a backstop
+	retryClient := service.NewRetryClient(&http.Client{Timeout: max(chatProviderConfig.Timeout, tableQAConfig.Timeout)}, utility.GetRetryConfig())
+	// The breaker sits outside the retries so a degraded endpoint fails fast
+	httpClient := service.NewCircuitBreaker(retryClient, utility.GetCircuitBreakerConfig(), time.Now)
+	chatProvider, err := service.NewChatProvider(httpClient, chatProviderConfig)
+	if err != nil {
+		log.Fatalf("Error configuring chat provider: %v", err)
+	}
+	log.Printf("Using chat provider %s", chatProvider.Name())
+
+	tableQAProviders := map[string]service.TableQAProvider{
+		"local": service.NewLocalTableQAProvider(),
+	}
+	if tableQAConfig.APIKey != "" {
+		tableQAProviders["huggingface"] = service.NewHuggingFaceTableQAProvider(httpClient, tableQAConfig)
+	}
+	if _, ok := tableQAProviders[tableQAConfig.Provider]; !ok {
+		log.Fatalf("Error configuring table QA provider: %v: %q", service.ErrUnknownTableQAProvider, tableQAConfig.Provider)
+	}
+	log.Printf("Using table QA provider %s", tableQAProviders[tableQAConfig.Provider].Name())
+
+	tableQACache, err := service.NewTableQACache(utility.GetTableQACacheConfig(), tableQACacheRepo, time.Now)
+	if err != nil {
+		log.Fatalf("Error configuring table QA cache: %v", err)
+	}
+
+	dataLocation, err := utility.GetDataLocation()
+	if err != nil {
+		log.Fatalf("Error loading DATA_TIMEZONE: %v", err)
+	}
 
-	userService := service.NewUserService(userRepo)
-	sessionService := service.NewSessionService(sessionRepo)
-	fileService := service.NewFileService(fileRepo)
-	aiService := service.NewAIService(&http.Client{})
-	chatService := service.NewChatService(chatRepo)
+	aiService := service.NewAIService(chatProvider, tableQAProviders, tableQAConfig.Provider, tableQACache)
+	chatService := service.NewChatService(chatRepo, utility.GetConversationConfig())
+	insightTemplateService := service.NewInsightTemplateService(insightTemplateRepo, datasetRepo)
+	tariffService := service.NewTariffService(tariffRepo, utility.GetDefaultTariff())
+
+	// Promote the configured user to admin so the deployment can be managed
+	if adminUsername := os.Getenv("ADMIN_USERNAME"); adminUsername != "" {
+		if err := userService.EnsureAdmin(adminUsername); err != nil {
+			log.Printf("Error granting admin role to %q: %v", adminUsername, err)
+		}
+	}
 
 	// Set up the router
 	router := mux.NewRouter()
-	api.RegisterRoutes(token, router, userService, sessionService, fileService, aiService, chatService)
+	api.RegisterRoutes(router, userService, sessionService, apiKeyService, fileService, aiService, chatService, httpClient, insightTemplateService, dataLocation, tariffService)
 
 	// List all routes
 	utility.ListRoutes(router)
@@ -65,8 +136,9 @@ func main() {
 	corsHandler := cors.New(cors.Options{
 		// AllowedOrigins: []string{"http://localhost:5173"},
 		AllowedOrigins: []string{"*"},
-		AllowedMethods: []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key"},
+		ExposedHeaders: []string{"X-Session-Expires-At"},
 	}).Handler(router)
 
 	port := os.Getenv("PORT")
@@ -74,9 +146,27 @@ func main() {
 		port = defaultPort
 	}
 
+	// Stop background work and the server on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go service.RunSessionReaper(ctx, sessionService, sessionConfig.ReaperInterval)
+
+	server := &http.Server{Addr: ":" + port, Handler: corsHandler}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server shutdown error: %v", err)
+		}
+	}()
+
 	// Start the server
 	log.Printf("Server running on port %s\n", port)
-	if err := http.ListenAndServe(":"+port, corsHandler); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Server failed to start on port %s: %v", port, err)
 	}
+	log.Println("Server stopped")
 }
@@ -3,7 +3,9 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/z4fL/fp-ai-golang-neurons/service"
 	"github.com/z4fL/fp-ai-golang-neurons/utility"
@@ -11,13 +13,39 @@ import (
 
 type ContextKey string
 
-const UserIDKey ContextKey = "userID"
+const (
+	UserIDKey       ContextKey = "userID"
+	SessionIDKey    ContextKey = "sessionID"
+	APIKeyScopesKey ContextKey = "apiKeyScopes"
+)
 
-func AuthMiddleware(sessionService service.SessionService) func(http.Handler) http.Handler {
+// AuthMiddleware accepts either a session token ("Authorization: Bearer
+// <token>") or an API key (an "X-API-Key" header, or a bearer token starting
+// with service.APIKeyPrefix). Both put the owning user's ID in the context.
+func AuthMiddleware(sessionService service.SessionService, apiKeyService service.APIKeyService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Ambil token dari header Authorization
 			authHeader := r.Header.Get("Authorization")
+			apiKey := r.Header.Get("X-API-Key")
+			if apiKey == "" && strings.HasPrefix(authHeader, "Bearer "+service.APIKeyPrefix) {
+				apiKey = strings.TrimPrefix(authHeader, "Bearer ")
+			}
+
+			if apiKey != "" {
+				key, err := apiKeyService.Authenticate(apiKey)
+				if err != nil {
+					utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Invalid API key")
+					return
+				}
+
+				// Masukkan userID dan scope API key ke context
+				ctx := context.WithValue(r.Context(), UserIDKey, key.UserID)
+				ctx = context.WithValue(ctx, APIKeyScopesKey, []string(key.Scopes))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 				utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Missing or invalid token")
 				return
@@ -33,9 +61,42 @@ func AuthMiddleware(sessionService service.SessionService) func(http.Handler) ht
 				return
 			}
 
+			// Beri tahu client kapan sesi akan berakhir setelah diperpanjang
+			w.Header().Set("X-Session-Expires-At", session.Expiry.UTC().Format(time.RFC3339))
+
 			// Masukkan userID ke context
 			ctx := context.WithValue(r.Context(), UserIDKey, session.UserID)
+			ctx = context.WithValue(ctx, SessionIDKey, session.ID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// RequireScope lets API-key requests through only when the key carries
+// scope. Session-authenticated requests are not restricted by scopes.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, isAPIKey := r.Context().Value(APIKeyScopesKey).([]string)
+			if isAPIKey && !slices.Contains(scopes, scope) {
+				utility.JSONResponse(w, http.StatusForbidden, "failed", "API key is missing the "+scope+" scope")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects API-key requests, for routes such as account and
+// key management that only an interactive login may use.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, isAPIKey := r.Context().Value(APIKeyScopesKey).([]string); isAPIKey {
+			utility.JSONResponse(w, http.StatusForbidden, "failed", "This endpoint requires a session login")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
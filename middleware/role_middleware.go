@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+// RequireRole only lets through users holding one of roles. It reads the
+// user ID set by AuthMiddleware, so it must be used after it.
+func RequireRole(userService service.UserService, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDKey).(uint)
+			if !ok {
+				utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Missing or invalid token")
+				return
+			}
+
+			user, err := userService.GetUser(userID)
+			if err != nil || user.Disabled {
+				utility.JSONResponse(w, http.StatusUnauthorized, "failed", "Missing or invalid token")
+				return
+			}
+
+			if !slices.Contains(roles, user.Role) {
+				utility.JSONResponse(w, http.StatusForbidden, "failed", "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -7,17 +7,140 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser    = "user"
+	RoleAnalyst = "analyst"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
 	gorm.Model
 	Username string `gorm:"type:varchar(100);unique" json:"username"`
-	Password string `gorm:"type:varchar(100)" json:"password"`
+	Password string `gorm:"type:varchar(100)" json:"-"`
+	Role     string `gorm:"type:varchar(20);not null;default:user" json:"role"`
+	Disabled bool   `gorm:"not null;default:false" json:"disabled"`
+}
+
+type UpdateUserRequest struct {
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 type Session struct {
 	gorm.Model
-	Token  string    `json:"token"`
-	UserID uint      `json:"user_id"`
-	Expiry time.Time `json:"expiry"`
+	Token          string    `gorm:"-" json:"-"` // raw token, only set when the session is issued
+	TokenHash      string    `gorm:"type:char(64);uniqueIndex" json:"-"`
+	UserID         uint      `gorm:"index" json:"user_id"`
+	Expiry         time.Time `json:"expiry"`          // idle expiry, slides forward on activity
+	AbsoluteExpiry time.Time `json:"absolute_expiry"` // hard cap that sliding never passes
+	FamilyID       string    `gorm:"index" json:"-"`  // shared with the refresh tokens issued for this login
+	UserAgent      string    `gorm:"type:varchar(255)" json:"user_agent"`
+	IPAddress      string    `gorm:"type:varchar(45)" json:"ip_address"`
+	LastSeenAt     time.Time `json:"last_seen_at"`
+}
+
+type RefreshToken struct {
+	gorm.Model
+	Token     string `gorm:"-"` // raw token, only set when the token is issued
+	TokenHash string `gorm:"type:char(64);uniqueIndex"`
+	UserID    uint   `gorm:"index"`
+	FamilyID  string `gorm:"index"`
+	Expiry    time.Time
+	UsedAt    *time.Time // set once the token has been rotated
+	RevokedAt *time.Time
+}
+
+type SessionConfig struct {
+	IdleTimeout      time.Duration
+	AbsoluteLifetime time.Duration
+	RefreshLifetime  time.Duration
+	ReaperInterval   time.Duration
+}
+
+type AuthTokens struct {
+	SessionToken string    `json:"session_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionInfo is the client-facing view of a session, without its token.
+type SessionInfo struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Expiry     time.Time `json:"expiry"`
+	Current    bool      `json:"current"`
+}
+
+// LoginGuardConfig controls how failed logins are throttled. Failures are
+// counted per username and per client IP within FailureWindow.
+type LoginGuardConfig struct {
+	MaxFailures      int           // per username, before a lockout
+	MaxFailuresPerIP int           // per client IP, before a lockout
+	BaseDelay        time.Duration // backoff after the first failure, doubled for each one after
+	MaxDelay         time.Duration
+	LockoutDuration  time.Duration
+	FailureWindow    time.Duration
+}
+
+// LoginAttempt tracks consecutive failed logins for one key, which is either
+// "username:<name>" or "ip:<address>".
+type LoginAttempt struct {
+	ID            uint      `gorm:"primarykey"`
+	Key           string    `gorm:"type:varchar(300);uniqueIndex;not null"`
+	Failures      int       `gorm:"not null"`
+	LastFailureAt time.Time `gorm:"not null"`
+	LockedUntil   *time.Time
+}
+
+// LoginLockout is an audit record written each time a key gets locked out.
+type LoginLockout struct {
+	gorm.Model
+	Key         string    `gorm:"type:varchar(300);index" json:"key"`
+	Username    string    `gorm:"type:varchar(255)" json:"username"`
+	IPAddress   string    `gorm:"type:varchar(45)" json:"ip_address"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+const (
+	ScopeUpload    = "upload"
+	ScopeChatRead  = "chat:read"
+	ScopeChatWrite = "chat:write"
+)
+
+type APIKey struct {
+	gorm.Model
+	UserID     uint                        `gorm:"index;not null" json:"user_id"`
+	Name       string                      `gorm:"type:varchar(100)" json:"name"`
+	Prefix     string                      `gorm:"type:varchar(16)" json:"prefix"` // first characters of the key, for recognizing it in listings
+	KeyHash    string                      `gorm:"type:char(64);uniqueIndex" json:"-"`
+	Scopes     datatypes.JSONSlice[string] `gorm:"type:jsonb" json:"scopes"`
+	LastUsedAt *time.Time                  `json:"last_used_at"`
+	RevokedAt  *time.Time                  `json:"revoked_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedAPIKey is returned once, when the key is created; the raw key is
+// not stored and can't be retrieved again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
 }
 
 type Chat struct {
@@ -26,6 +149,41 @@ type Chat struct {
 	ChatHistory datatypes.JSON `gorm:"type:jsonb"` // Simpan history sebagai JSONB
 }
 
+type Dataset struct {
+	gorm.Model
+	UserID     uint   `gorm:"index;uniqueIndex:idx_datasets_user_checksum,where:deleted_at IS NULL;not null" json:"user_id"`
+	Filename   string `gorm:"type:varchar(255)" json:"filename"`
+	Checksum   string `gorm:"type:char(64);index;uniqueIndex:idx_datasets_user_checksum,where:deleted_at IS NULL" json:"checksum"`
+	StorageKey string `gorm:"type:varchar(255)" json:"-"`
+	Rows       int    `json:"rows"`
+	Columns    int    `json:"columns"`
+}
+
+// InsightTemplate is a question asked about every uploaded CSV. Templates
+// with a DatasetID apply to re-uploads of that dataset only and replace the
+// user's general ones, which in turn replace the built-in appliance pair.
+type InsightTemplate struct {
+	gorm.Model
+	UserID    uint   `gorm:"index;not null" json:"user_id"`
+	DatasetID *uint  `gorm:"index" json:"dataset_id"`
+	Query     string `gorm:"type:varchar(500);not null" json:"query"`
+	Label     string `gorm:"type:varchar(100);not null" json:"label"`
+	Position  int    `gorm:"not null;default:0" json:"position"`
+}
+
+type CreateInsightTemplateRequest struct {
+	DatasetID *uint  `json:"dataset_id"`
+	Query     string `json:"query"`
+	Label     string `json:"label"`
+	Position  *int   `json:"position"` // defaults to after the last template of the set
+}
+
+type UpdateInsightTemplateRequest struct {
+	Query    *string `json:"query"`
+	Label    *string `json:"label"`
+	Position *int    `json:"position"`
+}
+
 type ChatHistoryEntry struct {
 	ID      int    `json:"id"`
 	Role    string `json:"role"`
@@ -45,12 +203,369 @@ type DBCredential struct {
 type Response struct {
 	Status string `json:"status"`
 	Answer any    `json:"answer"`
+	Meta   any    `json:"meta,omitempty"`
+}
+
+type ValidationErrorResponse struct {
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields"`
 }
 
 type ChatRequest struct {
 	Type         string `json:"type"`
 	Query        string `json:"query"`
-	PreviousChat string `json:"prevChat"`
+	PreviousChat string `json:"prevChat"`         // only used without ChatID
+	ChatID       uint   `json:"chatId"`           // when set, history is loaded and the exchange saved server-side
+	DatasetID    uint   `json:"datasetId"`        // for phi, grounds the answer in the dataset's summary
+	Engine       string `json:"engine,omitempty"` // table QA provider, defaults to TABLE_QA_PROVIDER
+}
+
+// StreamChatRequest is the body of POST /chats/{chatId}/stream.
+type StreamChatRequest struct {
+	Query     string `json:"query"`
+	DatasetID uint   `json:"datasetId"` // grounds the answer in the dataset's summary
+}
+
+// ConversationConfig limits how much stored chat history is sent to the
+// chat provider with each question.
+type ConversationConfig struct {
+	HistoryTokens int // budget for the past turns that are sent verbatim
+	SummaryTokens int // budget for the summary of turns that didn't fit
+}
+
+// ChatProviderConfig selects and configures the LLM behind ChatWithAI.
+type ChatProviderConfig struct {
+	Provider    string // "huggingface", "openai" or "fake"
+	BaseURL     string // OpenAI-compatible server, e.g. http://localhost:11434
+	APIKey      string
+	Model       string
+	Temperature float64
+	MaxTokens   int
+	Timeout     time.Duration // deadline for a whole completion, including a stream
+}
+
+// TableQAConfig selects the default engine behind AnalyzeData and
+// configures the Hugging Face TAPAS model.
+type TableQAConfig struct {
+	Provider string // "huggingface" or "local"
+	Model    string
+	APIKey   string
+	Timeout  time.Duration
+}
+
+// RetryConfig bounds how hard model calls are retried when the server is
+// loading the model, rate limiting, or failing transiently.
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Budget      time.Duration // total time spent waiting between attempts
+}
+
+// CircuitBreakerConfig controls when calls to a model endpoint are cut off.
+// A circuit opens once at least MinRequests calls were made within Window
+// and FailureRate of them failed, and lets a single probe through after
+// CoolDown.
+type CircuitBreakerConfig struct {
+	Window      time.Duration
+	MinRequests int
+	FailureRate float64
+	CoolDown    time.Duration
+}
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitStatus reports the state of one model endpoint's circuit.
+type CircuitStatus struct {
+	Endpoint    string     `json:"endpoint"`
+	State       string     `json:"state"`
+	Requests    int        `json:"requests"`
+	Failures    int        `json:"failures"`
+	FailureRate float64    `json:"failureRate"`
+	OpenedAt    *time.Time `json:"openedAt,omitempty"`
+	RetryAt     *time.Time `json:"retryAt,omitempty"`
+}
+
+// TableQACacheConfig bounds the cache of table QA answers. Backend is
+// "memory", "postgres" or "none".
+type TableQACacheConfig struct {
+	Backend    string
+	TTL        time.Duration
+	MaxEntries int
+}
+
+// TableQACacheEntry is a cached table QA answer, stored under a hash of the
+// dataset checksum, the normalized question and the provider's model.
+type TableQACacheEntry struct {
+	ID        uint                              `gorm:"primarykey"`
+	Key       string                            `gorm:"type:char(64);uniqueIndex;not null"`
+	Response  datatypes.JSONType[TapasResponse] `gorm:"not null"`
+	ExpiresAt time.Time                         `gorm:"index;not null"`
+	UpdatedAt time.Time
+}
+
+// AnalysisMeta describes how a table QA answer was produced. It is sent as
+// the response metadata of /upload and /chat-with-ai.
+type AnalysisMeta struct {
+	Engine      string `json:"engine"`
+	CacheHits   int    `json:"cacheHits"`
+	CacheMisses int    `json:"cacheMisses"`
+}
+
+type UploadResponse struct {
+	DatasetID uint            `json:"datasetId"`
+	Answer    string          `json:"answer"`
+	Results   []InsightResult `json:"results"`
+	Anomalies *AnomalyReport  `json:"anomalies,omitempty"` // nil when the data has no energy readings
+}
+
+// TableAnswer is a table QA result with its aggregator applied. Value is set
+// for COUNT and the numeric aggregators; Text is the human-readable form.
+type TableAnswer struct {
+	Aggregator  string        `json:"aggregator"`
+	Value       *float64      `json:"value,omitempty"`
+	Unit        string        `json:"unit,omitempty"`
+	Cells       []string      `json:"cells"`
+	Coordinates [][]int       `json:"coordinates"`
+	Skipped     []SkippedCell `json:"skipped,omitempty"` // cells left out of a numeric aggregator
+	Text        string        `json:"text"`
+}
+
+// SkippedCell is a selected cell that could not be used as a number.
+type SkippedCell struct {
+	Cell   string `json:"cell"`
+	Reason string `json:"reason"`
+}
+
+// EnergySummary is the analysis of an appliance consumption dataset served
+// by GET /datasets/{id}/summary. Energy figures are in Unit; shares are
+// percentages of Total.
+type EnergySummary struct {
+	Columns     EnergyColumns `json:"columns"`
+	Unit        string        `json:"unit"`
+	Readings    int           `json:"readings"`
+	SkippedRows int           `json:"skippedRows"` // rows without a usable energy value
+	Total       float64       `json:"total"`
+	Average     float64       `json:"average"`    // per reading
+	Appliances  []EnergyGroup `json:"appliances"` // highest total first
+	Rooms       []EnergyGroup `json:"rooms"`      // highest total first
+	Hours       []HourlyUsage `json:"hours"`      // by hour of day
+	PeakHours   []HourlyUsage `json:"peakHours"`  // highest total first
+}
+
+// EnergyColumns names the columns an EnergySummary was computed from; all
+// but Energy may be empty when the dataset has no such column.
+type EnergyColumns struct {
+	Energy    string `json:"energy"`
+	Appliance string `json:"appliance,omitempty"`
+	Room      string `json:"room,omitempty"`
+	Time      string `json:"time,omitempty"`
+}
+
+type EnergyGroup struct {
+	Rank     int     `json:"rank"`
+	Name     string  `json:"name"`
+	Total    float64 `json:"total"`
+	Average  float64 `json:"average"`
+	Readings int     `json:"readings"`
+	Share    float64 `json:"share"`
+	PeakHour *int    `json:"peakHour,omitempty"`
+}
+
+type HourlyUsage struct {
+	Hour     int     `json:"hour"`
+	Total    float64 `json:"total"`
+	Average  float64 `json:"average"`
+	Readings int     `json:"readings"`
+	Share    float64 `json:"share"`
+}
+
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalMonth = "month"
+)
+
+// TimeSeries is the consumption of a dataset resampled into buckets of one
+// Interval, served by GET /datasets/{id}/timeseries. Buckets holds the start
+// of every bucket from the first reading to the last, including empty ones,
+// and each series has one value per bucket.
+type TimeSeries struct {
+	Columns     TimeSeriesColumns `json:"columns"`
+	Interval    string            `json:"interval"`
+	TimeZone    string            `json:"timeZone"`
+	Unit        string            `json:"unit"`
+	SkippedRows int               `json:"skippedRows"` // rows without a usable timestamp or energy value
+	Buckets     []time.Time       `json:"buckets"`
+	Total       []float64         `json:"total"`
+	Series      []TimeSeriesLine  `json:"series"` // per appliance, highest total first
+	Gaps        []TimeGap         `json:"gaps"`
+}
+
+// TimeSeriesColumns names the columns a TimeSeries was read from. The time
+// of a reading comes from Timestamp, or from Date and, if present, Time.
+type TimeSeriesColumns struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Energy    string `json:"energy"`
+	Appliance string `json:"appliance,omitempty"`
+}
+
+type TimeSeriesLine struct {
+	Name   string    `json:"name"`
+	Total  float64   `json:"total"`
+	Values []float64 `json:"values"`
+}
+
+// TimeGap is a run of buckets without any reading; End is the start of the
+// next bucket that has one.
+type TimeGap struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Buckets int       `json:"buckets"`
+}
+
+// Tariff is how a user's electricity is priced. Energy is charged at Rate
+// per kWh, by Tiers on each month's consumption, or by time-of-use Windows
+// with Rate outside them; tiers and windows can't be combined.
+type Tariff struct {
+	gorm.Model
+	UserID             uint                              `gorm:"uniqueIndex;not null" json:"-"`
+	Name               string                            `gorm:"type:varchar(100)" json:"name"`
+	Currency           string                            `gorm:"type:varchar(3);not null" json:"currency"`
+	Rate               float64                           `json:"rate"`
+	Tiers              datatypes.JSONSlice[TariffTier]   `gorm:"type:jsonb" json:"tiers"`
+	Windows            datatypes.JSONSlice[TariffWindow] `gorm:"type:jsonb" json:"windows"`
+	FixedMonthlyCharge float64                           `json:"fixedMonthlyCharge"`
+}
+
+// TariffTier prices the kWh of a month up to UpTo; the last tier has no
+// UpTo and prices the rest.
+type TariffTier struct {
+	UpTo *float64 `json:"upTo"`
+	Rate float64  `json:"rate"`
+}
+
+// TariffWindow prices the kWh used from Start up to End, both "HH:MM". A
+// window whose End is before its Start runs past midnight.
+type TariffWindow struct {
+	Start string  `json:"start"`
+	End   string  `json:"end"`
+	Rate  float64 `json:"rate"`
+}
+
+type TariffRequest struct {
+	Name               string         `json:"name"`
+	Currency           string         `json:"currency"`
+	Rate               float64        `json:"rate"`
+	Tiers              []TariffTier   `json:"tiers"`
+	Windows            []TariffWindow `json:"windows"`
+	FixedMonthlyCharge float64        `json:"fixedMonthlyCharge"`
+}
+
+// CostEstimate is the electricity cost of a dataset under a tariff, served
+// by GET /datasets/{id}/cost. Months is empty and the monthly estimates are
+// nil when the dataset has no timestamps.
+type CostEstimate struct {
+	Tariff          string          `json:"tariff"`
+	Currency        string          `json:"currency"`
+	KWh             float64         `json:"kWh"`
+	EnergyCost      float64         `json:"energyCost"`
+	FixedCost       float64         `json:"fixedCost"`
+	Total           float64         `json:"total"`
+	SkippedRows     int             `json:"skippedRows"`               // rows without a usable kWh value or timestamp
+	Days            int             `json:"days,omitempty"`            // calendar days from the first reading to the last
+	MonthlyEstimate *float64        `json:"monthlyEstimate,omitempty"` // the cost of a 30-day month at the same usage
+	Months          []MonthlyCost   `json:"months"`
+	Appliances      []ApplianceCost `json:"appliances"` // highest cost first
+}
+
+type MonthlyCost struct {
+	Month      string  `json:"month"` // YYYY-MM
+	KWh        float64 `json:"kWh"`
+	EnergyCost float64 `json:"energyCost"`
+	FixedCost  float64 `json:"fixedCost"`
+	Total      float64 `json:"total"`
+}
+
+// ApplianceCost is an appliance's share of the energy cost; fixed charges
+// aren't attributed to appliances.
+type ApplianceCost struct {
+	Name            string   `json:"name"`
+	KWh             float64  `json:"kWh"`
+	Cost            float64  `json:"cost"`
+	Share           float64  `json:"share"`
+	MonthlyEstimate *float64 `json:"monthlyEstimate,omitempty"`
+}
+
+const (
+	AnomalySpike   = "spike"   // a reading far above the appliance's usual use at that hour
+	AnomalyStep    = "step"    // a lasting jump or drop in the appliance's use
+	AnomalyStandby = "standby" // an appliance whose use never drops to zero
+
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// Anomaly is unusual consumption found in a dataset. Row is the 0-based data
+// row it was found at; Start and End are only set for timestamped datasets.
+type Anomaly struct {
+	Type      string     `json:"type"`
+	Severity  string     `json:"severity"`
+	Appliance string     `json:"appliance"`
+	Row       int        `json:"row"`
+	Start     *time.Time `json:"start,omitempty"`
+	End       *time.Time `json:"end,omitempty"`
+	Value     float64    `json:"value"`
+	Baseline  float64    `json:"baseline"`
+	Score     float64    `json:"score"` // robust z-score for spikes, rolling z-score for steps
+	Message   string     `json:"message"`
+}
+
+// AnomalyReport is served by GET /datasets/{id}/anomalies and included in
+// the upload response.
+type AnomalyReport struct {
+	Unit        string    `json:"unit"`
+	Readings    int       `json:"readings"`
+	SkippedRows int       `json:"skippedRows"`
+	Anomalies   []Anomaly `json:"anomalies"`
+}
+
+// AnomalyFilter narrows an AnomalyReport; zero fields don't filter. To is
+// exclusive.
+type AnomalyFilter struct {
+	MinSeverity string
+	Type        string
+	From        time.Time
+	To          time.Time
+}
+
+// FileAnalysis is the summary of an upload: one sentence built from the
+// answered insights, and the result of every insight in template order.
+type FileAnalysis struct {
+	Answer  string
+	Results []InsightResult
+}
+
+// InsightResult is the answer to one insight template. Result is nil and
+// Error says why when the data could not answer the query.
+type InsightResult struct {
+	Label  string       `json:"label"`
+	Query  string       `json:"query"`
+	Result *TableAnswer `json:"result"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// TableQAReply is the /chat-with-ai answer to a table QA question.
+type TableQAReply struct {
+	Answer string      `json:"answer"`
+	Result TableAnswer `json:"result"`
 }
 
 type Inputs struct {
@@ -74,6 +589,9 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// PhiRequest and PhiResponse follow the OpenAI chat completions format,
+// which the Hugging Face router and local servers like llama.cpp and
+// Ollama all accept.
 type PhiRequest struct {
 	Model       string    `json:"model"`
 	Messages    []Message `json:"messages"`
@@ -89,3 +607,12 @@ type Choice struct {
 type PhiResponse struct {
 	Choices []Choice `json:"choices"`
 }
+
+// PhiStreamChunk is one "data:" event of a streamed chat completion.
+type PhiStreamChunk struct {
+	Choices []StreamChoice `json:"choices"`
+}
+
+type StreamChoice struct {
+	Delta Message `json:"delta"`
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository interface {
+	AddAPIKey(apiKey *model.APIKey) error
+	ListUserAPIKeys(userID uint) ([]model.APIKey, error)
+	GetAPIKeyByHash(keyHash string) (model.APIKey, error)
+	RevokeUserAPIKey(userID, keyID uint, revokedAt time.Time) error
+	RevokeAllUserAPIKeys(userID uint, revokedAt time.Time) error
+	TouchAPIKey(keyID uint, lastUsedAt time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db}
+}
+
+func (r *apiKeyRepository) AddAPIKey(apiKey *model.APIKey) error {
+	return r.db.Create(apiKey).Error
+}
+
+func (r *apiKeyRepository) ListUserAPIKeys(userID uint) ([]model.APIKey, error) {
+	var apiKeys []model.APIKey
+	if err := r.db.Where("user_id = ?", userID).Order("id desc").Find(&apiKeys).Error; err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func (r *apiKeyRepository) GetAPIKeyByHash(keyHash string) (model.APIKey, error) {
+	var apiKey model.APIKey
+	if err := r.db.Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(&apiKey).Error; err != nil {
+		return model.APIKey{}, err
+	}
+	return apiKey, nil
+}
+
+func (r *apiKeyRepository) RevokeUserAPIKey(userID, keyID uint, revokedAt time.Time) error {
+	result := r.db.Model(&model.APIKey{}).
+		Where("user_id = ? AND id = ? AND revoked_at IS NULL", userID, keyID).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) RevokeAllUserAPIKeys(userID uint, revokedAt time.Time) error {
+	return r.db.Model(&model.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error
+}
+
+func (r *apiKeyRepository) TouchAPIKey(keyID uint, lastUsedAt time.Time) error {
+	return r.db.Model(&model.APIKey{}).Where("id = ?", keyID).Update("last_used_at", lastUsedAt).Error
+}
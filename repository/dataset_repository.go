@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"gorm.io/gorm"
+)
+
+type DatasetRepository interface {
+	AddDataset(dataset *model.Dataset) (*model.Dataset, error)
+	GetUserDataset(userID, datasetID uint) (*model.Dataset, error)
+	GetUserDatasetByChecksum(userID uint, checksum string) (*model.Dataset, error)
+	ListUserDatasets(userID uint) ([]model.Dataset, error)
+	DeleteDataset(dataset *model.Dataset) error
+}
+
+type datasetRepository struct {
+	db *gorm.DB
+}
+
+func NewDatasetRepository(db *gorm.DB) DatasetRepository {
+	return &datasetRepository{db: db}
+}
+
+func (r *datasetRepository) AddDataset(dataset *model.Dataset) (*model.Dataset, error) {
+	if err := r.db.Create(dataset).Error; err != nil {
+		return nil, err
+	}
+	return dataset, nil
+}
+
+func (r *datasetRepository) GetUserDataset(userID, datasetID uint) (*model.Dataset, error) {
+	var dataset model.Dataset
+	if err := r.db.Where("user_id = ? AND id = ?", userID, datasetID).First(&dataset).Error; err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+func (r *datasetRepository) GetUserDatasetByChecksum(userID uint, checksum string) (*model.Dataset, error) {
+	var dataset model.Dataset
+	if err := r.db.Where("user_id = ? AND checksum = ?", userID, checksum).First(&dataset).Error; err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+func (r *datasetRepository) ListUserDatasets(userID uint) ([]model.Dataset, error) {
+	var datasets []model.Dataset
+	if err := r.db.Where("user_id = ?", userID).Order("id desc").Find(&datasets).Error; err != nil {
+		return nil, err
+	}
+	return datasets, nil
+}
+
+// DeleteDataset deletes dataset along with the insight templates scoped to
+// it.
+func (r *datasetRepository) DeleteDataset(dataset *model.Dataset) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dataset_id = ?", dataset.ID).Delete(&model.InsightTemplate{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(dataset).Error
+	})
+}
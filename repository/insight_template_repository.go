@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"gorm.io/gorm"
+)
+
+type InsightTemplateRepository interface {
+	AddInsightTemplate(template *model.InsightTemplate) error
+	ListUserInsightTemplates(userID uint) ([]model.InsightTemplate, error)
+	ListInsightTemplateSet(userID uint, datasetID *uint) ([]model.InsightTemplate, error)
+	GetUserInsightTemplate(userID, templateID uint) (model.InsightTemplate, error)
+	UpdateInsightTemplate(template *model.InsightTemplate) error
+	DeleteUserInsightTemplate(userID, templateID uint) error
+}
+
+type insightTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewInsightTemplateRepository(db *gorm.DB) InsightTemplateRepository {
+	return &insightTemplateRepository{db}
+}
+
+func (r *insightTemplateRepository) AddInsightTemplate(template *model.InsightTemplate) error {
+	return r.db.Create(template).Error
+}
+
+// ListUserInsightTemplates returns every template of the user, the general
+// set first and each set in the order its queries are asked.
+func (r *insightTemplateRepository) ListUserInsightTemplates(userID uint) ([]model.InsightTemplate, error) {
+	var templates []model.InsightTemplate
+	if err := r.db.Where("user_id = ?", userID).
+		Order("dataset_id NULLS FIRST, position, id").
+		Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ListInsightTemplateSet returns the templates for one dataset, or the
+// user's general ones when datasetID is nil, in the order they are asked.
+func (r *insightTemplateRepository) ListInsightTemplateSet(userID uint, datasetID *uint) ([]model.InsightTemplate, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if datasetID == nil {
+		query = query.Where("dataset_id IS NULL")
+	} else {
+		query = query.Where("dataset_id = ?", *datasetID)
+	}
+
+	var templates []model.InsightTemplate
+	if err := query.Order("position, id").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *insightTemplateRepository) GetUserInsightTemplate(userID, templateID uint) (model.InsightTemplate, error) {
+	var template model.InsightTemplate
+	if err := r.db.Where("user_id = ? AND id = ?", userID, templateID).First(&template).Error; err != nil {
+		return model.InsightTemplate{}, err
+	}
+	return template, nil
+}
+
+func (r *insightTemplateRepository) UpdateInsightTemplate(template *model.InsightTemplate) error {
+	return r.db.Save(template).Error
+}
+
+func (r *insightTemplateRepository) DeleteUserInsightTemplate(userID, templateID uint) error {
+	result := r.db.Where("user_id = ? AND id = ?", userID, templateID).Delete(&model.InsightTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
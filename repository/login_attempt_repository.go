@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginAttemptRepository interface {
+	GetLoginAttempt(key string) (model.LoginAttempt, error)
+	RecordLoginFailure(key string, at, windowStart time.Time) (model.LoginAttempt, error)
+	LockLoginAttempt(key string, until time.Time) (bool, error)
+	DeleteLoginAttempt(key string) error
+	AddLoginLockout(lockout *model.LoginLockout) error
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db}
+}
+
+func (r *loginAttemptRepository) GetLoginAttempt(key string) (model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	if err := r.db.Where("key = ?", key).First(&attempt).Error; err != nil {
+		return model.LoginAttempt{}, err
+	}
+	return attempt, nil
+}
+
+// RecordLoginFailure counts a failure for key in a single upsert, so
+// concurrent failures can't overwrite each other's increment, and returns
+// the updated row. A run whose lockout has ended, or whose last failure is
+// before windowStart, starts over at one.
+func (r *loginAttemptRepository) RecordLoginFailure(key string, at, windowStart time.Time) (model.LoginAttempt, error) {
+	stale := gorm.Expr("(login_attempts.locked_until IS NOT NULL AND login_attempts.locked_until <= ?) OR (login_attempts.locked_until IS NULL AND login_attempts.last_failure_at < ?)", at, windowStart)
+
+	attempt := model.LoginAttempt{Key: key, Failures: 1, LastFailureAt: at}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"failures":        gorm.Expr("CASE WHEN ? THEN 1 ELSE login_attempts.failures + 1 END", stale),
+			"locked_until":    gorm.Expr("CASE WHEN ? THEN NULL ELSE login_attempts.locked_until END", stale),
+			"last_failure_at": at,
+		}),
+	}, clause.Returning{}).Create(&attempt).Error
+	if err != nil {
+		return model.LoginAttempt{}, err
+	}
+	return attempt, nil
+}
+
+// LockLoginAttempt locks key until the given time unless it is already
+// locked, reporting whether this call set the lock.
+func (r *loginAttemptRepository) LockLoginAttempt(key string, until time.Time) (bool, error) {
+	result := r.db.Model(&model.LoginAttempt{}).Where("key = ? AND locked_until IS NULL", key).Update("locked_until", until)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *loginAttemptRepository) DeleteLoginAttempt(key string) error {
+	return r.db.Where("key = ?", key).Delete(&model.LoginAttempt{}).Error
+}
+
+func (r *loginAttemptRepository) AddLoginLockout(lockout *model.LoginLockout) error {
+	return r.db.Create(lockout).Error
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	AddRefreshToken(refreshToken model.RefreshToken) error
+	GetRefreshToken(token string) (model.RefreshToken, error)
+	MarkRefreshTokenUsed(id uint, usedAt time.Time) (bool, error)
+	RevokeFamily(familyID string, revokedAt time.Time) error
+	RevokeUserRefreshTokens(userID uint, revokedAt time.Time) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db}
+}
+
+// AddRefreshToken stores the refresh token under its digest only.
+func (r *refreshTokenRepository) AddRefreshToken(refreshToken model.RefreshToken) error {
+	refreshToken.TokenHash = utility.HashToken(refreshToken.Token)
+	return r.db.Create(&refreshToken).Error
+}
+
+func (r *refreshTokenRepository) GetRefreshToken(token string) (model.RefreshToken, error) {
+	var refreshToken model.RefreshToken
+	if err := r.db.Where("token_hash = ?", utility.HashToken(token)).First(&refreshToken).Error; err != nil {
+		return model.RefreshToken{}, err
+	}
+	return refreshToken, nil
+}
+
+// MarkRefreshTokenUsed flags the token as rotated. It reports false when the
+// token had already been used, so two concurrent refreshes can't both win.
+func (r *refreshTokenRepository) MarkRefreshTokenUsed(id uint, usedAt time.Time) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string, revokedAt time.Time) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", revokedAt).Error
+}
+
+func (r *refreshTokenRepository) RevokeUserRefreshTokens(userID uint, revokedAt time.Time) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error
+}
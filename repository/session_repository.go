@@ -2,18 +2,25 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
 	"gorm.io/gorm"
 )
 
 type SessionsRepository interface {
 	AddSessions(session model.Session) error
 	DeleteSession(token string) error
-	UpdateSessions(session model.Session) error
-	SessionAvailID(id uint) error
 	SessionAvailToken(token string) (model.Session, error)
 	GetUserIDByToken(token string) (uint, error)
+	ListUserSessions(userID uint) ([]model.Session, error)
+	GetUserSession(userID, sessionID uint) (model.Session, error)
+	DeleteUserSession(userID, sessionID uint) error
+	DeleteUserSessions(userID uint) error
+	DeleteSessionFamily(familyID string) error
+	TouchSession(sessionID uint, lastSeenAt, expiry time.Time) error
+	DeleteExpiredSessions(now time.Time, limit int) (int64, error)
 }
 
 type sessionsRepoImpl struct {
@@ -26,13 +33,16 @@ func NewSessionRepo(db *gorm.DB) SessionsRepository {
 
 func (s *sessionsRepoImpl) GetUserIDByToken(token string) (uint, error) {
 	var session model.Session
-	if err := s.db.Where("token = ?", token).First(&session).Error; err != nil {
+	if err := s.db.Where("token_hash = ?", utility.HashToken(token)).First(&session).Error; err != nil {
 		return 0, errors.New("invalid session token")
 	}
 	return session.UserID, nil
 }
 
+// AddSessions stores the session under the digest of its token; the raw
+// token itself is never persisted.
 func (s *sessionsRepoImpl) AddSessions(session model.Session) error {
+	session.TokenHash = utility.HashToken(session.Token)
 	result := s.db.Create(&session)
 	if result.Error != nil {
 		return result.Error
@@ -42,7 +52,7 @@ func (s *sessionsRepoImpl) AddSessions(session model.Session) error {
 }
 
 func (s *sessionsRepoImpl) DeleteSession(token string) error {
-	result := s.db.Where("token=?", token).Delete(&model.Session{})
+	result := s.db.Where("token_hash = ?", utility.HashToken(token)).Delete(&model.Session{})
 	if result.Error != nil {
 		return result.Error
 	}
@@ -50,33 +60,69 @@ func (s *sessionsRepoImpl) DeleteSession(token string) error {
 	return nil
 }
 
-func (s *sessionsRepoImpl) UpdateSessions(session model.Session) error {
-	result := s.db.Where("user_id=?", session.UserID).Updates(model.Session{Token: session.Token, Expiry: session.Expiry})
+func (s *sessionsRepoImpl) SessionAvailToken(token string) (model.Session, error) {
+	var session model.Session
+	result := s.db.Where(&model.Session{TokenHash: utility.HashToken(token)}).First(&session)
+
 	if result.Error != nil {
-		return result.Error
+		return model.Session{}, result.Error
 	}
 
-	return nil
+	return session, nil
 }
 
-func (s *sessionsRepoImpl) SessionAvailID(id uint) error {
+func (s *sessionsRepoImpl) ListUserSessions(userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := s.db.Where("user_id = ?", userID).Order("last_seen_at desc").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s *sessionsRepoImpl) GetUserSession(userID, sessionID uint) (model.Session, error) {
 	var session model.Session
-	result := s.db.Where(&model.Session{UserID: id}).First(&session)
+	if err := s.db.Where("user_id = ? AND id = ?", userID, sessionID).First(&session).Error; err != nil {
+		return model.Session{}, err
+	}
+	return session, nil
+}
 
+func (s *sessionsRepoImpl) DeleteUserSession(userID, sessionID uint) error {
+	result := s.db.Where("user_id = ? AND id = ?", userID, sessionID).Delete(&model.Session{})
 	if result.Error != nil {
 		return result.Error
 	}
 
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
 	return nil
 }
 
-func (s *sessionsRepoImpl) SessionAvailToken(token string) (model.Session, error) {
-	var session model.Session
-	result := s.db.Where(&model.Session{Token: token}).First(&session)
+func (s *sessionsRepoImpl) DeleteUserSessions(userID uint) error {
+	return s.db.Where("user_id = ?", userID).Delete(&model.Session{}).Error
+}
+
+func (s *sessionsRepoImpl) DeleteSessionFamily(familyID string) error {
+	return s.db.Where("family_id = ?", familyID).Delete(&model.Session{}).Error
+}
+
+func (s *sessionsRepoImpl) TouchSession(sessionID uint, lastSeenAt, expiry time.Time) error {
+	return s.db.Model(&model.Session{}).Where("id = ?", sessionID).Updates(map[string]any{
+		"last_seen_at": lastSeenAt,
+		"expiry":       expiry,
+	}).Error
+}
 
+// DeleteExpiredSessions permanently removes up to limit sessions whose expiry
+// is before now and reports how many rows were deleted. The idle expiry never
+// passes the absolute expiry, so checking it alone covers both.
+func (s *sessionsRepoImpl) DeleteExpiredSessions(now time.Time, limit int) (int64, error) {
+	expired := s.db.Unscoped().Model(&model.Session{}).Select("id").Where("expiry < ?", now).Limit(limit)
+	result := s.db.Unscoped().Where("id IN (?)", expired).Delete(&model.Session{})
 	if result.Error != nil {
-		return model.Session{}, result.Error
+		return 0, result.Error
 	}
-
-	return session, nil
+	return result.RowsAffected, nil
 }
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TableQACacheRepository interface {
+	GetTableQACacheEntry(key string, now time.Time) (model.TableQACacheEntry, error)
+	SaveTableQACacheEntry(entry *model.TableQACacheEntry) error
+	PruneTableQACache(now time.Time, maxEntries int) error
+}
+
+type tableQACacheRepository struct {
+	db *gorm.DB
+}
+
+func NewTableQACacheRepository(db *gorm.DB) TableQACacheRepository {
+	return &tableQACacheRepository{db}
+}
+
+// GetTableQACacheEntry returns the entry stored under key, unless it expired
+// before now.
+func (r *tableQACacheRepository) GetTableQACacheEntry(key string, now time.Time) (model.TableQACacheEntry, error) {
+	var entry model.TableQACacheEntry
+	if err := r.db.Where("key = ? AND expires_at > ?", key, now).First(&entry).Error; err != nil {
+		return model.TableQACacheEntry{}, err
+	}
+	return entry, nil
+}
+
+// SaveTableQACacheEntry inserts or overwrites the entry for entry.Key.
+func (r *tableQACacheRepository) SaveTableQACacheEntry(entry *model.TableQACacheEntry) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"response", "expires_at", "updated_at"}),
+	}).Create(entry).Error
+}
+
+// PruneTableQACache deletes expired entries, then the least recently
+// written ones beyond maxEntries.
+func (r *tableQACacheRepository) PruneTableQACache(now time.Time, maxEntries int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at <= ?", now).Delete(&model.TableQACacheEntry{}).Error; err != nil {
+			return err
+		}
+
+		newest := tx.Model(&model.TableQACacheEntry{}).Select("id").Order("updated_at desc").Limit(maxEntries)
+		return tx.Where("id NOT IN (?)", newest).Delete(&model.TableQACacheEntry{}).Error
+	})
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TariffRepository interface {
+	GetUserTariff(userID uint) (model.Tariff, error)
+	SaveUserTariff(tariff *model.Tariff) error
+	DeleteUserTariff(userID uint) error
+}
+
+type tariffRepository struct {
+	db *gorm.DB
+}
+
+func NewTariffRepository(db *gorm.DB) TariffRepository {
+	return &tariffRepository{db}
+}
+
+func (r *tariffRepository) GetUserTariff(userID uint) (model.Tariff, error) {
+	var tariff model.Tariff
+	if err := r.db.Where("user_id = ?", userID).First(&tariff).Error; err != nil {
+		return model.Tariff{}, err
+	}
+	return tariff, nil
+}
+
+// SaveUserTariff inserts or replaces the tariff of tariff.UserID.
+func (r *tariffRepository) SaveUserTariff(tariff *model.Tariff) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "currency", "rate", "tiers", "windows", "fixed_monthly_charge", "updated_at"}),
+	}).Create(tariff).Error
+}
+
+// DeleteUserTariff removes the row for good, so the user can save a new
+// tariff under the unique user_id index.
+func (r *tariffRepository) DeleteUserTariff(userID uint) error {
+	result := r.db.Unscoped().Where("user_id = ?", userID).Delete(&model.Tariff{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -1,15 +1,20 @@
 package repository
 
 import (
-	"errors"
-
 	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
 	Add(user model.User) error
-	Authenticate(username, password string) (model.User, error)
+	UsernameExists(username string) (bool, error)
+	GetByID(id uint) (model.User, error)
+	GetByUsername(username string) (model.User, error)
+	List() ([]model.User, error)
+	UpdateRole(id uint, role string) error
+	UpdateDisabled(id uint, disabled bool) error
+	UpdatePassword(id uint, hash string) error
 }
 
 type userRepository struct {
@@ -21,18 +26,55 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 }
 
 func (r *userRepository) Add(user model.User) error {
+	hash, err := utility.HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+
 	return r.db.Create(&user).Error
 }
 
-func (r *userRepository) Authenticate(username, password string) (model.User, error) {
+func (r *userRepository) UsernameExists(username string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) GetByID(id uint) (model.User, error) {
+	var user model.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (r *userRepository) GetByUsername(username string) (model.User, error) {
 	var user model.User
 	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
 		return model.User{}, err
 	}
+	return user, nil
+}
 
-	if user.Password != password {
-		return model.User{}, errors.New("invalid username or password")
+func (r *userRepository) List() ([]model.User, error) {
+	var users []model.User
+	if err := r.db.Order("id").Find(&users).Error; err != nil {
+		return nil, err
 	}
+	return users, nil
+}
 
-	return user, nil
+func (r *userRepository) UpdateRole(id uint, role string) error {
+	return r.db.Model(&model.User{}).Where("id = ?", id).Update("role", role).Error
+}
+
+func (r *userRepository) UpdateDisabled(id uint, disabled bool) error {
+	return r.db.Model(&model.User{}).Where("id = ?", id).Update("disabled", disabled).Error
+}
+
+func (r *userRepository) UpdatePassword(id uint, hash string) error {
+	return r.db.Model(&model.User{}).Where("id = ?", id).Update("password", hash).Error
 }
@@ -1,11 +1,14 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/utility"
@@ -16,167 +19,211 @@ type HTTPClient interface {
 }
 
 type AIService interface {
-	AnalyzeData(table map[string][]string, query, token string) (string, error)
-	AnalyzeFile(table map[string][]string, queries []string, token string) (string, error)
-	ChatWithAI(context, query, token string) (string, error)
+	AnalyzeData(ctx context.Context, table map[string][]string, checksum, query, engine string) (model.TableAnswer, model.AnalysisMeta, error)
+	AnalyzeFile(ctx context.Context, table map[string][]string, checksum string, insights []model.InsightTemplate, engine string) (model.FileAnalysis, model.AnalysisMeta, error)
+	ChatWithAI(ctx context.Context, history []model.Message, query, grounding string) (string, error)
+	StreamChatWithAI(ctx context.Context, history []model.Message, query, grounding string, onDelta func(delta string) error) (string, error)
+	CheckTableQAEngine(engine string) error
 }
 
-func NewAIService(client HTTPClient) AIService {
+// NewAIService creates an AIService. tableQAProviders maps engine names to
+// providers; defaultTableQA names the one used when a request doesn't pick.
+// A nil tableQACache disables caching.
+func NewAIService(chatProvider ChatProvider, tableQAProviders map[string]TableQAProvider, defaultTableQA string, tableQACache TableQACache) AIService {
 	return &aiService{
-		Client: client,
+		ChatProvider:     chatProvider,
+		TableQAProviders: tableQAProviders,
+		DefaultTableQA:   defaultTableQA,
+		TableQACache:     tableQACache,
 	}
 }
 
 type aiService struct {
-	Client HTTPClient
+	ChatProvider     ChatProvider
+	TableQAProviders map[string]TableQAProvider
+	DefaultTableQA   string
+	TableQACache     TableQACache
 }
 
-func (s *aiService) AnalyzeData(table map[string][]string, query, token string) (string, error) {
+// AnalyzeData answers query about table. checksum identifies the dataset's
+// content for the answer cache; an empty checksum bypasses the cache.
+func (s *aiService) AnalyzeData(ctx context.Context, table map[string][]string, checksum, query, engine string) (model.TableAnswer, model.AnalysisMeta, error) {
 	if len(table) == 0 {
-		return "", errors.New("table cannot be empty")
+		return model.TableAnswer{}, model.AnalysisMeta{}, errors.New("table cannot be empty")
 	}
 
-	url := "https://api-inference.huggingface.co/models/google/tapas-base-finetuned-wtq"
-	requestData := &model.TapasRequest{
-		Inputs: model.Inputs{
-			Table: table,
-			Query: query,
-		},
-	}
-
-	body, err := json.Marshal(*requestData)
+	provider, err := s.tableQAProvider(engine)
 	if err != nil {
-		return "", err
+		return model.TableAnswer{}, model.AnalysisMeta{}, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	meta := model.AnalysisMeta{Engine: provider.Name()}
+	tapasRes, err := s.answer(ctx, provider, table, checksum, query, &meta)
 	if err != nil {
-		return "", err
+		return model.TableAnswer{}, meta, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-wait-for-model", "true")
-
-	res, err := s.Client.Do(req)
+	answer, err := tableAnswer(table, tapasRes)
 	if err != nil {
-		return "", err
+		return model.TableAnswer{}, meta, err
 	}
+	return answer, meta, nil
+}
 
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return "", errors.New("failed to get a valid response from the AI model")
-	}
+// CheckTableQAEngine fails with ErrUnknownTableQAProvider when engine names
+// no configured provider. An empty engine picks the default one.
+func (s *aiService) CheckTableQAEngine(engine string) error {
+	_, err := s.tableQAProvider(engine)
+	return err
+}
 
-	var tapasRes model.TapasResponse
-	if err := json.NewDecoder(res.Body).Decode(&tapasRes); err != nil {
-		return "", err
+func (s *aiService) tableQAProvider(engine string) (TableQAProvider, error) {
+	if engine == "" {
+		engine = s.DefaultTableQA
 	}
-
-	processor := utility.TapasProcessor{
-		Cells: tapasRes.Cells,
+	provider, ok := s.TableQAProviders[engine]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTableQAProvider, engine)
 	}
-
-	var answer string
-
-	if tapasRes.Aggregator == "NONE" {
-		if len(tapasRes.Cells) == 1 {
-			answer = tapasRes.Answer
-		} else {
-			count, list := processor.CountUniqueCells()
-			answer = fmt.Sprintf("Count: %d, List: %v", count, list)
-		}
-	} else if tapasRes.Aggregator == "COUNT" {
-		count, list := processor.CountUniqueCells()
-		answer = fmt.Sprintf("Count: %d, List: %v", count, list)
-	} else if tapasRes.Aggregator == "SUM" {
-		sum := processor.Sum()
-		answer = fmt.Sprintf("Sum: %f", sum)
-	} else if tapasRes.Aggregator == "AVERAGE" {
-		avg := processor.Average()
-		answer = fmt.Sprintf("Sum: %f", avg)
-	} else if tapasRes.Aggregator == "MIN" {
-		min, _ := processor.Min()
-		answer = fmt.Sprintf("Min: %f", min)
-	} else if tapasRes.Aggregator == "MIN" {
-		max, _ := processor.Max()
-		answer = fmt.Sprintf("Max: %f", max)
-	}
-
-	return answer, nil
+	return provider, nil
 }
 
-func (s *aiService) AnalyzeFile(table map[string][]string, queries []string, token string) (string, error) {
-	results := make([]string, 0, len(queries))
+// answer asks provider, going through the cache when one is configured and
+// the dataset is known. Cache failures are logged and otherwise ignored.
+func (s *aiService) answer(ctx context.Context, provider TableQAProvider, table map[string][]string, checksum, query string, meta *model.AnalysisMeta) (model.TapasResponse, error) {
+	if s.TableQACache == nil || checksum == "" {
+		return provider.Answer(ctx, table, query)
+	}
 
-	for _, query := range queries {
-		result, err := s.AnalyzeData(table, query, token)
-		if err != nil {
-			return "", err
-		}
-		results = append(results, result)
+	key := tableQACacheKey(checksum, query, provider.Name())
+	cached, ok, err := s.TableQACache.Get(key)
+	if err != nil {
+		log.Printf("Table QA cache read error: %v", err)
+	}
+	if ok {
+		meta.CacheHits++
+		return cached, nil
 	}
+	meta.CacheMisses++
 
-	answer := fmt.Sprintf("From the provided data, here are the Least Electricity: %s and the Most Electricity: %s.", results[0], results[1])
+	tapasRes, err := provider.Answer(ctx, table, query)
+	if err != nil {
+		return model.TapasResponse{}, err
+	}
 
-	return answer, nil
+	if err := s.TableQACache.Set(key, tapasRes); err != nil {
+		log.Printf("Table QA cache write error: %v", err)
+	}
+	return tapasRes, nil
 }
 
-func (s *aiService) ChatWithAI(context, query, token string) (string, error) {
-	url := "https://api-inference.huggingface.co/models/microsoft/Phi-3.5-mini-instruct/v1/chat/completions"
+// tableAnswer applies the aggregator of tapasRes. Coordinates refer to the
+// table's columns in sorted order, as every provider sends them. The decimal
+// mark comes from the whole answer column, since the few selected cells are
+// often all ambiguous, like "1.234".
+func tableAnswer(table map[string][]string, tapasRes model.TapasResponse) (model.TableAnswer, error) {
+	processor := utility.TapasProcessor{
+		Cells:       tapasRes.Cells,
+		Coordinates: tapasRes.Coordinates,
+		Columns:     slices.Sorted(maps.Keys(table)),
+	}
+	if column := processor.Column(); column != "" {
+		processor.DecimalMark = utility.DetectDecimalMark(table[column])
+	}
 
-	var messages []model.Message
-	if context != "" {
-		messages = append(messages, model.Message{Role: "assistant", Content: context})
+	answer, err := processor.Aggregate(tapasRes.Aggregator)
+	switch {
+	case errors.Is(err, utility.ErrNoCells) || errors.Is(err, utility.ErrNoNumericValues):
+		return answer, fmt.Errorf("%w: %w", ErrUnansweredQuery, err)
+	case errors.Is(err, utility.ErrUnknownAggregator):
+		return answer, fmt.Errorf("%w: %w", ErrUpstream, err)
 	}
-	messages = append(messages, model.Message{Role: "user", Content: query})
+	return answer, err
+}
 
-	requestData := &model.PhiRequest{
-		Model: "microsoft/Phi-3.5-mini-instruct",
-		Messages: append([]model.Message{
-			{
-				Role:    "system",
-				Content: "You are an intelligent assistant designed to help users optimize energy consumption in their smart homes. You must respond clearly, concisely, and in a user-friendly manner. If the user asks for recommendations, base your advice on energy-saving strategies while considering the data insights.",
-			},
-		}, messages...),
-		Temperature: 0.2,
-		MaxTokens:   500,
-		Stream:      false,
+// AnalyzeFile asks every insight query about table. A query the data can't
+// answer is reported in its result; the analysis only fails with
+// ErrUnansweredQuery when none of them could be answered.
+func (s *aiService) AnalyzeFile(ctx context.Context, table map[string][]string, checksum string, insights []model.InsightTemplate, engine string) (model.FileAnalysis, model.AnalysisMeta, error) {
+	results := make([]model.InsightResult, 0, len(insights))
+	var answered []string
+
+	var meta model.AnalysisMeta
+	var unanswered error
+	for _, insight := range insights {
+		result, queryMeta, err := s.AnalyzeData(ctx, table, checksum, insight.Query, engine)
+		meta.Engine = queryMeta.Engine
+		meta.CacheHits += queryMeta.CacheHits
+		meta.CacheMisses += queryMeta.CacheMisses
+
+		switch {
+		case errors.Is(err, ErrUnansweredQuery):
+			unanswered = err
+			results = append(results, model.InsightResult{Label: insight.Label, Query: insight.Query, Error: err.Error()})
+		case err != nil:
+			return model.FileAnalysis{}, meta, err
+		default:
+			results = append(results, model.InsightResult{Label: insight.Label, Query: insight.Query, Result: &result})
+			answered = append(answered, fmt.Sprintf("the %s: %s", insight.Label, result.Text))
+		}
 	}
 
-	body, err := json.Marshal(*requestData)
-	if err != nil {
-		return "", err
+	if len(answered) == 0 && unanswered != nil {
+		return model.FileAnalysis{}, meta, unanswered
 	}
 
-	// log.Println("Request Body:", string(body))
+	return model.FileAnalysis{Answer: insightSentence(answered), Results: results}, meta, nil
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		return "", err
-	}
+// insightSentence joins the answered insights into one sentence, e.g.
+// "From the provided data, here are the Least Electricity: TV and the Most
+// Electricity: Heater."
+func insightSentence(answered []string) string {
+	switch len(answered) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("From the provided data, here is %s.", answered[0])
+	}
+	last := len(answered) - 1
+	return fmt.Sprintf("From the provided data, here are %s and %s.", strings.Join(answered[:last], ", "), answered[last])
+}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-wait-for-model", "true")
+// ChatWithAI asks the chat provider query, after the earlier turns in
+// history (see ChatService.GetConversation). A non-empty grounding, such as a
+// dataset summary, is added to the system prompt.
+func (s *aiService) ChatWithAI(ctx context.Context, history []model.Message, query, grounding string) (string, error) {
+	return s.ChatProvider.Complete(ctx, chatMessages(history, query, grounding))
+}
 
-	res, err := s.Client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
+// StreamChatWithAI is ChatWithAI with the reply streamed through onDelta. It
+// stops early when ctx is cancelled, e.g. because the client went away.
+func (s *aiService) StreamChatWithAI(ctx context.Context, history []model.Message, query, grounding string, onDelta func(delta string) error) (string, error) {
+	return s.ChatProvider.Stream(ctx, chatMessages(history, query, grounding), onDelta)
+}
 
-	if res.StatusCode != http.StatusOK {
-		return "", errors.New("failed to get a valid response from the AI model")
+// chatMessages builds the request for query. Strict chat templates only take
+// one system message, at the start, so grounding and any system turns in
+// history, like the summary of dropped turns, go into the system prompt.
+func chatMessages(history []model.Message, query, grounding string) []model.Message {
+	// A question stored before a failed attempt is being asked again
+	if n := len(history); n > 0 && history[n-1].Role == "user" && history[n-1].Content == query {
+		history = history[:n-1]
+	}
+
+	system := []string{"You are an intelligent assistant designed to help users optimize energy consumption in their smart homes. You must respond clearly, concisely, and in a user-friendly manner. If the user asks for recommendations, base your advice on energy-saving strategies while considering the data insights."}
+	turns := make([]model.Message, 0, len(history)+1)
+	for _, message := range history {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		turns = append(turns, message)
 	}
-
-	var phiResponse model.PhiResponse
-	if err := json.NewDecoder(res.Body).Decode(&phiResponse); err != nil {
-		return "", err
+	if grounding != "" {
+		system = append(system, grounding)
 	}
+	messages := mergeConsecutiveRoles(append(turns, model.Message{Role: "user", Content: query}))
 
-	answer := phiResponse.Choices[0].Message.Content
-
-	return answer, nil
+	return append([]model.Message{{Role: "system", Content: strings.Join(system, "\n\n")}}, messages...)
 }
@@ -2,9 +2,12 @@ package service_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -24,20 +27,29 @@ var _ = Describe("AIService", func() {
 	var (
 		mockClient *MockHTTPClient
 		aiService  service.AIService
-		token      string
 	)
 
 	BeforeEach(func() {
 		mockClient = &MockHTTPClient{}
-		aiService = service.NewAIService(mockClient)
-		token = "test-token"
+		chatProvider := service.NewHuggingFaceChatProvider(mockClient, model.ChatProviderConfig{
+			APIKey: "test-token",
+			Model:  "microsoft/Phi-3.5-mini-instruct",
+		})
+		tableQAProvider := service.NewHuggingFaceTableQAProvider(mockClient, model.TableQAConfig{
+			APIKey: "test-token",
+			Model:  "google/tapas-base-finetuned-wtq",
+		})
+		aiService = service.NewAIService(chatProvider, map[string]service.TableQAProvider{
+			"huggingface": tableQAProvider,
+			"local":       service.NewLocalTableQAProvider(),
+		}, "huggingface", nil)
 	})
 
 	Describe("AnalyzeData", func() {
 		It("should return an error if the table is empty", func() {
-			result, err := aiService.AnalyzeData(map[string][]string{}, "query", token)
+			result, _, err := aiService.AnalyzeData(context.Background(), map[string][]string{}, "", "query", "")
 			Expect(err).To(HaveOccurred())
-			Expect(result).To(BeEmpty())
+			Expect(result).To(BeZero())
 		})
 
 		It("should return a valid response for a valid request", func() {
@@ -55,9 +67,10 @@ var _ = Describe("AIService", func() {
 			}
 
 			table := map[string][]string{"column1": {"value1", "value2"}}
-			result, err := aiService.AnalyzeData(table, "query", token)
+			result, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(Equal("Count: 2, List: cell1, cell2"))
+			Expect(result.Text).To(Equal("cell1, cell2"))
+			Expect(result.Cells).To(Equal([]string{"cell1", "cell2"}))
 		})
 
 		It("should return an error if the API response is not OK", func() {
@@ -69,9 +82,144 @@ var _ = Describe("AIService", func() {
 			}
 
 			table := map[string][]string{"column1": {"value1", "value2"}}
-			result, err := aiService.AnalyzeData(table, "query", token)
-			Expect(err).To(HaveOccurred())
-			Expect(result).To(BeEmpty())
+			result, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).To(MatchError(service.ErrUpstream))
+			Expect(result).To(BeZero())
+		})
+
+		It("should report a model that is still loading", func() {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"error":"Model is currently loading","estimated_time":20}`)),
+				}, nil
+			}
+
+			table := map[string][]string{"column1": {"value1", "value2"}}
+			_, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).To(MatchError(service.ErrModelLoading))
+
+			var upstreamErr *service.UpstreamError
+			Expect(errors.As(err, &upstreamErr)).To(BeTrue())
+			Expect(upstreamErr.RetryAfter).To(Equal(20 * time.Second))
+		})
+	})
+
+	Describe("AnalyzeData aggregators", func() {
+		table := map[string][]string{
+			"Appliance":                {"TV", "Heater"},
+			"Energy_Consumption (kWh)": {"1.5", "4.0"},
+		}
+
+		respondWith := func(tapasRes model.TapasResponse) {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				responseBody, _ := json.Marshal(tapasRes)
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+				}, nil
+			}
+		}
+
+		It("should apply MAX with the unit of the selected column", func() {
+			respondWith(model.TapasResponse{
+				Cells:       []string{"1.5", "4.0"},
+				Coordinates: [][]int{{0, 1}, {1, 1}},
+				Aggregator:  "MAX",
+			})
+
+			result, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*result.Value).To(Equal(4.0))
+			Expect(result.Unit).To(Equal("kWh"))
+			Expect(result.Text).To(Equal("Max: 4 kWh"))
+		})
+
+		It("should read the selected cells with the decimal mark of their whole column", func() {
+			table := map[string][]string{
+				"Appliance":                {"TV", "Heater", "Lamp"},
+				"Energy_Consumption (kWh)": {"1.234", "2.500", "3,5"},
+			}
+			respondWith(model.TapasResponse{
+				Cells:       []string{"1.234", "2.500"},
+				Coordinates: [][]int{{0, 1}, {1, 1}},
+				Aggregator:  "SUM",
+			})
+
+			result, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*result.Value).To(Equal(3734.0))
+		})
+
+		It("should report an empty selection as unanswered", func() {
+			respondWith(model.TapasResponse{Aggregator: "NONE"})
+
+			_, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).To(MatchError(service.ErrUnansweredQuery))
+		})
+
+		It("should report a numeric aggregator over text as unanswered", func() {
+			respondWith(model.TapasResponse{Cells: []string{"TV"}, Coordinates: [][]int{{0, 0}}, Aggregator: "AVERAGE"})
+
+			_, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).To(MatchError(service.ErrUnansweredQuery))
+		})
+
+		It("should treat an unknown aggregator as an upstream error", func() {
+			respondWith(model.TapasResponse{Cells: []string{"1.5"}, Aggregator: "MEDIAN"})
+
+			_, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).To(MatchError(service.ErrUpstream))
+		})
+	})
+
+	Describe("AnalyzeData engine selection", func() {
+		It("should use the engine picked by the request", func() {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Fail("the local engine must not make HTTP requests")
+				return nil, nil
+			}
+
+			table := map[string][]string{"Appliance": {"Fridge", "Heater"}, "Energy": {"1.5", "3.0"}}
+			result, _, err := aiService.AnalyzeData(context.Background(), table, "", "Which appliance uses the most energy?", "local")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Text).To(Equal("Heater"))
+		})
+
+		It("should reject an unknown engine", func() {
+			table := map[string][]string{"column1": {"value1"}}
+			_, _, err := aiService.AnalyzeData(context.Background(), table, "", "query", "mystery")
+			Expect(err).To(MatchError(service.ErrUnknownTableQAProvider))
+		})
+	})
+
+	Describe("Context propagation", func() {
+		It("should send table QA requests with the caller's context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, req.Context().Err()
+			}
+
+			table := map[string][]string{"column1": {"value1"}}
+			_, _, err := aiService.AnalyzeData(ctx, table, "", "query", "")
+			Expect(err).To(MatchError(context.Canceled))
+		})
+
+		It("should give up when the provider's deadline passes", func() {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+
+			chatProvider := service.NewOpenAIChatProvider(mockClient, model.ChatProviderConfig{
+				BaseURL: "http://localhost:8080",
+				Timeout: 10 * time.Millisecond,
+			})
+			aiService = service.NewAIService(chatProvider, nil, "", nil)
+
+			_, err := aiService.ChatWithAI(context.Background(), nil, "query", "")
+			Expect(err).To(MatchError(context.DeadlineExceeded))
 		})
 	})
 
@@ -91,11 +239,62 @@ var _ = Describe("AIService", func() {
 			}
 
 			table := map[string][]string{"column1": {"value1", "value2"}}
-			queries := []string{"query1", "query2"}
-			result, err := aiService.AnalyzeFile(table, queries, token)
+			result, _, err := aiService.AnalyzeFile(context.Background(), table, "", service.DefaultInsightTemplates, "")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(ContainSubstring("Least Electricity"))
-			Expect(result).To(ContainSubstring("Most Electricity"))
+			Expect(result.Answer).To(Equal("From the provided data, here are the Least Electricity: cell1, cell2 and the Most Electricity: cell1, cell2."))
+			Expect(result.Results).To(HaveLen(2))
+			Expect(result.Results[0].Label).To(Equal("Least Electricity"))
+			Expect(result.Results[1].Query).To(Equal("Find the most electricity usage appliance."))
+		})
+
+		It("should answer any number of insights", func() {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var tapasReq model.TapasRequest
+				Expect(json.NewDecoder(req.Body).Decode(&tapasReq)).To(Succeed())
+				responseBody, _ := json.Marshal(model.TapasResponse{Cells: []string{tapasReq.Inputs.Query}, Aggregator: "NONE"})
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+				}, nil
+			}
+
+			insights := []model.InsightTemplate{
+				{Label: "Rooms", Query: "rooms"},
+				{Label: "Cheapest", Query: "cheapest"},
+				{Label: "Busiest", Query: "busiest"},
+			}
+			result, _, err := aiService.AnalyzeFile(context.Background(), map[string][]string{"Room": {"Kitchen"}}, "", insights, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Answer).To(Equal("From the provided data, here are the Rooms: rooms, the Cheapest: cheapest and the Busiest: busiest."))
+			Expect(result.Results).To(HaveLen(3))
+			Expect(result.Results[2].Result.Text).To(Equal("busiest"))
+		})
+
+		It("should report unanswerable insights alongside the others", func() {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				var tapasReq model.TapasRequest
+				Expect(json.NewDecoder(req.Body).Decode(&tapasReq)).To(Succeed())
+				response := model.TapasResponse{Cells: []string{"TV"}, Aggregator: "NONE"}
+				if tapasReq.Inputs.Query == "total" {
+					response.Aggregator = "SUM"
+				}
+				responseBody, _ := json.Marshal(response)
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+				}, nil
+			}
+
+			insights := []model.InsightTemplate{{Label: "Total", Query: "total"}, {Label: "Appliance", Query: "appliance"}}
+			result, _, err := aiService.AnalyzeFile(context.Background(), map[string][]string{"Appliance": {"TV"}}, "", insights, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Answer).To(Equal("From the provided data, here is the Appliance: TV."))
+			Expect(result.Results[0].Result).To(BeNil())
+			Expect(result.Results[0].Error).To(ContainSubstring("no numeric values found"))
+			Expect(result.Results[1].Result.Text).To(Equal("TV"))
+
+			_, _, err = aiService.AnalyzeFile(context.Background(), map[string][]string{"Appliance": {"TV"}}, "", insights[:1], "")
+			Expect(err).To(MatchError(service.ErrUnansweredQuery))
 		})
 	})
 
@@ -114,11 +313,39 @@ var _ = Describe("AIService", func() {
 				}, nil
 			}
 
-			result, err := aiService.ChatWithAI("context", "query", token)
+			result, err := aiService.ChatWithAI(context.Background(), []model.Message{{Role: "assistant", Content: "context"}}, "query", "")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(result).To(Equal("response"))
 		})
 
+		It("should send the grounding and summary in a single leading system message", func() {
+			var sent model.PhiRequest
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+				responseBody, _ := json.Marshal(model.PhiResponse{Choices: []model.Choice{{Message: model.Message{Content: "response"}}}})
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+				}, nil
+			}
+
+			history := []model.Message{
+				{Role: "system", Content: "Earlier in this conversation the user asked: hi"},
+				{Role: "user", Content: "How much did I use?"},
+				{Role: "assistant", Content: "12 kWh"},
+			}
+			_, err := aiService.ChatWithAI(context.Background(), history, "And the TV?", "The dataset has 3 appliances.")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sent.Messages).To(HaveLen(4))
+			Expect(sent.Messages[0].Role).To(Equal("system"))
+			Expect(sent.Messages[0].Content).To(ContainSubstring("Earlier in this conversation the user asked: hi"))
+			Expect(sent.Messages[0].Content).To(HaveSuffix("The dataset has 3 appliances."))
+			for i, message := range sent.Messages[1:] {
+				Expect(message.Role).To(Equal([]string{"user", "assistant", "user"}[i]))
+			}
+		})
+
 		It("should return an error if the API response is not OK", func() {
 			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
 				return &http.Response{
@@ -127,8 +354,8 @@ var _ = Describe("AIService", func() {
 				}, nil
 			}
 
-			result, err := aiService.ChatWithAI("context", "query", token)
-			Expect(err).To(HaveOccurred())
+			result, err := aiService.ChatWithAI(context.Background(), []model.Message{{Role: "assistant", Content: "context"}}, "query", "")
+			Expect(err).To(MatchError(service.ErrUpstream))
 			Expect(result).To(BeEmpty())
 		})
 	})
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix marks a bearer token as an API key rather than a session token.
+const APIKeyPrefix = "nrn_"
+
+const apiKeyDisplayPrefixLength = 12
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidScope   = errors.New("invalid scope")
+	ErrAPIKeyName     = errors.New("API key name is required")
+)
+
+type APIKeyService interface {
+	CreateAPIKey(userID uint, name string, scopes []string) (model.CreatedAPIKey, error)
+	ListAPIKeys(userID uint) ([]model.APIKey, error)
+	RevokeAPIKey(userID, keyID uint) error
+	RevokeAllAPIKeys(userID uint) error
+	Authenticate(rawKey string) (model.APIKey, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{apiKeyRepo}
+}
+
+func (s *apiKeyService) CreateAPIKey(userID uint, name string, scopes []string) (model.CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return model.CreatedAPIKey{}, ErrAPIKeyName
+	}
+
+	if len(scopes) == 0 {
+		return model.CreatedAPIKey{}, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !IsValidScope(scope) {
+			return model.CreatedAPIKey{}, ErrInvalidScope
+		}
+	}
+
+	token, err := utility.GenerateToken()
+	if err != nil {
+		return model.CreatedAPIKey{}, err
+	}
+	rawKey := APIKeyPrefix + token
+
+	apiKey := model.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  rawKey[:apiKeyDisplayPrefixLength],
+		KeyHash: utility.HashToken(rawKey),
+		Scopes:  scopes,
+	}
+	if err := s.apiKeyRepo.AddAPIKey(&apiKey); err != nil {
+		return model.CreatedAPIKey{}, err
+	}
+
+	return model.CreatedAPIKey{APIKey: apiKey, Key: rawKey}, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(userID uint) ([]model.APIKey, error) {
+	return s.apiKeyRepo.ListUserAPIKeys(userID)
+}
+
+func (s *apiKeyService) RevokeAPIKey(userID, keyID uint) error {
+	err := s.apiKeyRepo.RevokeUserAPIKey(userID, keyID, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+func (s *apiKeyService) RevokeAllAPIKeys(userID uint) error {
+	return s.apiKeyRepo.RevokeAllUserAPIKeys(userID, time.Now())
+}
+
+// Authenticate resolves a raw key to its active API key record.
+func (s *apiKeyService) Authenticate(rawKey string) (model.APIKey, error) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return model.APIKey{}, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.apiKeyRepo.GetAPIKeyByHash(utility.HashToken(rawKey))
+	if err != nil {
+		return model.APIKey{}, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastSeenResolution {
+		if err := s.apiKeyRepo.TouchAPIKey(apiKey.ID, now); err != nil {
+			return model.APIKey{}, err
+		}
+		apiKey.LastUsedAt = &now
+	}
+
+	return apiKey, nil
+}
+
+func IsValidScope(scope string) bool {
+	switch scope {
+	case model.ScopeUpload, model.ScopeChatRead, model.ScopeChatWrite:
+		return true
+	}
+	return false
+}
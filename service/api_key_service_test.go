@@ -0,0 +1,151 @@
+package service_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+	"gorm.io/gorm"
+)
+
+type MockAPIKeyRepository struct {
+	AddAPIKeyFunc            func(apiKey *model.APIKey) error
+	ListUserAPIKeysFunc      func(userID uint) ([]model.APIKey, error)
+	GetAPIKeyByHashFunc      func(keyHash string) (model.APIKey, error)
+	RevokeUserAPIKeyFunc     func(userID, keyID uint, revokedAt time.Time) error
+	RevokeAllUserAPIKeysFunc func(userID uint, revokedAt time.Time) error
+	TouchAPIKeyFunc          func(keyID uint, lastUsedAt time.Time) error
+}
+
+func (m *MockAPIKeyRepository) AddAPIKey(apiKey *model.APIKey) error {
+	return m.AddAPIKeyFunc(apiKey)
+}
+
+func (m *MockAPIKeyRepository) ListUserAPIKeys(userID uint) ([]model.APIKey, error) {
+	return m.ListUserAPIKeysFunc(userID)
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeyByHash(keyHash string) (model.APIKey, error) {
+	return m.GetAPIKeyByHashFunc(keyHash)
+}
+
+func (m *MockAPIKeyRepository) RevokeUserAPIKey(userID, keyID uint, revokedAt time.Time) error {
+	return m.RevokeUserAPIKeyFunc(userID, keyID, revokedAt)
+}
+
+func (m *MockAPIKeyRepository) RevokeAllUserAPIKeys(userID uint, revokedAt time.Time) error {
+	return m.RevokeAllUserAPIKeysFunc(userID, revokedAt)
+}
+
+func (m *MockAPIKeyRepository) TouchAPIKey(keyID uint, lastUsedAt time.Time) error {
+	return m.TouchAPIKeyFunc(keyID, lastUsedAt)
+}
+
+var _ = Describe("APIKeyService", func() {
+	var (
+		mockRepo      *MockAPIKeyRepository
+		apiKeyService service.APIKeyService
+	)
+
+	BeforeEach(func() {
+		mockRepo = &MockAPIKeyRepository{}
+		apiKeyService = service.NewAPIKeyService(mockRepo)
+	})
+
+	Describe("CreateAPIKey", func() {
+		It("should store only the hash of the generated key", func() {
+			var stored model.APIKey
+			mockRepo.AddAPIKeyFunc = func(apiKey *model.APIKey) error {
+				stored = *apiKey
+				return nil
+			}
+
+			created, err := apiKeyService.CreateAPIKey(1, "nightly upload", []string{model.ScopeUpload})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created.Key).To(HavePrefix(service.APIKeyPrefix))
+			Expect(stored.KeyHash).To(Equal(utility.HashToken(created.Key)))
+			Expect(strings.HasPrefix(created.Key, stored.Prefix)).To(BeTrue())
+			Expect(stored.UserID).To(Equal(uint(1)))
+		})
+
+		It("should reject unknown scopes", func() {
+			_, err := apiKeyService.CreateAPIKey(1, "script", []string{"admin"})
+			Expect(err).To(MatchError(service.ErrInvalidScope))
+		})
+
+		It("should require at least one scope", func() {
+			_, err := apiKeyService.CreateAPIKey(1, "script", nil)
+			Expect(err).To(MatchError(service.ErrInvalidScope))
+		})
+
+		It("should require a name", func() {
+			_, err := apiKeyService.CreateAPIKey(1, "  ", []string{model.ScopeUpload})
+			Expect(err).To(MatchError(service.ErrAPIKeyName))
+		})
+	})
+
+	Describe("Authenticate", func() {
+		It("should reject keys without the API key prefix", func() {
+			_, err := apiKeyService.Authenticate("some-session-token")
+			Expect(err).To(MatchError(service.ErrInvalidAPIKey))
+		})
+
+		It("should reject unknown or revoked keys", func() {
+			mockRepo.GetAPIKeyByHashFunc = func(keyHash string) (model.APIKey, error) {
+				return model.APIKey{}, gorm.ErrRecordNotFound
+			}
+
+			_, err := apiKeyService.Authenticate(service.APIKeyPrefix + "unknown")
+			Expect(err).To(MatchError(service.ErrInvalidAPIKey))
+		})
+
+		It("should look the key up by hash and record its use", func() {
+			rawKey := service.APIKeyPrefix + "abc"
+			mockRepo.GetAPIKeyByHashFunc = func(keyHash string) (model.APIKey, error) {
+				Expect(keyHash).To(Equal(utility.HashToken(rawKey)))
+				apiKey := model.APIKey{UserID: 7, Scopes: []string{model.ScopeChatRead}}
+				apiKey.ID = 3
+				return apiKey, nil
+			}
+			var touchedID uint
+			mockRepo.TouchAPIKeyFunc = func(keyID uint, lastUsedAt time.Time) error {
+				touchedID = keyID
+				return nil
+			}
+
+			apiKey, err := apiKeyService.Authenticate(rawKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apiKey.UserID).To(Equal(uint(7)))
+			Expect(touchedID).To(Equal(uint(3)))
+		})
+
+		It("should not touch a key used within the last minute", func() {
+			recently := time.Now().Add(-10 * time.Second)
+			mockRepo.GetAPIKeyByHashFunc = func(keyHash string) (model.APIKey, error) {
+				return model.APIKey{UserID: 7, LastUsedAt: &recently}, nil
+			}
+			mockRepo.TouchAPIKeyFunc = func(keyID uint, lastUsedAt time.Time) error {
+				Fail("TouchAPIKey should not be called")
+				return nil
+			}
+
+			_, err := apiKeyService.Authenticate(service.APIKeyPrefix + "abc")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("RevokeAPIKey", func() {
+		It("should return ErrAPIKeyNotFound for a missing key", func() {
+			mockRepo.RevokeUserAPIKeyFunc = func(userID, keyID uint, revokedAt time.Time) error {
+				return gorm.ErrRecordNotFound
+			}
+
+			err := apiKeyService.RevokeAPIKey(1, 99)
+			Expect(err).To(MatchError(service.ErrAPIKeyNotFound))
+		})
+	})
+})
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var ErrUnknownChatProvider = errors.New("unknown chat provider")
+
+// ChatProvider sends a conversation to an LLM and returns its reply.
+// Stream does the same but hands each piece of the reply to onDelta as it
+// arrives, and returns the assembled reply once the model is done.
+type ChatProvider interface {
+	Name() string
+	Complete(ctx context.Context, messages []model.Message) (string, error)
+	Stream(ctx context.Context, messages []model.Message, onDelta func(delta string) error) (string, error)
+}
+
+// NewChatProvider builds the provider named by config.Provider.
+func NewChatProvider(client HTTPClient, config model.ChatProviderConfig) (ChatProvider, error) {
+	switch config.Provider {
+	case "huggingface":
+		return NewHuggingFaceChatProvider(client, config), nil
+	case "openai":
+		if config.BaseURL == "" {
+			return nil, errors.New("the openai chat provider needs a base URL")
+		}
+		return NewOpenAIChatProvider(client, config), nil
+	case "fake":
+		return NewFakeChatProvider(), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownChatProvider, config.Provider)
+}
+
+// openAIChatProvider talks to any /v1/chat/completions endpoint.
+type openAIChatProvider struct {
+	client  HTTPClient
+	name    string
+	url     string
+	config  model.ChatProviderConfig
+	headers map[string]string
+}
+
+// NewHuggingFaceChatProvider uses the Hugging Face inference API, which
+// serves chat models through an OpenAI-compatible route.
+func NewHuggingFaceChatProvider(client HTTPClient, config model.ChatProviderConfig) ChatProvider {
+	return &openAIChatProvider{
+		client:  client,
+		name:    "huggingface:" + config.Model,
+		url:     "https://api-inference.huggingface.co/models/" + config.Model + "/v1/chat/completions",
+		config:  config,
+		headers: map[string]string{"x-wait-for-model": "true"},
+	}
+}
+
+// NewOpenAIChatProvider uses an OpenAI-compatible server at config.BaseURL,
+// such as a local llama.cpp or Ollama instance. The API key is optional.
+func NewOpenAIChatProvider(client HTTPClient, config model.ChatProviderConfig) ChatProvider {
+	return &openAIChatProvider{
+		client: client,
+		name:   "openai:" + config.Model,
+		url:    strings.TrimSuffix(config.BaseURL, "/") + "/v1/chat/completions",
+		config: config,
+	}
+}
+
+func (p *openAIChatProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIChatProvider) Complete(ctx context.Context, messages []model.Message) (string, error) {
+	ctx, cancel := withTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	res, err := p.send(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var phiResponse model.PhiResponse
+	if err := json.NewDecoder(res.Body).Decode(&phiResponse); err != nil {
+		return "", err
+	}
+
+	if len(phiResponse.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", p.name)
+	}
+
+	return phiResponse.Choices[0].Message.Content, nil
+}
+
+// Stream reads the completion as Server-Sent Events, one "data:" line per
+// chunk, until the "[DONE]" marker or the end of the body.
+func (p *openAIChatProvider) Stream(ctx context.Context, messages []model.Message, onDelta func(delta string) error) (string, error) {
+	ctx, cancel := withTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	res, err := p.send(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var answer strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk model.PhiStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", err
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		answer.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return answer.String(), nil
+}
+
+func (p *openAIChatProvider) send(ctx context.Context, messages []model.Message, stream bool) (*http.Response, error) {
+	requestData := model.PhiRequest{
+		Model:       p.config.Model,
+		Messages:    messages,
+		Temperature: p.config.Temperature,
+		MaxTokens:   p.config.MaxTokens,
+		Stream:      stream,
+	}
+
+	body, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %w", p.name, readUpstreamError(res))
+	}
+
+	return res, nil
+}
+
+// fakeChatProvider answers without any network access. The reply only
+// depends on the conversation, so it is safe to assert on in tests.
+type fakeChatProvider struct{}
+
+func NewFakeChatProvider() ChatProvider {
+	return fakeChatProvider{}
+}
+
+func (fakeChatProvider) Name() string {
+	return "fake"
+}
+
+func (fakeChatProvider) Complete(ctx context.Context, messages []model.Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var query string
+	for _, message := range messages {
+		if message.Role == "user" {
+			query = message.Content
+		}
+	}
+	return fmt.Sprintf("Fake reply to %q (%d messages in context)", query, len(messages)), nil
+}
+
+// Stream sends the Complete reply one word at a time.
+func (p fakeChatProvider) Stream(ctx context.Context, messages []model.Message, onDelta func(delta string) error) (string, error) {
+	answer, err := p.Complete(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	words := strings.SplitAfter(answer, " ")
+	for _, word := range words {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onDelta(word); err != nil {
+			return "", err
+		}
+	}
+
+	return answer, nil
+}
+
+// withTimeout bounds ctx by timeout, if one is configured.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+)
+
+var _ = Describe("ChatProvider", func() {
+	var mockClient *MockHTTPClient
+
+	BeforeEach(func() {
+		mockClient = &MockHTTPClient{}
+	})
+
+	respondWith := func(content string) func(req *http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			responseBody, _ := json.Marshal(model.PhiResponse{
+				Choices: []model.Choice{{Message: model.Message{Content: content}}},
+			})
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+			}, nil
+		}
+	}
+
+	Describe("NewChatProvider", func() {
+		It("should select the provider named in the config", func() {
+			provider, err := service.NewChatProvider(mockClient, model.ChatProviderConfig{Provider: "fake"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.Name()).To(Equal("fake"))
+
+			provider, err = service.NewChatProvider(mockClient, model.ChatProviderConfig{Provider: "openai", BaseURL: "http://localhost:8080", Model: "llama"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.Name()).To(Equal("openai:llama"))
+		})
+
+		It("should reject an unknown provider", func() {
+			_, err := service.NewChatProvider(mockClient, model.ChatProviderConfig{Provider: "mystery"})
+			Expect(err).To(MatchError(service.ErrUnknownChatProvider))
+		})
+
+		It("should require a base URL for the openai provider", func() {
+			_, err := service.NewChatProvider(mockClient, model.ChatProviderConfig{Provider: "openai"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("OpenAI-compatible provider", func() {
+		It("should post the conversation to the chat completions endpoint", func() {
+			var sent model.PhiRequest
+			var sentReq *http.Request
+			reply := respondWith("hello")
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				sentReq = req
+				Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+				return reply(req)
+			}
+
+			provider := service.NewOpenAIChatProvider(mockClient, model.ChatProviderConfig{
+				BaseURL:   "http://localhost:11434/",
+				Model:     "llama3",
+				MaxTokens: 100,
+			})
+			answer, err := provider.Complete(context.Background(), []model.Message{{Role: "user", Content: "hi"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(answer).To(Equal("hello"))
+
+			Expect(sentReq.URL.String()).To(Equal("http://localhost:11434/v1/chat/completions"))
+			Expect(sentReq.Header.Get("Authorization")).To(BeEmpty())
+			Expect(sent.Model).To(Equal("llama3"))
+			Expect(sent.MaxTokens).To(Equal(100))
+			Expect(sent.Messages).To(HaveLen(1))
+		})
+
+		It("should return an error when the response has no choices", func() {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(`{"choices":[]}`)),
+				}, nil
+			}
+
+			provider := service.NewOpenAIChatProvider(mockClient, model.ChatProviderConfig{BaseURL: "http://localhost:8080"})
+			_, err := provider.Complete(context.Background(), []model.Message{{Role: "user", Content: "hi"}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Streaming", func() {
+		It("should pass each delta on and return the assembled reply", func() {
+			var sent model.PhiRequest
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				Expect(json.NewDecoder(req.Body).Decode(&sent)).To(Succeed())
+				body := ": keep-alive\n\n" +
+					`data: {"choices":[{"delta":{"role":"assistant"}}]}` + "\n\n" +
+					`data: {"choices":[{"delta":{"content":"Turn off "}}]}` + "\n\n" +
+					`data: {"choices":[{"delta":{"content":"the heater."}}]}` + "\n\n" +
+					"data: [DONE]\n\n"
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(bytes.NewBufferString(body)),
+				}, nil
+			}
+
+			provider := service.NewOpenAIChatProvider(mockClient, model.ChatProviderConfig{BaseURL: "http://localhost:8080"})
+			var deltas []string
+			answer, err := provider.Stream(context.Background(), []model.Message{{Role: "user", Content: "hi"}}, func(delta string) error {
+				deltas = append(deltas, delta)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sent.Stream).To(BeTrue())
+			Expect(deltas).To(Equal([]string{"Turn off ", "the heater."}))
+			Expect(answer).To(Equal("Turn off the heater."))
+		})
+
+		It("should send the request with the caller's context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return nil, req.Context().Err()
+			}
+
+			provider := service.NewOpenAIChatProvider(mockClient, model.ChatProviderConfig{BaseURL: "http://localhost:8080"})
+			_, err := provider.Stream(ctx, []model.Message{{Role: "user", Content: "hi"}}, func(string) error { return nil })
+			Expect(err).To(MatchError(context.Canceled))
+		})
+
+		It("should stream the fake reply word by word", func() {
+			provider := service.NewFakeChatProvider()
+			messages := []model.Message{{Role: "user", Content: "hi"}}
+
+			var assembled string
+			answer, err := provider.Stream(context.Background(), messages, func(delta string) error {
+				assembled += delta
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(assembled).To(Equal(answer))
+
+			complete, _ := provider.Complete(context.Background(), messages)
+			Expect(answer).To(Equal(complete))
+		})
+	})
+
+	Describe("Hugging Face provider", func() {
+		It("should send the token and target the model's route", func() {
+			var sentReq *http.Request
+			reply := respondWith("hello")
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				sentReq = req
+				return reply(req)
+			}
+
+			provider := service.NewHuggingFaceChatProvider(mockClient, model.ChatProviderConfig{APIKey: "hf-token", Model: "org/model"})
+			_, err := provider.Complete(context.Background(), []model.Message{{Role: "user", Content: "hi"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sentReq.URL.String()).To(Equal("https://api-inference.huggingface.co/models/org/model/v1/chat/completions"))
+			Expect(sentReq.Header.Get("Authorization")).To(Equal("Bearer hf-token"))
+			Expect(sentReq.Header.Get("x-wait-for-model")).To(Equal("true"))
+		})
+	})
+
+	Describe("Fake provider", func() {
+		It("should answer deterministically", func() {
+			provider := service.NewFakeChatProvider()
+			messages := []model.Message{{Role: "system", Content: "be nice"}, {Role: "user", Content: "hi"}}
+
+			first, err := provider.Complete(context.Background(), messages)
+			Expect(err).NotTo(HaveOccurred())
+			second, _ := provider.Complete(context.Background(), messages)
+			Expect(first).To(Equal(second))
+			Expect(first).To(ContainSubstring(`"hi"`))
+		})
+	})
+})
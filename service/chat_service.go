@@ -15,14 +15,17 @@ type ChatService interface {
 	AddMessage(userID, chatID string, newMessage []map[string]any) error
 	GetChatUser(userID, chatID string) ([]map[string]any, error)
 	ListUserChats(userID string) ([]map[string]any, error)
+	AppendExchange(userID, chatID, query, answer string) error
+	GetConversation(userID, chatID string) ([]model.Message, error)
 }
 
 type chatService struct {
-	repo repository.ChatRepository
+	repo   repository.ChatRepository
+	config model.ConversationConfig
 }
 
-func NewChatService(repo repository.ChatRepository) ChatService {
-	return &chatService{repo: repo}
+func NewChatService(repo repository.ChatRepository, config model.ConversationConfig) ChatService {
+	return &chatService{repo: repo, config: config}
 }
 
 func (s *chatService) ListUserChats(userID string) ([]map[string]any, error) {
@@ -134,3 +137,51 @@ func (s *chatService) AddMessage(userID, chatID string, newMessage []map[string]
 	chat.ChatHistory = updatedChatHistory
 	return s.repo.UpdateChat(chat)
 }
+
+// AppendExchange adds a user question and the assistant's text answer to
+// the end of a chat, numbering them after the existing entries. A trailing
+// error entry is dropped first, since the exchange supersedes it, and so is
+// the question it failed on if the client is retrying it.
+func (s *chatService) AppendExchange(userID, chatID, query, answer string) error {
+	chat, err := s.repo.GetChatUser(userID, chatID)
+	if err != nil {
+		return errors.New("chat not found")
+	}
+
+	var chatHistory []map[string]any
+	if err := json.Unmarshal(chat.ChatHistory, &chatHistory); err != nil {
+		return err
+	}
+
+	if len(chatHistory) > 0 && chatHistory[len(chatHistory)-1]["type"] == "error" {
+		chatHistory = chatHistory[:len(chatHistory)-1]
+
+		if n := len(chatHistory); n > 0 && chatHistory[n-1]["role"] == "user" && chatHistory[n-1]["content"] == query {
+			chatHistory = chatHistory[:n-1]
+		}
+	}
+
+	chatHistory = append(chatHistory,
+		map[string]any{"id": len(chatHistory) + 1, "role": "user", "type": "text", "content": query},
+		map[string]any{"id": len(chatHistory) + 2, "role": "assistant", "type": "text", "content": answer},
+	)
+
+	updatedChatHistory, err := json.Marshal(chatHistory)
+	if err != nil {
+		return err
+	}
+
+	chat.ChatHistory = updatedChatHistory
+	return s.repo.UpdateChat(chat)
+}
+
+// GetConversation returns the chat's history as messages for the chat
+// provider, cut down to the configured token budget.
+func (s *chatService) GetConversation(userID, chatID string) ([]model.Message, error) {
+	chatHistory, err := s.GetChatUser(userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	return budgetConversation(historyMessages(chatHistory), s.config), nil
+}
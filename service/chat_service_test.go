@@ -3,6 +3,7 @@ package service_test
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -41,7 +42,7 @@ var _ = Describe("ChatService", func() {
 
 	BeforeEach(func() {
 		mockRepo = &MockChatRepository{}
-		chatService = service.NewChatService(mockRepo)
+		chatService = service.NewChatService(mockRepo, model.ConversationConfig{HistoryTokens: 40, SummaryTokens: 30})
 	})
 
 	Describe("CreateChat", func() {
@@ -93,6 +94,124 @@ var _ = Describe("ChatService", func() {
 		})
 	})
 
+	Describe("AppendExchange", func() {
+		It("should append the question and answer with the next ids", func() {
+			mockRepo.GetChatUserFunc = func(userID, chatID string) (*model.Chat, error) {
+				chatHistory, _ := json.Marshal([]map[string]any{
+					{"id": 1, "role": "assistant", "type": "text", "content": "Welcome"},
+					{"id": 2, "role": "user", "type": "text", "content": "Hello"},
+					{"id": 3, "role": "assistant", "type": "error", "content": "Error"},
+				})
+				return &model.Chat{UserID: userID, ChatHistory: chatHistory}, nil
+			}
+			var saved []model.ChatHistoryEntry
+			mockRepo.UpdateChatFunc = func(chat *model.Chat) error {
+				return json.Unmarshal(chat.ChatHistory, &saved)
+			}
+
+			err := chatService.AppendExchange("user1", "chat1", "How can I save energy?", "Turn off the heater.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved).To(HaveLen(4))
+			Expect(saved[2]).To(Equal(model.ChatHistoryEntry{ID: 3, Role: "user", Type: "text", Content: "How can I save energy?"}))
+			Expect(saved[3]).To(Equal(model.ChatHistoryEntry{ID: 4, Role: "assistant", Type: "text", Content: "Turn off the heater."}))
+		})
+
+		It("should return an error if chat is not found", func() {
+			mockRepo.GetChatUserFunc = func(userID, chatID string) (*model.Chat, error) {
+				return nil, errors.New("chat not found")
+			}
+
+			err := chatService.AppendExchange("user1", "chat1", "question", "answer")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not repeat a question that is being retried after an error", func() {
+			mockRepo.GetChatUserFunc = func(userID, chatID string) (*model.Chat, error) {
+				chatHistory, _ := json.Marshal([]map[string]any{
+					{"id": 1, "role": "assistant", "type": "text", "content": "Welcome"},
+					{"id": 2, "role": "user", "type": "text", "content": "Hello"},
+					{"id": 3, "role": "assistant", "type": "error", "content": "Error"},
+				})
+				return &model.Chat{UserID: userID, ChatHistory: chatHistory}, nil
+			}
+			var saved []model.ChatHistoryEntry
+			mockRepo.UpdateChatFunc = func(chat *model.Chat) error {
+				return json.Unmarshal(chat.ChatHistory, &saved)
+			}
+
+			err := chatService.AppendExchange("user1", "chat1", "Hello", "Hi there")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved).To(HaveLen(3))
+			Expect(saved[1].Content).To(Equal("Hello"))
+			Expect(saved[2].Content).To(Equal("Hi there"))
+		})
+	})
+
+	Describe("GetConversation", func() {
+		storeHistory := func(chatHistory []map[string]any) {
+			mockRepo.GetChatUserFunc = func(userID, chatID string) (*model.Chat, error) {
+				encoded, _ := json.Marshal(chatHistory)
+				return &model.Chat{UserID: userID, ChatHistory: encoded}, nil
+			}
+		}
+
+		It("should build alternating user and assistant messages from the text entries", func() {
+			storeHistory([]map[string]any{
+				{"id": 1, "role": "assistant", "type": "text", "content": "Hello, how can I help you?"},
+				{"id": 2, "role": "user", "type": "file", "content": map[string]any{"name": "usage.csv", "size": 120}},
+				{"id": 3, "role": "assistant", "type": "text", "content": "Heater uses most."},
+				{"id": 4, "role": "user", "type": "text", "content": "Why?"},
+				{"id": 5, "role": "assistant", "type": "error", "content": "Error: failed"},
+				{"id": 6, "role": "user", "type": "text", "content": "Why is that?"},
+			})
+
+			messages, err := chatService.GetConversation("user1", "chat1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages).To(Equal([]model.Message{
+				{Role: "user", Content: "I uploaded the file usage.csv."},
+				{Role: "assistant", Content: "Heater uses most."},
+				{Role: "user", Content: "Why?\n\nWhy is that?"},
+			}))
+		})
+
+		It("should summarize the turns that don't fit in the budget", func() {
+			storeHistory([]map[string]any{
+				{"id": 1, "role": "user", "type": "text", "content": "How much did the fridge use?"},
+				{"id": 2, "role": "assistant", "type": "text", "content": strings.Repeat("The fridge used a lot. ", 5)},
+				{"id": 3, "role": "user", "type": "text", "content": "And the heater?"},
+				{"id": 4, "role": "assistant", "type": "text", "content": strings.Repeat("The heater used more. ", 5)},
+				{"id": 5, "role": "user", "type": "text", "content": "Thanks"},
+				{"id": 6, "role": "assistant", "type": "text", "content": "You're welcome."},
+			})
+
+			messages, err := chatService.GetConversation("user1", "chat1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages).To(HaveLen(5))
+			Expect(messages[0]).To(Equal(model.Message{
+				Role:    "system",
+				Content: "Earlier in this conversation the user asked: How much did the fridge use?",
+			}))
+			Expect(messages[1]).To(Equal(model.Message{Role: "user", Content: "And the heater?"}))
+			Expect(messages[2].Content).To(HaveSuffix("[truncated]"))
+			Expect(messages[3:]).To(Equal([]model.Message{
+				{Role: "user", Content: "Thanks"},
+				{Role: "assistant", Content: "You're welcome."},
+			}))
+		})
+
+		It("should cut down a single message larger than the budget", func() {
+			storeHistory([]map[string]any{
+				{"id": 1, "role": "user", "type": "text", "content": strings.Repeat("word ", 100)},
+			})
+
+			messages, err := chatService.GetConversation("user1", "chat1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0].Content).To(HaveSuffix("[truncated]"))
+			Expect(len(messages[0].Content)).To(BeNumerically("<=", 80))
+		})
+	})
+
 	Describe("GetChatUser", func() {
 		It("should return chat history for a user", func() {
 			mockRepo.GetChatUserFunc = func(userID, chatID string) (*model.Chat, error) {
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var ErrCircuitOpen = errors.New("the AI model is temporarily unavailable")
+
+// CircuitBreaker is an HTTPClient that stops calling a model endpoint once
+// too many of its recent calls failed, so requests fail fast instead of
+// waiting on a degraded server. Endpoints are told apart by host and path.
+type CircuitBreaker interface {
+	HTTPClient
+	Status() []model.CircuitStatus
+}
+
+type circuitBreaker struct {
+	client HTTPClient
+	config model.CircuitBreakerConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    string
+	outcomes []callOutcome // closed state only, oldest first
+	openedAt time.Time
+	probing  bool
+}
+
+type callOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// NewCircuitBreaker wraps client with a circuit per endpoint. now is the
+// clock used for all decisions; pass nil to use time.Now.
+func NewCircuitBreaker(client HTTPClient, config model.CircuitBreakerConfig, now func() time.Time) CircuitBreaker {
+	if now == nil {
+		now = time.Now
+	}
+	return &circuitBreaker{client: client, config: config, now: now, circuits: map[string]*circuit{}}
+}
+
+// Do returns an *UpstreamError wrapping ErrCircuitOpen without calling the
+// endpoint while its circuit is open. Errors and 5xx responses count as
+// failures; a caller cancelling the request counts as neither outcome.
+func (b *circuitBreaker) Do(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Host + req.URL.Path
+	if err := b.acquire(endpoint); err != nil {
+		return nil, err
+	}
+
+	res, err := b.client.Do(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		b.release(endpoint)
+	case err != nil:
+		b.record(endpoint, true)
+	default:
+		b.record(endpoint, res.StatusCode >= http.StatusInternalServerError)
+	}
+	return res, err
+}
+
+func (b *circuitBreaker) acquire(endpoint string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(endpoint)
+	now := b.now()
+
+	switch c.state {
+	case model.CircuitOpen:
+		retryAt := c.openedAt.Add(b.config.CoolDown)
+		if now.Before(retryAt) {
+			return &UpstreamError{Err: ErrCircuitOpen, Message: endpoint, RetryAfter: retryAt.Sub(now)}
+		}
+		c.state = model.CircuitHalfOpen
+		c.probing = true
+	case model.CircuitHalfOpen:
+		// Only one probe at a time decides whether the endpoint recovered
+		if c.probing {
+			return &UpstreamError{Err: ErrCircuitOpen, Message: endpoint}
+		}
+		c.probing = true
+	}
+	return nil
+}
+
+func (b *circuitBreaker) release(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.circuit(endpoint).probing = false
+}
+
+func (b *circuitBreaker) record(endpoint string, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(endpoint)
+	now := b.now()
+
+	if c.state == model.CircuitHalfOpen {
+		c.probing = false
+		if failed {
+			c.state, c.openedAt = model.CircuitOpen, now
+			log.Printf("Circuit for %s reopened after a failed probe", endpoint)
+		} else {
+			c.state, c.outcomes = model.CircuitClosed, nil
+			log.Printf("Circuit for %s closed", endpoint)
+		}
+		return
+	}
+	if c.state != model.CircuitClosed {
+		return
+	}
+
+	c.outcomes = append(b.recent(c, now), callOutcome{now, failed})
+
+	requests, failures := countOutcomes(c.outcomes)
+	if requests >= b.config.MinRequests && float64(failures)/float64(requests) >= b.config.FailureRate {
+		c.state, c.openedAt, c.outcomes = model.CircuitOpen, now, nil
+		log.Printf("Circuit for %s opened: %d of %d calls failed", endpoint, failures, requests)
+	}
+}
+
+func (b *circuitBreaker) Status() []model.CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	statuses := make([]model.CircuitStatus, 0, len(b.circuits))
+	for endpoint, c := range b.circuits {
+		c.outcomes = b.recent(c, now)
+		requests, failures := countOutcomes(c.outcomes)
+
+		status := model.CircuitStatus{
+			Endpoint: endpoint,
+			State:    c.state,
+			Requests: requests,
+			Failures: failures,
+		}
+		if requests > 0 {
+			status.FailureRate = float64(failures) / float64(requests)
+		}
+		if c.state != model.CircuitClosed {
+			openedAt, retryAt := c.openedAt, c.openedAt.Add(b.config.CoolDown)
+			status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	slices.SortFunc(statuses, func(a, b model.CircuitStatus) int {
+		return strings.Compare(a.Endpoint, b.Endpoint)
+	})
+	return statuses
+}
+
+func (b *circuitBreaker) circuit(endpoint string) *circuit {
+	c, ok := b.circuits[endpoint]
+	if !ok {
+		c = &circuit{state: model.CircuitClosed}
+		b.circuits[endpoint] = c
+	}
+	return c
+}
+
+// recent drops the outcomes that fell out of the window.
+func (b *circuitBreaker) recent(c *circuit, now time.Time) []callOutcome {
+	cutoff := now.Add(-b.config.Window)
+	i := 0
+	for i < len(c.outcomes) && !c.outcomes[i].at.After(cutoff) {
+		i++
+	}
+	return c.outcomes[i:]
+}
+
+func countOutcomes(outcomes []callOutcome) (requests, failures int) {
+	for _, outcome := range outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+	return len(outcomes), failures
+}
@@ -0,0 +1,170 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		mockClient *MockHTTPClient
+		breaker    service.CircuitBreaker
+		now        time.Time
+		statusCode int
+		calls      int
+	)
+
+	call := func(ctx context.Context, url string) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+		Expect(err).NotTo(HaveOccurred())
+		res, err := breaker.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	tableQA := "https://api-inference.huggingface.co/models/google/tapas-base-finetuned-wtq"
+	chat := "https://api-inference.huggingface.co/models/microsoft/Phi-3.5-mini-instruct/v1/chat/completions"
+
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		statusCode = http.StatusOK
+		calls = 0
+		mockClient = &MockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				calls++
+				if statusCode == 0 {
+					return nil, errors.New("connection refused")
+				}
+				return &http.Response{StatusCode: statusCode, Body: io.NopCloser(strings.NewReader(""))}, nil
+			},
+		}
+		breaker = service.NewCircuitBreaker(mockClient, model.CircuitBreakerConfig{
+			Window:      time.Minute,
+			MinRequests: 4,
+			FailureRate: 0.5,
+			CoolDown:    30 * time.Second,
+		}, func() time.Time { return now })
+	})
+
+	openCircuit := func() {
+		statusCode = http.StatusBadGateway
+		for range 4 {
+			Expect(call(context.Background(), tableQA)).To(Succeed())
+		}
+	}
+
+	It("should stay closed while failures are below the rate", func() {
+		for _, code := range []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusInternalServerError} {
+			statusCode = code
+			Expect(call(context.Background(), tableQA)).To(Succeed())
+		}
+
+		status := breaker.Status()
+		Expect(status).To(HaveLen(1))
+		Expect(status[0].State).To(Equal(model.CircuitClosed))
+		Expect(status[0].Requests).To(Equal(4))
+		Expect(status[0].Failures).To(Equal(1))
+		Expect(status[0].FailureRate).To(Equal(0.25))
+	})
+
+	It("should open and fail fast once the failure rate is reached", func() {
+		openCircuit()
+
+		now = now.Add(10 * time.Second)
+		err := call(context.Background(), tableQA)
+		Expect(err).To(MatchError(service.ErrCircuitOpen))
+		Expect(calls).To(Equal(4))
+
+		var upstreamErr *service.UpstreamError
+		Expect(errors.As(err, &upstreamErr)).To(BeTrue())
+		Expect(upstreamErr.RetryAfter).To(Equal(20 * time.Second))
+
+		status := breaker.Status()
+		Expect(status).To(HaveLen(1))
+		Expect(status[0].State).To(Equal(model.CircuitOpen))
+		Expect(*status[0].RetryAt).To(Equal(now.Add(20 * time.Second)))
+	})
+
+	It("should keep a circuit per endpoint", func() {
+		openCircuit()
+
+		Expect(call(context.Background(), tableQA)).To(MatchError(service.ErrCircuitOpen))
+		Expect(call(context.Background(), chat)).To(Succeed())
+	})
+
+	It("should count transport errors as failures", func() {
+		statusCode = 0
+		for range 4 {
+			Expect(call(context.Background(), tableQA)).NotTo(MatchError(service.ErrCircuitOpen))
+		}
+
+		Expect(call(context.Background(), tableQA)).To(MatchError(service.ErrCircuitOpen))
+	})
+
+	It("should forget failures that left the window", func() {
+		statusCode = http.StatusBadGateway
+		for range 3 {
+			Expect(call(context.Background(), tableQA)).To(Succeed())
+		}
+
+		now = now.Add(2 * time.Minute)
+		Expect(call(context.Background(), tableQA)).To(Succeed())
+		Expect(breaker.Status()[0].State).To(Equal(model.CircuitClosed))
+	})
+
+	It("should close again after a successful probe", func() {
+		openCircuit()
+
+		now = now.Add(30 * time.Second)
+		statusCode = http.StatusOK
+		Expect(call(context.Background(), tableQA)).To(Succeed())
+		Expect(breaker.Status()[0].State).To(Equal(model.CircuitClosed))
+		Expect(call(context.Background(), tableQA)).To(Succeed())
+	})
+
+	It("should reopen after a failed probe", func() {
+		openCircuit()
+
+		now = now.Add(30 * time.Second)
+		Expect(call(context.Background(), tableQA)).To(Succeed())
+		Expect(breaker.Status()[0].State).To(Equal(model.CircuitOpen))
+		Expect(call(context.Background(), tableQA)).To(MatchError(service.ErrCircuitOpen))
+	})
+
+	It("should let only one probe through at a time", func() {
+		openCircuit()
+		now = now.Add(30 * time.Second)
+
+		mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+			// A second request arrives while the probe is in flight
+			Expect(call(context.Background(), tableQA)).To(MatchError(service.ErrCircuitOpen))
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		Expect(call(context.Background(), tableQA)).To(Succeed())
+		Expect(breaker.Status()[0].State).To(Equal(model.CircuitClosed))
+	})
+
+	It("should not count requests the caller cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+			cancel()
+			return nil, req.Context().Err()
+		}
+		for range 4 {
+			Expect(call(ctx, tableQA)).To(MatchError(context.Canceled))
+		}
+
+		Expect(breaker.Status()[0].Requests).To(BeZero())
+	})
+})
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const truncatedMarker = " [truncated]"
+
+// historyMessages turns a stored chat history into model messages. Only text
+// entries from the user and the assistant are kept; an uploaded file becomes
+// a short user message so the assistant's summary of it still has a prompt.
+func historyMessages(chatHistory []map[string]any) []model.Message {
+	var messages []model.Message
+	for _, entry := range chatHistory {
+		role, _ := entry["role"].(string)
+		if role != "user" && role != "assistant" {
+			continue
+		}
+
+		switch entry["type"] {
+		case "text":
+			if content, ok := entry["content"].(string); ok && strings.TrimSpace(content) != "" {
+				messages = append(messages, model.Message{Role: role, Content: content})
+			}
+		case "file":
+			if file, ok := entry["content"].(map[string]any); ok {
+				messages = append(messages, model.Message{Role: role, Content: fmt.Sprintf("I uploaded the file %v.", file["name"])})
+			}
+		}
+	}
+	return messages
+}
+
+// budgetConversation fits messages into historyTokens. The newest turns are
+// kept whole (a single oversized message is cut down), consecutive messages
+// from the same role are merged so the result alternates and starts with the
+// user, and any dropped turns are replaced by a system message summarizing
+// the questions asked in them, within summaryTokens.
+func budgetConversation(messages []model.Message, config model.ConversationConfig) []model.Message {
+	messages = mergeConsecutiveRoles(messages)
+
+	maxMessageTokens := config.HistoryTokens / 2
+	start, used := len(messages), 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		content := truncateTokens(messages[i].Content, maxMessageTokens)
+		tokens := estimateTokens(content)
+		if used+tokens > config.HistoryTokens {
+			break
+		}
+		messages[i].Content = content
+		used += tokens
+		start = i
+	}
+
+	// The kept window has to open with a user turn
+	for start < len(messages) && messages[start].Role != "user" {
+		start++
+	}
+
+	kept := messages[start:]
+	if start == 0 {
+		return kept
+	}
+
+	summary := summarizeTurns(messages[:start], config.SummaryTokens)
+	if summary == "" {
+		return kept
+	}
+	return append([]model.Message{{Role: "system", Content: summary}}, kept...)
+}
+
+func mergeConsecutiveRoles(messages []model.Message) []model.Message {
+	var merged []model.Message
+	for _, message := range messages {
+		if n := len(merged); n > 0 && merged[n-1].Role == message.Role {
+			merged[n-1].Content += "\n\n" + message.Content
+			continue
+		}
+		merged = append(merged, message)
+	}
+	return merged
+}
+
+// summarizeTurns lists the most recent user questions from dropped turns
+// that fit in budget tokens, oldest first.
+func summarizeTurns(messages []model.Message, budget int) string {
+	const prefix = "Earlier in this conversation the user asked: "
+
+	var questions []string
+	used := estimateTokens(prefix)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		question := truncateTokens(strings.Join(strings.Fields(messages[i].Content), " "), 40)
+		tokens := estimateTokens(question) + 1
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		questions = append([]string{question}, questions...)
+	}
+
+	if len(questions) == 0 {
+		return ""
+	}
+	return prefix + strings.Join(questions, "; ")
+}
+
+// estimateTokens approximates a token count at four characters per token,
+// which is close enough for budgeting across the models we use.
+func estimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+func truncateTokens(s string, tokens int) string {
+	if estimateTokens(s) <= tokens {
+		return s
+	}
+
+	runes := []rune(s)
+	limit := max(0, tokens*4-utf8.RuneCountInString(truncatedMarker))
+	return string(runes[:min(limit, len(runes))]) + truncatedMarker
+}
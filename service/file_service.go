@@ -1,77 +1,169 @@
 package service
 
 import (
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/repository"
 	"github.com/z4fL/fp-ai-golang-neurons/utility/projectpath"
+	"gorm.io/gorm"
 )
 
+var ErrDatasetNotFound = errors.New("dataset not found")
+
 type FileService interface {
-	ProcessFile(fileContent string) (map[string][]string, error)
+	ProcessFile(userID uint, filename, fileContent string) (*model.Dataset, map[string][]string, error)
+	LoadDataset(userID, datasetID uint) (*model.Dataset, map[string][]string, error)
+	GetDataset(userID, datasetID uint) (*model.Dataset, error)
+	ListDatasets(userID uint) ([]model.Dataset, error)
+	DeleteDataset(userID, datasetID uint) error
 	ParseCSV(fileContent string) (map[string][]string, error)
 	GetRepo() repository.FileRepository
 }
 
 type fileService struct {
-	repo repository.FileRepository
+	repo        repository.FileRepository
+	datasetRepo repository.DatasetRepository
 }
 
-func NewFileService(repo repository.FileRepository) FileService {
-	return &fileService{repo}
+func NewFileService(repo repository.FileRepository, datasetRepo repository.DatasetRepository) FileService {
+	return &fileService{repo, datasetRepo}
 }
 
-func (s *fileService) ProcessFile(fileContent string) (map[string][]string, error) {
+// ProcessFile stores the uploaded CSV as a dataset owned by userID. Uploading
+// content identical to one of the user's existing datasets reuses that dataset.
+func (s *fileService) ProcessFile(userID uint, filename, fileContent string) (*model.Dataset, map[string][]string, error) {
 	if strings.TrimSpace(fileContent) == "" {
-		return nil, errors.New("file content is empty")
+		return nil, nil, errors.New("file content is empty")
 	}
 
-	dir := filepath.Join(projectpath.Root, "upload")
-	if !s.repo.DirExists(dir) {
-		if err := s.repo.MakeDir(dir); err != nil {
-			return nil, err
+	parsedData, err := s.ParseCSV(fileContent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing CSV: %v", err)
+	}
+
+	checksum := datasetChecksum(fileContent)
+
+	// An existing dataset whose file went missing gets its file back rather
+	// than a second row sharing the same storage key
+	existing, err := s.datasetRepo.GetUserDatasetByChecksum(userID, checksum)
+	switch {
+	case err == nil:
+		if !s.repo.FileExists(datasetPath(existing.StorageKey)) {
+			if err := s.saveDatasetFile(existing.StorageKey, fileContent); err != nil {
+				return nil, nil, err
+			}
 		}
+		return existing, parsedData, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil, err
 	}
 
-	filename := "data-series.csv"
-	filePath := dir + "/" + filename
+	storageKey := filepath.Join(strconv.FormatUint(uint64(userID), 10), checksum+".csv")
+	if err := s.saveDatasetFile(storageKey, fileContent); err != nil {
+		return nil, nil, err
+	}
 
-	var contentFile string
+	dataset := &model.Dataset{
+		UserID:     userID,
+		Filename:   filepath.Base(filename),
+		Checksum:   checksum,
+		StorageKey: storageKey,
+		Rows:       rowCount(parsedData),
+		Columns:    len(parsedData),
+	}
 
-	if s.repo.FileExists(filePath) {
-		existingContent, err := s.repo.ReadFile(filePath)
-		if err != nil {
-			return nil, fmt.Errorf("error reading file: %v", err)
-		}
+	// A concurrent upload of the same content may have recorded it first
+	createdDataset, err := s.datasetRepo.AddDataset(dataset)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		createdDataset, err = s.datasetRepo.GetUserDatasetByChecksum(userID, checksum)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if strings.TrimSpace(fileContent) == strings.TrimSpace(string(existingContent)) {
-			contentFile = string(existingContent)
-		} else {
-			contentFile = fileContent
-			err := s.repo.SaveFile(filePath, []byte(fileContent))
-			if err != nil {
-				return nil, fmt.Errorf("failed to save file")
-			}
-		}
-	} else {
-		err := s.repo.SaveFile(filePath, []byte(fileContent))
-		if err != nil {
-			return nil, fmt.Errorf("failed to save file")
+	return createdDataset, parsedData, nil
+}
+
+// saveDatasetFile writes the CSV content under storageKey, creating the
+// user's upload directory if needed.
+func (s *fileService) saveDatasetFile(storageKey, fileContent string) error {
+	path := datasetPath(storageKey)
+	if dir := filepath.Dir(path); !s.repo.DirExists(dir) {
+		if err := s.repo.MakeDir(dir); err != nil {
+			return err
 		}
+	}
 
-		contentFile = fileContent
+	if err := s.repo.SaveFile(path, []byte(fileContent)); err != nil {
+		return fmt.Errorf("failed to save file")
 	}
+	return nil
+}
 
-	parsedData, err := s.ParseCSV(contentFile)
+// LoadDataset reads and parses the CSV content of a dataset owned by userID.
+func (s *fileService) LoadDataset(userID, datasetID uint) (*model.Dataset, map[string][]string, error) {
+	dataset, err := s.GetDataset(userID, datasetID)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing CSV: %v", err)
+		return nil, nil, err
 	}
 
-	return parsedData, nil
+	path := datasetPath(dataset.StorageKey)
+	if !s.repo.FileExists(path) {
+		return nil, nil, ErrDatasetNotFound
+	}
+
+	contentFile, err := s.repo.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	parsedData, err := s.ParseCSV(string(contentFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing CSV: %v", err)
+	}
+
+	return dataset, parsedData, nil
+}
+
+func (s *fileService) GetDataset(userID, datasetID uint) (*model.Dataset, error) {
+	dataset, err := s.datasetRepo.GetUserDataset(userID, datasetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDatasetNotFound
+		}
+		return nil, err
+	}
+	return dataset, nil
+}
+
+func (s *fileService) ListDatasets(userID uint) ([]model.Dataset, error) {
+	return s.datasetRepo.ListUserDatasets(userID)
+}
+
+func (s *fileService) DeleteDataset(userID, datasetID uint) error {
+	dataset, err := s.GetDataset(userID, datasetID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.datasetRepo.DeleteDataset(dataset); err != nil {
+		return err
+	}
+
+	path := datasetPath(dataset.StorageKey)
+	if s.repo.FileExists(path) {
+		return s.repo.RemoveFile(path)
+	}
+
+	return nil
 }
 
 func (s *fileService) ParseCSV(fileContent string) (map[string][]string, error) {
@@ -105,3 +197,19 @@ func (s *fileService) ParseCSV(fileContent string) (map[string][]string, error)
 func (s *fileService) GetRepo() repository.FileRepository {
 	return s.repo
 }
+
+func datasetChecksum(fileContent string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(fileContent)))
+	return hex.EncodeToString(sum[:])
+}
+
+func datasetPath(storageKey string) string {
+	return filepath.Join(projectpath.Root, "upload", storageKey)
+}
+
+func rowCount(table map[string][]string) int {
+	for _, column := range table {
+		return len(column)
+	}
+	return 0
+}
@@ -5,7 +5,9 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"gorm.io/gorm"
 )
 
 type MockFileRepository struct {
@@ -41,20 +43,58 @@ func (m *MockFileRepository) SaveFile(path string, content []byte) error {
 	return m.SaveFileFunc(path, content)
 }
 
+type MockDatasetRepository struct {
+	AddDatasetFunc               func(dataset *model.Dataset) (*model.Dataset, error)
+	GetUserDatasetFunc           func(userID, datasetID uint) (*model.Dataset, error)
+	GetUserDatasetByChecksumFunc func(userID uint, checksum string) (*model.Dataset, error)
+	ListUserDatasetsFunc         func(userID uint) ([]model.Dataset, error)
+	DeleteDatasetFunc            func(dataset *model.Dataset) error
+}
+
+func (m *MockDatasetRepository) AddDataset(dataset *model.Dataset) (*model.Dataset, error) {
+	return m.AddDatasetFunc(dataset)
+}
+
+func (m *MockDatasetRepository) GetUserDataset(userID, datasetID uint) (*model.Dataset, error) {
+	return m.GetUserDatasetFunc(userID, datasetID)
+}
+
+func (m *MockDatasetRepository) GetUserDatasetByChecksum(userID uint, checksum string) (*model.Dataset, error) {
+	return m.GetUserDatasetByChecksumFunc(userID, checksum)
+}
+
+func (m *MockDatasetRepository) ListUserDatasets(userID uint) ([]model.Dataset, error) {
+	return m.ListUserDatasetsFunc(userID)
+}
+
+func (m *MockDatasetRepository) DeleteDataset(dataset *model.Dataset) error {
+	return m.DeleteDatasetFunc(dataset)
+}
+
 var _ = Describe("FileService", func() {
 	var (
-		mockRepo    *MockFileRepository
-		fileService service.FileService
+		mockRepo        *MockFileRepository
+		mockDatasetRepo *MockDatasetRepository
+		fileService     service.FileService
 	)
 
 	BeforeEach(func() {
 		mockRepo = &MockFileRepository{}
-		fileService = service.NewFileService(mockRepo)
+		mockDatasetRepo = &MockDatasetRepository{
+			GetUserDatasetByChecksumFunc: func(userID uint, checksum string) (*model.Dataset, error) {
+				return nil, gorm.ErrRecordNotFound
+			},
+			AddDatasetFunc: func(dataset *model.Dataset) (*model.Dataset, error) {
+				dataset.ID = 1
+				return dataset, nil
+			},
+		}
+		fileService = service.NewFileService(mockRepo, mockDatasetRepo)
 	})
 
 	Describe("ProcessFile", func() {
 		It("should return an error if the file content is empty", func() {
-			_, err := fileService.ProcessFile("")
+			_, _, err := fileService.ProcessFile(1, "data.csv", "")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("file content is empty"))
 		})
@@ -62,11 +102,9 @@ var _ = Describe("FileService", func() {
 		It("should create directory if it does not exist", func() {
 			mockRepo.DirExistsFunc = func(path string) bool { return false }
 			mockRepo.MakeDirFunc = func(path string) error { return nil }
-			mockRepo.FileExistsFunc = func(path string) bool { return false }
 			mockRepo.SaveFileFunc = func(path string, content []byte) error { return nil }
-			mockRepo.ReadFileFunc = func(path string) ([]byte, error) { return nil, nil }
 
-			_, err := fileService.ProcessFile("header1,header2\nvalue1,value2")
+			_, _, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -74,42 +112,155 @@ var _ = Describe("FileService", func() {
 			mockRepo.DirExistsFunc = func(path string) bool { return false }
 			mockRepo.MakeDirFunc = func(path string) error { return errors.New("failed to create directory") }
 
-			_, err := fileService.ProcessFile("header1,header2\nvalue1,value2")
+			_, _, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("failed to create directory"))
 		})
 
-		It("should save file if it does not exist", func() {
+		It("should store the file under a per-user key and record the dataset", func() {
+			var savedPath string
 			mockRepo.DirExistsFunc = func(path string) bool { return true }
+			mockRepo.SaveFileFunc = func(path string, content []byte) error {
+				savedPath = path
+				return nil
+			}
+
+			dataset, _, err := fileService.ProcessFile(7, "uploads/data.csv", "header1,header2\nvalue1,value2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataset.UserID).To(Equal(uint(7)))
+			Expect(dataset.Filename).To(Equal("data.csv"))
+			Expect(dataset.Checksum).To(HaveLen(64))
+			Expect(dataset.Rows).To(Equal(1))
+			Expect(dataset.Columns).To(Equal(2))
+			Expect(savedPath).To(HaveSuffix("upload/7/" + dataset.Checksum + ".csv"))
+		})
+
+		It("should reuse an existing dataset with the same content", func() {
+			mockDatasetRepo.GetUserDatasetByChecksumFunc = func(userID uint, checksum string) (*model.Dataset, error) {
+				dataset := &model.Dataset{UserID: userID, Checksum: checksum, StorageKey: "1/" + checksum + ".csv"}
+				dataset.ID = 42
+				return dataset, nil
+			}
+			mockRepo.FileExistsFunc = func(path string) bool { return true }
+
+			dataset, parsedData, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataset.ID).To(Equal(uint(42)))
+			Expect(parsedData).To(HaveKeyWithValue("header1", []string{"value1"}))
+		})
+
+		It("should restore a missing file instead of recording the dataset twice", func() {
+			mockDatasetRepo.GetUserDatasetByChecksumFunc = func(userID uint, checksum string) (*model.Dataset, error) {
+				dataset := &model.Dataset{UserID: userID, Checksum: checksum, StorageKey: "1/" + checksum + ".csv"}
+				dataset.ID = 42
+				return dataset, nil
+			}
+			mockDatasetRepo.AddDatasetFunc = func(dataset *model.Dataset) (*model.Dataset, error) {
+				Fail("a second dataset row was inserted")
+				return nil, nil
+			}
+			var savedPath string
 			mockRepo.FileExistsFunc = func(path string) bool { return false }
+			mockRepo.DirExistsFunc = func(path string) bool { return true }
+			mockRepo.SaveFileFunc = func(path string, content []byte) error {
+				savedPath = path
+				return nil
+			}
+
+			dataset, _, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataset.ID).To(Equal(uint(42)))
+			Expect(savedPath).To(HaveSuffix("upload/" + dataset.StorageKey))
+		})
+
+		It("should return the row a concurrent upload of the same content recorded", func() {
+			lookups := 0
+			mockDatasetRepo.GetUserDatasetByChecksumFunc = func(userID uint, checksum string) (*model.Dataset, error) {
+				lookups++
+				if lookups == 1 {
+					return nil, gorm.ErrRecordNotFound
+				}
+				dataset := &model.Dataset{UserID: userID, Checksum: checksum}
+				dataset.ID = 42
+				return dataset, nil
+			}
+			mockDatasetRepo.AddDatasetFunc = func(dataset *model.Dataset) (*model.Dataset, error) {
+				return nil, gorm.ErrDuplicatedKey
+			}
+			mockRepo.DirExistsFunc = func(path string) bool { return true }
 			mockRepo.SaveFileFunc = func(path string, content []byte) error { return nil }
 
-			_, err := fileService.ProcessFile("header1,header2\nvalue1,value2")
+			dataset, _, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
 			Expect(err).NotTo(HaveOccurred())
+			Expect(dataset.ID).To(Equal(uint(42)))
+			Expect(lookups).To(Equal(2))
+		})
+
+		It("should not treat a failed checksum lookup as a new dataset", func() {
+			mockDatasetRepo.GetUserDatasetByChecksumFunc = func(userID uint, checksum string) (*model.Dataset, error) {
+				return nil, errors.New("connection refused")
+			}
+
+			_, _, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
+			Expect(err).To(MatchError("connection refused"))
 		})
 
 		It("should return an error if file saving fails", func() {
 			mockRepo.DirExistsFunc = func(path string) bool { return true }
-			mockRepo.FileExistsFunc = func(path string) bool { return false }
 			mockRepo.SaveFileFunc = func(path string, content []byte) error { return errors.New("failed to save file") }
 
-			_, err := fileService.ProcessFile("header1,header2\nvalue1,value2")
+			_, _, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("failed to save file"))
 		})
 
 		It("should parse CSV content correctly", func() {
 			mockRepo.DirExistsFunc = func(path string) bool { return true }
-			mockRepo.FileExistsFunc = func(path string) bool { return false }
 			mockRepo.SaveFileFunc = func(path string, content []byte) error { return nil }
 
-			parsedData, err := fileService.ProcessFile("header1,header2\nvalue1,value2")
+			_, parsedData, err := fileService.ProcessFile(1, "data.csv", "header1,header2\nvalue1,value2")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(parsedData).To(HaveKeyWithValue("header1", []string{"value1"}))
 			Expect(parsedData).To(HaveKeyWithValue("header2", []string{"value2"}))
 		})
 	})
 
+	Describe("LoadDataset", func() {
+		It("should return ErrDatasetNotFound for a dataset owned by another user", func() {
+			mockDatasetRepo.GetUserDatasetFunc = func(userID, datasetID uint) (*model.Dataset, error) {
+				return nil, gorm.ErrRecordNotFound
+			}
+
+			_, _, err := fileService.LoadDataset(2, 1)
+			Expect(err).To(MatchError(service.ErrDatasetNotFound))
+		})
+
+		It("should pass database errors through rather than reporting not found", func() {
+			mockDatasetRepo.GetUserDatasetFunc = func(userID, datasetID uint) (*model.Dataset, error) {
+				return nil, errors.New("connection refused")
+			}
+
+			_, _, err := fileService.LoadDataset(1, 1)
+			Expect(err).To(MatchError("connection refused"))
+			Expect(err).NotTo(MatchError(service.ErrDatasetNotFound))
+		})
+
+		It("should read and parse the stored dataset", func() {
+			mockDatasetRepo.GetUserDatasetFunc = func(userID, datasetID uint) (*model.Dataset, error) {
+				return &model.Dataset{UserID: userID, StorageKey: "1/abc.csv"}, nil
+			}
+			mockRepo.FileExistsFunc = func(path string) bool { return true }
+			mockRepo.ReadFileFunc = func(path string) ([]byte, error) {
+				Expect(path).To(HaveSuffix("upload/1/abc.csv"))
+				return []byte("header1,header2\nvalue1,value2"), nil
+			}
+
+			_, parsedData, err := fileService.LoadDataset(1, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsedData).To(HaveKeyWithValue("header2", []string{"value2"}))
+		})
+	})
+
 	Describe("ParseCSV", func() {
 		It("should return an error if CSV content is invalid", func() {
 			_, err := fileService.ParseCSV("header1,header2\nvalue1")
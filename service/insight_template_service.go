@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"gorm.io/gorm"
+)
+
+// MaxInsightTemplates bounds each template set, since every template costs
+// a table QA call per upload.
+const MaxInsightTemplates = 10
+
+var (
+	ErrInsightTemplateNotFound = errors.New("insight template not found")
+	ErrInsightQuery            = errors.New("insight query must be between 1 and 500 characters")
+	ErrInsightLabel            = errors.New("insight label must be between 1 and 100 characters")
+	ErrTooManyInsightTemplates = errors.New("too many insight templates")
+)
+
+// DefaultInsightTemplates are asked when the user has configured none.
+var DefaultInsightTemplates = []model.InsightTemplate{
+	{Label: "Least Electricity", Query: "Find the least electricity usage appliance."},
+	{Label: "Most Electricity", Query: "Find the most electricity usage appliance.", Position: 1},
+}
+
+type InsightTemplateService interface {
+	ListInsightTemplates(userID uint) ([]model.InsightTemplate, error)
+	CreateInsightTemplate(userID uint, req model.CreateInsightTemplateRequest) (model.InsightTemplate, error)
+	UpdateInsightTemplate(userID, templateID uint, req model.UpdateInsightTemplateRequest) (model.InsightTemplate, error)
+	DeleteInsightTemplate(userID, templateID uint) error
+	ResolveInsightTemplates(userID, datasetID uint) ([]model.InsightTemplate, error)
+}
+
+type insightTemplateService struct {
+	templateRepo repository.InsightTemplateRepository
+	datasetRepo  repository.DatasetRepository
+}
+
+func NewInsightTemplateService(templateRepo repository.InsightTemplateRepository, datasetRepo repository.DatasetRepository) InsightTemplateService {
+	return &insightTemplateService{templateRepo, datasetRepo}
+}
+
+func (s *insightTemplateService) ListInsightTemplates(userID uint) ([]model.InsightTemplate, error) {
+	return s.templateRepo.ListUserInsightTemplates(userID)
+}
+
+// CreateInsightTemplate adds a template to the user's general set, or to the
+// set of req.DatasetID, which must be one of the user's datasets.
+func (s *insightTemplateService) CreateInsightTemplate(userID uint, req model.CreateInsightTemplateRequest) (model.InsightTemplate, error) {
+	query, label, err := validateInsight(req.Query, req.Label)
+	if err != nil {
+		return model.InsightTemplate{}, err
+	}
+
+	if req.DatasetID != nil {
+		if _, err := s.datasetRepo.GetUserDataset(userID, *req.DatasetID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.InsightTemplate{}, ErrDatasetNotFound
+			}
+			return model.InsightTemplate{}, err
+		}
+	}
+
+	set, err := s.templateRepo.ListInsightTemplateSet(userID, req.DatasetID)
+	if err != nil {
+		return model.InsightTemplate{}, err
+	}
+	if len(set) >= MaxInsightTemplates {
+		return model.InsightTemplate{}, ErrTooManyInsightTemplates
+	}
+
+	template := model.InsightTemplate{
+		UserID:    userID,
+		DatasetID: req.DatasetID,
+		Query:     query,
+		Label:     label,
+	}
+	if req.Position != nil {
+		template.Position = *req.Position
+	} else if len(set) > 0 {
+		template.Position = set[len(set)-1].Position + 1
+	}
+
+	if err := s.templateRepo.AddInsightTemplate(&template); err != nil {
+		return model.InsightTemplate{}, err
+	}
+	return template, nil
+}
+
+// UpdateInsightTemplate changes the fields set in req.
+func (s *insightTemplateService) UpdateInsightTemplate(userID, templateID uint, req model.UpdateInsightTemplateRequest) (model.InsightTemplate, error) {
+	template, err := s.templateRepo.GetUserInsightTemplate(userID, templateID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.InsightTemplate{}, ErrInsightTemplateNotFound
+		}
+		return model.InsightTemplate{}, err
+	}
+
+	query, label := template.Query, template.Label
+	if req.Query != nil {
+		query = *req.Query
+	}
+	if req.Label != nil {
+		label = *req.Label
+	}
+	if template.Query, template.Label, err = validateInsight(query, label); err != nil {
+		return model.InsightTemplate{}, err
+	}
+	if req.Position != nil {
+		template.Position = *req.Position
+	}
+
+	if err := s.templateRepo.UpdateInsightTemplate(&template); err != nil {
+		return model.InsightTemplate{}, err
+	}
+	return template, nil
+}
+
+func (s *insightTemplateService) DeleteInsightTemplate(userID, templateID uint) error {
+	err := s.templateRepo.DeleteUserInsightTemplate(userID, templateID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInsightTemplateNotFound
+	}
+	return err
+}
+
+// ResolveInsightTemplates returns the templates to ask about an upload of
+// datasetID: its own set if it has one, else the user's general set, else
+// DefaultInsightTemplates.
+func (s *insightTemplateService) ResolveInsightTemplates(userID, datasetID uint) ([]model.InsightTemplate, error) {
+	templates, err := s.templateRepo.ListInsightTemplateSet(userID, &datasetID)
+	if err != nil || len(templates) > 0 {
+		return templates, err
+	}
+
+	templates, err = s.templateRepo.ListInsightTemplateSet(userID, nil)
+	if err != nil || len(templates) > 0 {
+		return templates, err
+	}
+
+	return DefaultInsightTemplates, nil
+}
+
+func validateInsight(query, label string) (string, string, error) {
+	query, label = strings.TrimSpace(query), strings.TrimSpace(label)
+	if query == "" || len(query) > 500 {
+		return "", "", ErrInsightQuery
+	}
+	if label == "" || len(label) > 100 {
+		return "", "", ErrInsightLabel
+	}
+	return query, label, nil
+}
@@ -0,0 +1,175 @@
+package service_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"gorm.io/gorm"
+)
+
+type MockInsightTemplateRepository struct {
+	AddInsightTemplateFunc        func(template *model.InsightTemplate) error
+	ListUserInsightTemplatesFunc  func(userID uint) ([]model.InsightTemplate, error)
+	ListInsightTemplateSetFunc    func(userID uint, datasetID *uint) ([]model.InsightTemplate, error)
+	GetUserInsightTemplateFunc    func(userID, templateID uint) (model.InsightTemplate, error)
+	UpdateInsightTemplateFunc     func(template *model.InsightTemplate) error
+	DeleteUserInsightTemplateFunc func(userID, templateID uint) error
+}
+
+func (m *MockInsightTemplateRepository) AddInsightTemplate(template *model.InsightTemplate) error {
+	return m.AddInsightTemplateFunc(template)
+}
+
+func (m *MockInsightTemplateRepository) ListUserInsightTemplates(userID uint) ([]model.InsightTemplate, error) {
+	return m.ListUserInsightTemplatesFunc(userID)
+}
+
+func (m *MockInsightTemplateRepository) ListInsightTemplateSet(userID uint, datasetID *uint) ([]model.InsightTemplate, error) {
+	return m.ListInsightTemplateSetFunc(userID, datasetID)
+}
+
+func (m *MockInsightTemplateRepository) GetUserInsightTemplate(userID, templateID uint) (model.InsightTemplate, error) {
+	return m.GetUserInsightTemplateFunc(userID, templateID)
+}
+
+func (m *MockInsightTemplateRepository) UpdateInsightTemplate(template *model.InsightTemplate) error {
+	return m.UpdateInsightTemplateFunc(template)
+}
+
+func (m *MockInsightTemplateRepository) DeleteUserInsightTemplate(userID, templateID uint) error {
+	return m.DeleteUserInsightTemplateFunc(userID, templateID)
+}
+
+var _ = Describe("InsightTemplateService", func() {
+	var (
+		mockRepo        *MockInsightTemplateRepository
+		mockDatasetRepo *MockDatasetRepository
+		insightService  service.InsightTemplateService
+	)
+
+	BeforeEach(func() {
+		mockRepo = &MockInsightTemplateRepository{}
+		mockDatasetRepo = &MockDatasetRepository{}
+		insightService = service.NewInsightTemplateService(mockRepo, mockDatasetRepo)
+	})
+
+	Describe("CreateInsightTemplate", func() {
+		var stored model.InsightTemplate
+
+		BeforeEach(func() {
+			mockRepo.AddInsightTemplateFunc = func(template *model.InsightTemplate) error {
+				stored = *template
+				return nil
+			}
+		})
+
+		It("should append to the end of the set by default", func() {
+			mockRepo.ListInsightTemplateSetFunc = func(userID uint, datasetID *uint) ([]model.InsightTemplate, error) {
+				Expect(datasetID).To(BeNil())
+				return []model.InsightTemplate{{Position: 0}, {Position: 4}}, nil
+			}
+
+			template, err := insightService.CreateInsightTemplate(1, model.CreateInsightTemplateRequest{Query: " Which room uses most? ", Label: "Busiest room"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(template.Position).To(Equal(5))
+			Expect(stored.UserID).To(Equal(uint(1)))
+			Expect(stored.Query).To(Equal("Which room uses most?"))
+		})
+
+		It("should only attach templates to the user's own datasets", func() {
+			mockDatasetRepo.GetUserDatasetFunc = func(userID, datasetID uint) (*model.Dataset, error) {
+				return nil, gorm.ErrRecordNotFound
+			}
+
+			datasetID := uint(7)
+			_, err := insightService.CreateInsightTemplate(1, model.CreateInsightTemplateRequest{DatasetID: &datasetID, Query: "q", Label: "l"})
+			Expect(err).To(MatchError(service.ErrDatasetNotFound))
+		})
+
+		It("should reject a blank query or an overlong label", func() {
+			_, err := insightService.CreateInsightTemplate(1, model.CreateInsightTemplateRequest{Query: "  ", Label: "l"})
+			Expect(err).To(MatchError(service.ErrInsightQuery))
+
+			_, err = insightService.CreateInsightTemplate(1, model.CreateInsightTemplateRequest{Query: "q", Label: strings.Repeat("a", 101)})
+			Expect(err).To(MatchError(service.ErrInsightLabel))
+		})
+
+		It("should cap the size of a set", func() {
+			mockRepo.ListInsightTemplateSetFunc = func(userID uint, datasetID *uint) ([]model.InsightTemplate, error) {
+				return make([]model.InsightTemplate, service.MaxInsightTemplates), nil
+			}
+
+			_, err := insightService.CreateInsightTemplate(1, model.CreateInsightTemplateRequest{Query: "q", Label: "l"})
+			Expect(err).To(MatchError(service.ErrTooManyInsightTemplates))
+		})
+	})
+
+	Describe("UpdateInsightTemplate", func() {
+		It("should only change the fields that were sent", func() {
+			mockRepo.GetUserInsightTemplateFunc = func(userID, templateID uint) (model.InsightTemplate, error) {
+				return model.InsightTemplate{UserID: userID, Query: "old query", Label: "Old", Position: 2}, nil
+			}
+			mockRepo.UpdateInsightTemplateFunc = func(template *model.InsightTemplate) error { return nil }
+
+			label := "New"
+			template, err := insightService.UpdateInsightTemplate(1, 3, model.UpdateInsightTemplateRequest{Label: &label})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(template.Label).To(Equal("New"))
+			Expect(template.Query).To(Equal("old query"))
+			Expect(template.Position).To(Equal(2))
+		})
+
+		It("should report another user's template as not found", func() {
+			mockRepo.GetUserInsightTemplateFunc = func(userID, templateID uint) (model.InsightTemplate, error) {
+				return model.InsightTemplate{}, gorm.ErrRecordNotFound
+			}
+
+			_, err := insightService.UpdateInsightTemplate(1, 3, model.UpdateInsightTemplateRequest{})
+			Expect(err).To(MatchError(service.ErrInsightTemplateNotFound))
+		})
+	})
+
+	Describe("DeleteInsightTemplate", func() {
+		It("should report a missing template as not found", func() {
+			mockRepo.DeleteUserInsightTemplateFunc = func(userID, templateID uint) error {
+				return gorm.ErrRecordNotFound
+			}
+
+			Expect(insightService.DeleteInsightTemplate(1, 3)).To(MatchError(service.ErrInsightTemplateNotFound))
+		})
+	})
+
+	Describe("ResolveInsightTemplates", func() {
+		var sets map[string][]model.InsightTemplate
+
+		BeforeEach(func() {
+			sets = map[string][]model.InsightTemplate{}
+			mockRepo.ListInsightTemplateSetFunc = func(userID uint, datasetID *uint) ([]model.InsightTemplate, error) {
+				if datasetID == nil {
+					return sets["user"], nil
+				}
+				return sets["dataset"], nil
+			}
+		})
+
+		It("should prefer the dataset's own templates", func() {
+			sets["dataset"] = []model.InsightTemplate{{Label: "Dataset"}}
+			sets["user"] = []model.InsightTemplate{{Label: "User"}}
+
+			Expect(insightService.ResolveInsightTemplates(1, 7)).To(Equal(sets["dataset"]))
+		})
+
+		It("should fall back to the user's general templates", func() {
+			sets["user"] = []model.InsightTemplate{{Label: "User"}}
+
+			Expect(insightService.ResolveInsightTemplates(1, 7)).To(Equal(sets["user"]))
+		})
+
+		It("should fall back to the built-in templates", func() {
+			Expect(insightService.ResolveInsightTemplates(1, 7)).To(Equal(service.DefaultInsightTemplates))
+		})
+	})
+})
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+var (
+	superlativeMinPattern = regexp.MustCompile(`\b(least|lowest|smallest|minimum|min|fewest)\b`)
+	superlativeMaxPattern = regexp.MustCompile(`\b(most|highest|largest|biggest|maximum|max|greatest)\b`)
+	sumPattern            = regexp.MustCompile(`\b(sum|total|altogether|combined)\b`)
+	averagePattern        = regexp.MustCompile(`\b(average|avg|mean)\b`)
+	countPattern          = regexp.MustCompile(`\b(how many|count|number of)\b`)
+	whichPattern          = regexp.MustCompile(`\b(which|who|where)\b`)
+	greaterThanPattern    = regexp.MustCompile(`(?:greater than|more than|higher than|above|over|>)\s*(-?\d+(?:\.\d+)?)`)
+	lessThanPattern       = regexp.MustCompile(`(?:less than|lower than|fewer than|below|under|<)\s*(-?\d+(?:\.\d+)?)`)
+	nonWordPattern        = regexp.MustCompile(`[^a-z0-9]+`)
+	querySeparatorPattern = regexp.MustCompile(`[^a-z0-9.<>-]+`)
+)
+
+// energyTerms are treated as synonyms when matching a question to a numeric
+// column, so "electricity usage" finds "Energy_Consumption (kWh)".
+var energyTerms = []string{"electricity", "energy", "usage", "consumption", "power", "kwh", "use", "uses", "used"}
+
+type localTableQAProvider struct{}
+
+// NewLocalTableQAProvider answers aggregator-shaped questions (SUM, AVERAGE,
+// COUNT, MIN, MAX and "which row has the most/least") with simple keyword
+// rules, without any network access.
+func NewLocalTableQAProvider() TableQAProvider {
+	return localTableQAProvider{}
+}
+
+func (localTableQAProvider) Name() string {
+	return "local"
+}
+
+func (localTableQAProvider) Answer(ctx context.Context, table map[string][]string, query string) (model.TapasResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return model.TapasResponse{}, err
+	}
+
+	t := newLocalTable(table)
+	if t.rows == 0 {
+		return model.TapasResponse{}, ErrUnansweredQuery
+	}
+
+	q := " " + normalizeQuery(query) + " "
+
+	target := t.targetColumn(q)
+	label, labelScore := t.labelColumn(q)
+	rows := t.filterRows(q, target)
+
+	isMin := superlativeMinPattern.MatchString(q)
+	isMax := superlativeMaxPattern.MatchString(q)
+
+	switch {
+	case countPattern.MatchString(q):
+		return t.result("COUNT", rows, label), nil
+	case averagePattern.MatchString(q) && target != "":
+		return t.result("AVERAGE", rows, target), nil
+	case sumPattern.MatchString(q) && target != "":
+		return t.result("SUM", rows, target), nil
+	case (isMin || isMax) && target != "":
+		// "Which appliance uses the most" or "find the most ... appliance"
+		if label != "" && (whichPattern.MatchString(q) || labelScore > 0) {
+			row, ok := t.extremeRow(rows, target, isMax && !isMin)
+			if !ok {
+				return model.TapasResponse{}, ErrUnansweredQuery
+			}
+			return t.result("NONE", []int{row}, label), nil
+		}
+		if isMax && !isMin {
+			return t.result("MAX", rows, target), nil
+		}
+		return t.result("MIN", rows, target), nil
+	case len(rows) < t.rows && label != "":
+		// A plain lookup such as "which appliances are in the kitchen"
+		return t.result("NONE", rows, label), nil
+	}
+
+	return model.TapasResponse{}, ErrUnansweredQuery
+}
+
+type localTable struct {
+	columns      []string // sorted, since the parsed table has no column order
+	cells        map[string][]string
+	numeric      map[string]bool
+	decimalMarks map[string]rune
+	rows         int
+}
+
+func newLocalTable(table map[string][]string) *localTable {
+	t := &localTable{cells: table, numeric: map[string]bool{}, decimalMarks: map[string]rune{}}
+	for column, values := range table {
+		t.columns = append(t.columns, column)
+		t.rows = max(t.rows, len(values))
+	}
+	slices.Sort(t.columns)
+
+	for _, column := range t.columns {
+		t.decimalMarks[column] = utility.DetectDecimalMark(table[column])
+		t.numeric[column] = isNumericColumn(table[column], t.decimalMarks[column])
+	}
+	return t
+}
+
+// number parses a cell of a numeric column, e.g. "1,5 kWh".
+func (t *localTable) number(column string, row int) (float64, bool) {
+	number, err := utility.ParseNumber(t.cell(column, row), t.decimalMarks[column])
+	return number.Value, err == nil
+}
+
+func (t *localTable) cell(column string, row int) string {
+	if row >= len(t.cells[column]) {
+		return ""
+	}
+	return strings.TrimSpace(t.cells[column][row])
+}
+
+// targetColumn picks the numeric column the question is about, preferring
+// the one whose name shares the most words with the question.
+func (t *localTable) targetColumn(q string) string {
+	best, bestScore := "", -1
+	for _, column := range t.columns {
+		if !t.numeric[column] {
+			continue
+		}
+		if score := columnScore(column, q); score > bestScore {
+			best, bestScore = column, score
+		}
+	}
+	return best
+}
+
+// labelColumn picks the text column used to name rows in the answer, and
+// reports how strongly the question refers to it.
+func (t *localTable) labelColumn(q string) (string, int) {
+	best, bestScore := "", -1
+	for _, column := range t.columns {
+		if t.numeric[column] {
+			continue
+		}
+		if score := columnScore(column, q); score > bestScore {
+			best, bestScore = column, score
+		}
+	}
+	return best, bestScore
+}
+
+// filterRows keeps the rows matching every text value named in the question
+// (values from the same column are OR-ed) and any numeric bound on target.
+func (t *localTable) filterRows(q, target string) []int {
+	wanted := map[string][]string{}
+	for _, column := range t.columns {
+		if t.numeric[column] {
+			continue
+		}
+		seen := map[string]bool{}
+		for row := 0; row < t.rows; row++ {
+			value := normalizeQuery(t.cell(column, row))
+			if len(value) < 3 || seen[value] {
+				continue
+			}
+			seen[value] = true
+			if strings.Contains(q, " "+value+" ") || strings.Contains(q, " "+value+"s ") {
+				wanted[column] = append(wanted[column], value)
+			}
+		}
+	}
+
+	var lower, upper *float64
+	if match := greaterThanPattern.FindStringSubmatch(q); match != nil {
+		value, _ := strconv.ParseFloat(match[1], 64)
+		lower = &value
+	}
+	if match := lessThanPattern.FindStringSubmatch(q); match != nil {
+		value, _ := strconv.ParseFloat(match[1], 64)
+		upper = &value
+	}
+
+	var rows []int
+	for row := 0; row < t.rows; row++ {
+		keep := true
+		for column, values := range wanted {
+			if !slices.Contains(values, normalizeQuery(t.cell(column, row))) {
+				keep = false
+				break
+			}
+		}
+		if keep && target != "" && (lower != nil || upper != nil) {
+			value, ok := t.number(target, row)
+			keep = ok && (lower == nil || value > *lower) && (upper == nil || value < *upper)
+		}
+		if keep {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (t *localTable) extremeRow(rows []int, column string, highest bool) (int, bool) {
+	bestRow, found := -1, false
+	bestValue := math.Inf(1)
+	if highest {
+		bestValue = math.Inf(-1)
+	}
+
+	for _, row := range rows {
+		value, ok := t.number(column, row)
+		if !ok {
+			continue
+		}
+		if (highest && value > bestValue) || (!highest && value < bestValue) {
+			bestRow, bestValue, found = row, value, true
+		}
+	}
+	return bestRow, found
+}
+
+// result builds the TAPAS-style response for the given rows of column.
+func (t *localTable) result(aggregator string, rows []int, column string) model.TapasResponse {
+	columnIndex := slices.Index(t.columns, column)
+
+	cells := make([]string, 0, len(rows))
+	coordinates := make([][]int, 0, len(rows))
+	for _, row := range rows {
+		cells = append(cells, t.cell(column, row))
+		coordinates = append(coordinates, []int{row, columnIndex})
+	}
+
+	answer := strings.Join(cells, ", ")
+	if aggregator != "NONE" {
+		answer = aggregator + " > " + answer
+	}
+
+	return model.TapasResponse{
+		Answer:      answer,
+		Coordinates: coordinates,
+		Cells:       cells,
+		Aggregator:  aggregator,
+	}
+}
+
+// normalizeQuery lowercases s and reduces it to space-separated words,
+// keeping the characters needed for numbers and comparisons.
+func normalizeQuery(s string) string {
+	words := strings.Fields(querySeparatorPattern.ReplaceAllString(strings.ToLower(s), " "))
+	for i, word := range words {
+		words[i] = strings.TrimRight(word, ".")
+	}
+	return strings.Join(words, " ")
+}
+
+func isNumericColumn(values []string, decimalMark rune) bool {
+	found := false
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		if _, err := utility.ParseNumber(value, decimalMark); err != nil {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// columnScore counts the words of a column name that occur in q, treating
+// the energy terms as interchangeable.
+func columnScore(column, q string) int {
+	score := 0
+	for _, word := range strings.Fields(nonWordPattern.ReplaceAllString(strings.ToLower(column), " ")) {
+		if len(word) < 3 {
+			continue
+		}
+		if strings.Contains(q, " "+word+" ") || strings.Contains(q, " "+word+"s ") {
+			score += 2
+		} else if slices.Contains(energyTerms, word) && mentionsAny(q, energyTerms) {
+			score++
+		}
+	}
+	return score
+}
+
+func mentionsAny(q string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(q, " "+word+" ") {
+			return true
+		}
+	}
+	return false
+}
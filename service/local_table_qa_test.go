@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+)
+
+var _ = Describe("LocalTableQAProvider", func() {
+	table := map[string][]string{
+		"Appliance":                {"Fridge", "Heater", "TV", "Washer", "Oven"},
+		"Room":                     {"Kitchen", "Living Room", "Living Room", "Laundry", "Kitchen"},
+		"Energy_Consumption (kWh)": {"1.5", "4.0", "0.8", "2.5", "3.0"},
+		"Status":                   {"On", "On", "Off", "On", "Off"},
+	}
+
+	provider := service.NewLocalTableQAProvider()
+
+	DescribeTable("answers aggregator-shaped questions",
+		func(query, aggregator string, cells []string) {
+			res, err := provider.Answer(context.Background(), table, query)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Aggregator).To(Equal(aggregator))
+			Expect(res.Cells).To(Equal(cells))
+		},
+		Entry("total", "What is the total energy consumption?", "SUM", []string{"1.5", "4.0", "0.8", "2.5", "3.0"}),
+		Entry("total filtered by a text value", "Total energy used in the kitchen", "SUM", []string{"1.5", "3.0"}),
+		Entry("average", "Average kWh in the living room", "AVERAGE", []string{"4.0", "0.8"}),
+		Entry("count", "How many appliances are in the kitchen?", "COUNT", []string{"Fridge", "Oven"}),
+		Entry("count with a numeric bound", "How many appliances use more than 2 kWh?", "COUNT", []string{"Heater", "Washer", "Oven"}),
+		Entry("maximum value", "What is the maximum energy consumption?", "MAX", []string{"1.5", "4.0", "0.8", "2.5", "3.0"}),
+		Entry("minimum value without a label", "Minimum energy consumption", "MIN", []string{"1.5", "4.0", "0.8", "2.5", "3.0"}),
+		Entry("least used appliance", "Find the least electricity usage appliance.", "NONE", []string{"TV"}),
+		Entry("most used appliance", "Find the most electricity usage appliance.", "NONE", []string{"Heater"}),
+		Entry("most used appliance in a room", "Which appliance uses the most energy in the kitchen?", "NONE", []string{"Oven"}),
+		Entry("lookup", "Which appliances are in the laundry?", "NONE", []string{"Washer"}),
+	)
+
+	It("should report questions it can't answer", func() {
+		_, err := provider.Answer(context.Background(), table, "Tell me a joke")
+		Expect(err).To(MatchError(service.ErrUnansweredQuery))
+	})
+
+	It("should format the answer like TAPAS", func() {
+		res, err := provider.Answer(context.Background(), table, "Total energy used in the kitchen")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(model.TapasResponse{
+			Answer:      "SUM > 1.5, 3.0",
+			Coordinates: [][]int{{0, 1}, {4, 1}},
+			Cells:       []string{"1.5", "3.0"},
+			Aggregator:  "SUM",
+		}))
+	})
+})
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+	ErrAccountLocked   = errors.New("account is temporarily locked")
+)
+
+// LoginBlockedError wraps ErrTooManyAttempts or ErrAccountLocked with how
+// long the client has to wait before trying again.
+type LoginBlockedError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", e.Err, e.RetryAfter)
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return e.Err
+}
+
+// LoginGuard throttles failed logins per username and per client IP. Every
+// failure doubles the wait before the next attempt, and reaching the
+// configured threshold locks the key out for LockoutDuration.
+type LoginGuard interface {
+	Allow(username, ip string) error
+	RecordFailure(username, ip string) error
+	RecordSuccess(username string) error
+}
+
+type loginGuard struct {
+	loginAttemptRepo repository.LoginAttemptRepository
+	config           model.LoginGuardConfig
+	now              func() time.Time
+}
+
+// NewLoginGuard creates a LoginGuard. now is the clock used for all
+// decisions; pass nil to use time.Now.
+func NewLoginGuard(loginAttemptRepo repository.LoginAttemptRepository, config model.LoginGuardConfig, now func() time.Time) LoginGuard {
+	if now == nil {
+		now = time.Now
+	}
+	return &loginGuard{loginAttemptRepo, config, now}
+}
+
+type loginKey struct {
+	key         string
+	maxFailures int
+}
+
+// Allow returns a *LoginBlockedError if either the username or the IP is
+// locked out or still inside its backoff delay. Lockouts take precedence
+// over backoff, and the longest wait wins.
+func (g *loginGuard) Allow(username, ip string) error {
+	now := g.now()
+
+	var blocked *LoginBlockedError
+	for _, k := range g.keys(username, ip) {
+		attempt, err := g.loginAttemptRepo.GetLoginAttempt(k.key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if g.stale(attempt, now) {
+			continue
+		}
+
+		candidate := &LoginBlockedError{Err: ErrTooManyAttempts}
+		if attempt.LockedUntil != nil {
+			candidate = &LoginBlockedError{Err: ErrAccountLocked, RetryAfter: attempt.LockedUntil.Sub(now)}
+		} else if next := attempt.LastFailureAt.Add(g.backoff(attempt.Failures)); now.Before(next) {
+			candidate.RetryAfter = next.Sub(now)
+		} else {
+			continue
+		}
+
+		if blocked == nil || outranks(candidate, blocked) {
+			blocked = candidate
+		}
+	}
+
+	if blocked != nil {
+		return blocked
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against the username and the IP,
+// locking out whichever reaches its threshold. The lockout audit record is
+// best-effort; the lock itself is what keeps the key out.
+func (g *loginGuard) RecordFailure(username, ip string) error {
+	now := g.now()
+
+	for _, k := range g.keys(username, ip) {
+		attempt, err := g.loginAttemptRepo.RecordLoginFailure(k.key, now, now.Add(-g.config.FailureWindow))
+		if err != nil {
+			return err
+		}
+		if attempt.Failures < k.maxFailures || attempt.LockedUntil != nil {
+			continue
+		}
+
+		lockedUntil := now.Add(g.config.LockoutDuration)
+		locked, err := g.loginAttemptRepo.LockLoginAttempt(k.key, lockedUntil)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			// A concurrent failure locked the key first and wrote the record
+			continue
+		}
+		log.Printf("Login lockout: %s after %d failures (username %q, ip %s) until %s", k.key, attempt.Failures, username, ip, lockedUntil.Format(time.RFC3339))
+
+		lockout := model.LoginLockout{
+			Key:         k.key,
+			Username:    username,
+			IPAddress:   ip,
+			Failures:    attempt.Failures,
+			LockedUntil: lockedUntil,
+		}
+		if err := g.loginAttemptRepo.AddLoginLockout(&lockout); err != nil {
+			log.Printf("AddLoginLockout error for %s: %v", k.key, err)
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the failures for username. The IP counter is left
+// alone so a valid login can't be used to reset a guessing run from the
+// same address.
+func (g *loginGuard) RecordSuccess(username string) error {
+	username = normalizeLoginUsername(username)
+	if username == "" {
+		return nil
+	}
+	return g.loginAttemptRepo.DeleteLoginAttempt("username:" + username)
+}
+
+func (g *loginGuard) keys(username, ip string) []loginKey {
+	var keys []loginKey
+	if username = normalizeLoginUsername(username); username != "" {
+		keys = append(keys, loginKey{"username:" + username, g.config.MaxFailures})
+	}
+	if ip != "" {
+		keys = append(keys, loginKey{"ip:" + ip, g.config.MaxFailuresPerIP})
+	}
+	return keys
+}
+
+// stale reports whether attempt no longer counts: its lockout has ended, or
+// its last failure fell out of the failure window.
+func (g *loginGuard) stale(attempt model.LoginAttempt, now time.Time) bool {
+	if attempt.LockedUntil != nil {
+		return !now.Before(*attempt.LockedUntil)
+	}
+	return now.Sub(attempt.LastFailureAt) > g.config.FailureWindow
+}
+
+// backoff is the wait required after the given number of failures.
+func (g *loginGuard) backoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	delay := g.config.BaseDelay
+	for i := 1; i < failures && delay < g.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, g.config.MaxDelay)
+}
+
+func outranks(a, b *LoginBlockedError) bool {
+	aLocked, bLocked := errors.Is(a.Err, ErrAccountLocked), errors.Is(b.Err, ErrAccountLocked)
+	if aLocked != bLocked {
+		return aLocked
+	}
+	return a.RetryAfter > b.RetryAfter
+}
+
+func normalizeLoginUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
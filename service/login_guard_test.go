@@ -0,0 +1,224 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"gorm.io/gorm"
+)
+
+type MockLoginAttemptRepository struct {
+	GetLoginAttemptFunc    func(key string) (model.LoginAttempt, error)
+	RecordLoginFailureFunc func(key string, at, windowStart time.Time) (model.LoginAttempt, error)
+	LockLoginAttemptFunc   func(key string, until time.Time) (bool, error)
+	DeleteLoginAttemptFunc func(key string) error
+	AddLoginLockoutFunc    func(lockout *model.LoginLockout) error
+}
+
+func (m *MockLoginAttemptRepository) GetLoginAttempt(key string) (model.LoginAttempt, error) {
+	return m.GetLoginAttemptFunc(key)
+}
+
+func (m *MockLoginAttemptRepository) RecordLoginFailure(key string, at, windowStart time.Time) (model.LoginAttempt, error) {
+	return m.RecordLoginFailureFunc(key, at, windowStart)
+}
+
+func (m *MockLoginAttemptRepository) LockLoginAttempt(key string, until time.Time) (bool, error) {
+	return m.LockLoginAttemptFunc(key, until)
+}
+
+func (m *MockLoginAttemptRepository) DeleteLoginAttempt(key string) error {
+	return m.DeleteLoginAttemptFunc(key)
+}
+
+func (m *MockLoginAttemptRepository) AddLoginLockout(lockout *model.LoginLockout) error {
+	return m.AddLoginLockoutFunc(lockout)
+}
+
+// newInMemoryLoginAttemptRepository backs the mock with maps so the guard
+// can be exercised across several calls. A mutex makes each call atomic,
+// like the single statements of the real repository.
+func newInMemoryLoginAttemptRepository() (*MockLoginAttemptRepository, map[string]model.LoginAttempt, *[]model.LoginLockout) {
+	var mu sync.Mutex
+	attempts := map[string]model.LoginAttempt{}
+	lockouts := &[]model.LoginLockout{}
+
+	return &MockLoginAttemptRepository{
+		GetLoginAttemptFunc: func(key string) (model.LoginAttempt, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			attempt, ok := attempts[key]
+			if !ok {
+				return model.LoginAttempt{}, gorm.ErrRecordNotFound
+			}
+			return attempt, nil
+		},
+		RecordLoginFailureFunc: func(key string, at, windowStart time.Time) (model.LoginAttempt, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			attempt, ok := attempts[key]
+			stale := attempt.LastFailureAt.Before(windowStart)
+			if attempt.LockedUntil != nil {
+				stale = !at.Before(*attempt.LockedUntil)
+			}
+			if !ok || stale {
+				attempt = model.LoginAttempt{Key: key}
+			}
+			attempt.Failures++
+			attempt.LastFailureAt = at
+			attempts[key] = attempt
+			return attempt, nil
+		},
+		LockLoginAttemptFunc: func(key string, until time.Time) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			attempt, ok := attempts[key]
+			if !ok || attempt.LockedUntil != nil {
+				return false, nil
+			}
+			attempt.LockedUntil = &until
+			attempts[key] = attempt
+			return true, nil
+		},
+		DeleteLoginAttemptFunc: func(key string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(attempts, key)
+			return nil
+		},
+		AddLoginLockoutFunc: func(lockout *model.LoginLockout) error {
+			mu.Lock()
+			defer mu.Unlock()
+			*lockouts = append(*lockouts, *lockout)
+			return nil
+		},
+	}, attempts, lockouts
+}
+
+var _ = Describe("LoginGuard", func() {
+	var (
+		mockRepo   *MockLoginAttemptRepository
+		attempts   map[string]model.LoginAttempt
+		lockouts   *[]model.LoginLockout
+		now        time.Time
+		loginGuard service.LoginGuard
+	)
+
+	config := model.LoginGuardConfig{
+		MaxFailures:      3,
+		MaxFailuresPerIP: 5,
+		BaseDelay:        time.Second,
+		MaxDelay:         4 * time.Second,
+		LockoutDuration:  15 * time.Minute,
+		FailureWindow:    15 * time.Minute,
+	}
+
+	BeforeEach(func() {
+		mockRepo, attempts, lockouts = newInMemoryLoginAttemptRepository()
+		now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		loginGuard = service.NewLoginGuard(mockRepo, config, func() time.Time { return now })
+	})
+
+	retryAfter := func(err error) time.Duration {
+		var blockedErr *service.LoginBlockedError
+		Expect(errors.As(err, &blockedErr)).To(BeTrue())
+		return blockedErr.RetryAfter
+	}
+
+	It("should allow a login without previous failures", func() {
+		Expect(loginGuard.Allow("alice", "10.0.0.1")).To(Succeed())
+	})
+
+	It("should double the backoff after each failure", func() {
+		Expect(loginGuard.RecordFailure("alice", "10.0.0.1")).To(Succeed())
+		err := loginGuard.Allow("alice", "10.0.0.1")
+		Expect(err).To(MatchError(service.ErrTooManyAttempts))
+		Expect(retryAfter(err)).To(Equal(time.Second))
+
+		now = now.Add(time.Second)
+		Expect(loginGuard.Allow("alice", "10.0.0.1")).To(Succeed())
+
+		Expect(loginGuard.RecordFailure("alice", "10.0.0.1")).To(Succeed())
+		Expect(retryAfter(loginGuard.Allow("alice", "10.0.0.1"))).To(Equal(2 * time.Second))
+	})
+
+	It("should lock the username out after MaxFailures and write an audit record", func() {
+		for i := 0; i < config.MaxFailures; i++ {
+			Expect(loginGuard.RecordFailure("Alice", "10.0.0.1")).To(Succeed())
+		}
+
+		err := loginGuard.Allow("alice", "10.0.0.2")
+		Expect(err).To(MatchError(service.ErrAccountLocked))
+		Expect(retryAfter(err)).To(Equal(config.LockoutDuration))
+
+		Expect(*lockouts).To(HaveLen(1))
+		Expect((*lockouts)[0].Key).To(Equal("username:alice"))
+		Expect((*lockouts)[0].Failures).To(Equal(config.MaxFailures))
+
+		now = now.Add(config.LockoutDuration)
+		Expect(loginGuard.Allow("alice", "10.0.0.2")).To(Succeed())
+	})
+
+	It("should lock out an IP that fails across many usernames", func() {
+		for _, username := range []string{"a1", "a2", "a3", "a4", "a5"} {
+			Expect(loginGuard.RecordFailure(username, "10.0.0.1")).To(Succeed())
+		}
+
+		Expect(loginGuard.Allow("someone-else", "10.0.0.1")).To(MatchError(service.ErrAccountLocked))
+		Expect(loginGuard.Allow("someone-else", "10.0.0.9")).To(Succeed())
+	})
+
+	It("should forget failures older than the failure window", func() {
+		Expect(loginGuard.RecordFailure("alice", "10.0.0.1")).To(Succeed())
+		Expect(loginGuard.RecordFailure("alice", "10.0.0.1")).To(Succeed())
+
+		now = now.Add(config.FailureWindow + time.Second)
+		Expect(loginGuard.RecordFailure("alice", "10.0.0.1")).To(Succeed())
+
+		Expect(attempts["username:alice"].Failures).To(Equal(1))
+		Expect(*lockouts).To(BeEmpty())
+	})
+
+	It("should count every one of many concurrent failures", func() {
+		var wg sync.WaitGroup
+		for i := range 20 {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(loginGuard.RecordFailure("alice", fmt.Sprintf("10.0.0.%d", i))).To(Succeed())
+			}()
+		}
+		wg.Wait()
+
+		Expect(attempts["username:alice"].Failures).To(Equal(20))
+		Expect(loginGuard.Allow("alice", "10.0.1.1")).To(MatchError(service.ErrAccountLocked))
+		Expect(*lockouts).To(HaveLen(1))
+	})
+
+	It("should still lock the key when the audit record can't be written", func() {
+		mockRepo.AddLoginLockoutFunc = func(lockout *model.LoginLockout) error {
+			return errors.New("value too long for type character varying(45)")
+		}
+
+		for i := 0; i < config.MaxFailures; i++ {
+			Expect(loginGuard.RecordFailure("alice", "10.0.0.1")).To(Succeed())
+		}
+
+		Expect(loginGuard.Allow("alice", "10.0.0.2")).To(MatchError(service.ErrAccountLocked))
+	})
+
+	It("should clear username failures but keep IP failures on success", func() {
+		Expect(loginGuard.RecordFailure("alice", "10.0.0.1")).To(Succeed())
+		Expect(loginGuard.RecordSuccess("alice")).To(Succeed())
+
+		Expect(attempts).NotTo(HaveKey("username:alice"))
+		Expect(attempts).To(HaveKey("ip:10.0.0.1"))
+	})
+})
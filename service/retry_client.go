@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var (
+	ErrModelLoading = errors.New("the AI model is still loading")
+	ErrRateLimited  = errors.New("the AI model is rate limited")
+	ErrUpstream     = errors.New("the AI model returned an error")
+)
+
+// UpstreamError wraps ErrModelLoading, ErrRateLimited or ErrUpstream with
+// what the model server said. RetryAfter is its hint for when to try again,
+// or zero if it gave none.
+type UpstreamError struct {
+	Err        error
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *UpstreamError) Error() string {
+	message := e.Err.Error()
+	if e.StatusCode != 0 {
+		message += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	return message
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// upstreamErrorBody is the error shape of the Hugging Face inference API.
+type upstreamErrorBody struct {
+	Error         string  `json:"error"`
+	EstimatedTime float64 `json:"estimated_time"`
+}
+
+const maxErrorBodySize = 64 * 1024
+
+type retryClient struct {
+	client HTTPClient
+	config model.RetryConfig
+}
+
+// NewRetryClient wraps client so that rate limits, loading models and
+// transient server or network errors are retried with jittered exponential
+// backoff. Retry-After and Hugging Face's estimated_time are honored, and
+// retries stop once config.Budget of waiting is used up or the request's
+// context deadline would pass. The last failure is then returned wrapping
+// ErrModelLoading, ErrRateLimited or ErrUpstream.
+func NewRetryClient(client HTTPClient, config model.RetryConfig) HTTPClient {
+	return &retryClient{client, config}
+}
+
+func (c *retryClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	var waited time.Duration
+
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := cloneRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := c.client.Do(attemptReq)
+
+		var failure error
+		var hint time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			failure = fmt.Errorf("%w: %w", ErrUpstream, err)
+		case isRetryableStatus(res.StatusCode):
+			upstreamErr := readUpstreamError(res)
+			failure, hint = upstreamErr, upstreamErr.RetryAfter
+		default:
+			return res, nil
+		}
+
+		wait := hint
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+
+		if attempt >= c.config.MaxAttempts || waited+wait > c.config.Budget || !fitsDeadline(ctx, wait) {
+			return nil, failure
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		waited += wait
+	}
+}
+
+// backoff returns a random wait between zero and BaseDelay*2^(attempt-1),
+// capped at MaxDelay ("full jitter").
+func (c *retryClient) backoff(attempt int) time.Duration {
+	ceiling := c.config.BaseDelay
+	for i := 1; i < attempt && ceiling < c.config.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, c.config.MaxDelay)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// readUpstreamError consumes and closes res, classifying the failure.
+func readUpstreamError(res *http.Response) *UpstreamError {
+	defer res.Body.Close()
+
+	upstreamErr := &UpstreamError{Err: ErrUpstream, StatusCode: res.StatusCode}
+
+	var body upstreamErrorBody
+	if data, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize)); err == nil {
+		if json.Unmarshal(data, &body) == nil {
+			upstreamErr.Message = body.Error
+		}
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		upstreamErr.Err = ErrRateLimited
+	case http.StatusServiceUnavailable:
+		if body.EstimatedTime > 0 {
+			upstreamErr.Err = ErrModelLoading
+			upstreamErr.RetryAfter = time.Duration(body.EstimatedTime * float64(time.Second))
+		}
+	}
+
+	if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+		upstreamErr.RetryAfter = retryAfter
+	}
+
+	return upstreamErr
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(at)), true
+	}
+	return 0, false
+}
+
+// cloneRequest copies req for another attempt, with a fresh body.
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+func fitsDeadline(ctx context.Context, wait time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > wait
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+)
+
+var _ = Describe("RetryClient", func() {
+	var (
+		mockClient *MockHTTPClient
+		client     service.HTTPClient
+		responses  []func() *http.Response
+		bodies     []string
+	)
+
+	response := func(statusCode int, body string, header http.Header) func() *http.Response {
+		if header == nil {
+			header = http.Header{}
+		}
+		return func() *http.Response {
+			return &http.Response{
+				StatusCode: statusCode,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}
+		}
+	}
+
+	newRequest := func(ctx context.Context) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, "POST", "https://example.com/model", bytes.NewBufferString(`{"inputs":"hi"}`))
+		Expect(err).NotTo(HaveOccurred())
+		return req
+	}
+
+	BeforeEach(func() {
+		responses = nil
+		bodies = nil
+		mockClient = &MockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				bodies = append(bodies, string(body))
+				res := responses[0]
+				if len(responses) > 1 {
+					responses = responses[1:]
+				}
+				return res(), nil
+			},
+		}
+		client = service.NewRetryClient(mockClient, model.RetryConfig{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    5 * time.Millisecond,
+			Budget:      time.Second,
+		})
+	})
+
+	It("should retry while the model is loading and resend the body", func() {
+		responses = []func() *http.Response{
+			response(http.StatusServiceUnavailable, `{"error":"Model is currently loading","estimated_time":0.01}`, nil),
+			response(http.StatusOK, `[]`, nil),
+		}
+
+		res, err := client.Do(newRequest(context.Background()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(bodies).To(Equal([]string{`{"inputs":"hi"}`, `{"inputs":"hi"}`}))
+	})
+
+	It("should give up after the configured attempts with a rate limit error", func() {
+		responses = []func() *http.Response{
+			response(http.StatusTooManyRequests, `{"error":"Rate limit reached"}`, nil),
+		}
+
+		_, err := client.Do(newRequest(context.Background()))
+		Expect(err).To(MatchError(service.ErrRateLimited))
+		Expect(err.Error()).To(ContainSubstring("Rate limit reached"))
+		Expect(bodies).To(HaveLen(3))
+	})
+
+	It("should not wait longer than the budget allows", func() {
+		responses = []func() *http.Response{
+			response(http.StatusServiceUnavailable, `{"error":"Model is currently loading","estimated_time":120}`, nil),
+		}
+
+		_, err := client.Do(newRequest(context.Background()))
+		Expect(err).To(MatchError(service.ErrModelLoading))
+		Expect(bodies).To(HaveLen(1))
+
+		var upstreamErr *service.UpstreamError
+		Expect(errors.As(err, &upstreamErr)).To(BeTrue())
+		Expect(upstreamErr.RetryAfter).To(Equal(2 * time.Minute))
+	})
+
+	It("should prefer the Retry-After header", func() {
+		responses = []func() *http.Response{
+			response(http.StatusTooManyRequests, ``, http.Header{"Retry-After": []string{"60"}}),
+		}
+
+		_, err := client.Do(newRequest(context.Background()))
+
+		var upstreamErr *service.UpstreamError
+		Expect(errors.As(err, &upstreamErr)).To(BeTrue())
+		Expect(upstreamErr.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(upstreamErr.RetryAfter).To(Equal(time.Minute))
+		Expect(bodies).To(HaveLen(1))
+	})
+
+	It("should not wait past the request deadline", func() {
+		responses = []func() *http.Response{
+			response(http.StatusServiceUnavailable, `{"error":"Model is currently loading","estimated_time":0.5}`, nil),
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := client.Do(newRequest(ctx))
+		Expect(err).To(MatchError(service.ErrModelLoading))
+		Expect(bodies).To(HaveLen(1))
+	})
+
+	It("should pass other responses through untouched", func() {
+		responses = []func() *http.Response{
+			response(http.StatusBadRequest, `{"error":"bad input"}`, nil),
+		}
+
+		res, err := client.Do(newRequest(context.Background()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(bodies).To(HaveLen(1))
+	})
+
+	It("should report other server errors as upstream errors", func() {
+		responses = []func() *http.Response{
+			response(http.StatusBadGateway, `upstream connect error`, nil),
+		}
+
+		_, err := client.Do(newRequest(context.Background()))
+		Expect(err).To(MatchError(service.ErrUpstream))
+		Expect(bodies).To(HaveLen(3))
+	})
+
+	It("should retry network errors", func() {
+		attempts := 0
+		mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, errors.New("connection reset by peer")
+			}
+			return response(http.StatusOK, `[]`, nil)(), nil
+		}
+
+		res, err := client.Do(newRequest(context.Background()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(attempts).To(Equal(2))
+	})
+})
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// sessionReaperBatchSize bounds how many rows a single DELETE removes, so the
+// reaper never holds a long lock on the sessions table.
+const sessionReaperBatchSize = 500
+
+// RunSessionReaper deletes expired sessions every interval until ctx is
+// cancelled. It is meant to be started in its own goroutine.
+func RunSessionReaper(ctx context.Context, sessionService SessionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Session reaper stopped")
+			return
+		case <-ticker.C:
+			deleted, err := sessionService.DeleteExpiredSessions(sessionReaperBatchSize)
+			if err != nil {
+				log.Printf("Session reaper error after removing %d sessions: %v", deleted, err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Session reaper removed %d expired sessions", deleted)
+			}
+		}
+	}
+}
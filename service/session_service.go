@@ -1,39 +1,57 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+	"gorm.io/gorm"
+)
+
+// lastSeenResolution limits how often a session's last-seen time and sliding
+// expiry are written back, so busy clients don't cause a database write on
+// every request.
+const lastSeenResolution = time.Minute
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
 )
 
 type SessionService interface {
 	AddSession(session model.Session) error
-	UpdateSession(session model.Session) error
+	StartSession(userID uint, userAgent, ipAddress string) (model.AuthTokens, error)
+	RefreshSession(refreshToken, userAgent, ipAddress string) (model.AuthTokens, error)
 	DeleteSession(sessionToken string) error
-	SessionAvailID(id uint) error
 	SessionAvailToken(token string) (model.Session, error)
 	TokenExpired(session model.Session) bool
 	TokenValidity(token string) (model.Session, error)
 	GetUserIDByToken(token string) (uint, error)
+	ListSessions(userID uint) ([]model.Session, error)
+	RevokeSession(userID, sessionID uint) error
+	RevokeAllSessions(userID uint) error
+	DeleteExpiredSessions(batchSize int) (int64, error)
 }
 
 type sessionService struct {
-	sessionRepository repository.SessionsRepository
+	sessionRepository      repository.SessionsRepository
+	refreshTokenRepository repository.RefreshTokenRepository
+	config                 model.SessionConfig
 }
 
-func NewSessionService(sessionRepository repository.SessionsRepository) SessionService {
-	return &sessionService{sessionRepository}
+func NewSessionService(sessionRepository repository.SessionsRepository, refreshTokenRepository repository.RefreshTokenRepository, config model.SessionConfig) SessionService {
+	return &sessionService{sessionRepository, refreshTokenRepository, config}
 }
 
 func (s *sessionService) GetUserIDByToken(token string) (uint, error) {
 	return s.sessionRepository.GetUserIDByToken(token)
 }
 
-func (s *sessionService) SessionAvailID(id uint) error {
-	return s.sessionRepository.SessionAvailID(id)
-}
 func (s *sessionService) SessionAvailToken(token string) (model.Session, error) {
 	return s.sessionRepository.SessionAvailToken(token)
 }
@@ -42,8 +60,53 @@ func (s *sessionService) AddSession(session model.Session) error {
 	return s.sessionRepository.AddSessions(session)
 }
 
-func (s *sessionService) UpdateSession(session model.Session) error {
-	return s.sessionRepository.UpdateSessions(session)
+// StartSession creates a session for a fresh login together with the first
+// refresh token of a new token family.
+func (s *sessionService) StartSession(userID uint, userAgent, ipAddress string) (model.AuthTokens, error) {
+	now := time.Now()
+	return s.issueTokens(userID, uuid.NewString(), now.Add(s.config.RefreshLifetime), userAgent, ipAddress, now)
+}
+
+// RefreshSession rotates a refresh token: the presented token is spent and a
+// new session plus refresh token are issued in the same family. Presenting a
+// token that was already spent means it leaked, so the whole family is
+// revoked.
+func (s *sessionService) RefreshSession(refreshToken, userAgent, ipAddress string) (model.AuthTokens, error) {
+	stored, err := s.refreshTokenRepository.GetRefreshToken(refreshToken)
+	if err != nil || stored.RevokedAt != nil {
+		return model.AuthTokens{}, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	if stored.UsedAt != nil {
+		if err := s.revokeFamily(stored.FamilyID, now); err != nil {
+			return model.AuthTokens{}, err
+		}
+		return model.AuthTokens{}, ErrRefreshTokenReused
+	}
+
+	if stored.Expiry.Before(now) {
+		return model.AuthTokens{}, ErrInvalidRefreshToken
+	}
+
+	marked, err := s.refreshTokenRepository.MarkRefreshTokenUsed(stored.ID, now)
+	if err != nil {
+		return model.AuthTokens{}, err
+	}
+	if !marked {
+		if err := s.revokeFamily(stored.FamilyID, now); err != nil {
+			return model.AuthTokens{}, err
+		}
+		return model.AuthTokens{}, ErrRefreshTokenReused
+	}
+
+	// The session issued alongside the spent token is replaced by the new one
+	if err := s.sessionRepository.DeleteSessionFamily(stored.FamilyID); err != nil {
+		return model.AuthTokens{}, err
+	}
+
+	// The family keeps its original expiry so rotation can't extend it forever
+	return s.issueTokens(stored.UserID, stored.FamilyID, stored.Expiry, userAgent, ipAddress, now)
 }
 
 func (s *sessionService) DeleteSession(sessionToken string) error {
@@ -64,9 +127,137 @@ func (s *sessionService) TokenValidity(token string) (model.Session, error) {
 		return model.Session{}, fmt.Errorf("Token is Expired!")
 	}
 
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		expiry := s.slidingExpiry(now, session.AbsoluteExpiry)
+		if err := s.sessionRepository.TouchSession(session.ID, now, expiry); err != nil {
+			return model.Session{}, err
+		}
+		session.LastSeenAt = now
+		session.Expiry = expiry
+	}
+
 	return session, nil
 }
 
 func (s *sessionService) TokenExpired(session model.Session) bool {
-	return session.Expiry.Before(time.Now())
+	now := time.Now()
+	if !session.AbsoluteExpiry.IsZero() && session.AbsoluteExpiry.Before(now) {
+		return true
+	}
+	return session.Expiry.Before(now)
+}
+
+func (s *sessionService) ListSessions(userID uint) ([]model.Session, error) {
+	return s.sessionRepository.ListUserSessions(userID)
+}
+
+// RevokeSession deletes one of the user's sessions and revokes the refresh
+// tokens issued for it, so it can't be revived through /refresh.
+func (s *sessionService) RevokeSession(userID, sessionID uint) error {
+	session, err := s.sessionRepository.GetUserSession(userID, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	err = s.sessionRepository.DeleteUserSession(userID, sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if session.FamilyID == "" {
+		return nil
+	}
+	return s.refreshTokenRepository.RevokeFamily(session.FamilyID, time.Now())
+}
+
+func (s *sessionService) RevokeAllSessions(userID uint) error {
+	if err := s.sessionRepository.DeleteUserSessions(userID); err != nil {
+		return err
+	}
+	return s.refreshTokenRepository.RevokeUserRefreshTokens(userID, time.Now())
+}
+
+// DeleteExpiredSessions removes expired sessions in batches of batchSize
+// until none are left, returning the total number of rows removed.
+func (s *sessionService) DeleteExpiredSessions(batchSize int) (int64, error) {
+	now := time.Now()
+
+	var total int64
+	for {
+		deleted, err := s.sessionRepository.DeleteExpiredSessions(now, batchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+func (s *sessionService) issueTokens(userID uint, familyID string, refreshExpiry time.Time, userAgent, ipAddress string, now time.Time) (model.AuthTokens, error) {
+	sessionToken, err := utility.GenerateToken()
+	if err != nil {
+		return model.AuthTokens{}, err
+	}
+
+	absoluteExpiry := now.Add(s.config.AbsoluteLifetime)
+	session := model.Session{
+		Token:          sessionToken,
+		UserID:         userID,
+		Expiry:         s.slidingExpiry(now, absoluteExpiry),
+		AbsoluteExpiry: absoluteExpiry,
+		FamilyID:       familyID,
+		UserAgent:      userAgent,
+		IPAddress:      ipAddress,
+		LastSeenAt:     now,
+	}
+	if err := s.sessionRepository.AddSessions(session); err != nil {
+		return model.AuthTokens{}, err
+	}
+
+	rawRefreshToken, err := utility.GenerateToken()
+	if err != nil {
+		return model.AuthTokens{}, err
+	}
+
+	refreshToken := model.RefreshToken{
+		Token:    rawRefreshToken,
+		UserID:   userID,
+		FamilyID: familyID,
+		Expiry:   refreshExpiry,
+	}
+	if err := s.refreshTokenRepository.AddRefreshToken(refreshToken); err != nil {
+		return model.AuthTokens{}, err
+	}
+
+	return model.AuthTokens{
+		SessionToken: session.Token,
+		RefreshToken: refreshToken.Token,
+		ExpiresAt:    session.Expiry,
+	}, nil
+}
+
+func (s *sessionService) revokeFamily(familyID string, now time.Time) error {
+	if err := s.sessionRepository.DeleteSessionFamily(familyID); err != nil {
+		return err
+	}
+	return s.refreshTokenRepository.RevokeFamily(familyID, now)
+}
+
+// slidingExpiry extends the idle expiry from now without passing the
+// session's absolute expiry.
+func (s *sessionService) slidingExpiry(now, absoluteExpiry time.Time) time.Time {
+	expiry := now.Add(s.config.IdleTimeout)
+	if !absoluteExpiry.IsZero() && expiry.After(absoluteExpiry) {
+		return absoluteExpiry
+	}
+	return expiry
 }
@@ -1,22 +1,30 @@
 package service_test
 
 import (
+	"context"
 	"errors"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"gorm.io/gorm"
 )
 
 type MockSessionsRepository struct {
-	SessionAvailIDFunc    func(id uint) error
-	AddSessionsFunc       func(session model.Session) error
-	UpdateSessionsFunc    func(session model.Session) error
-	DeleteSessionFunc     func(sessionToken string) error
-	SessionAvailTokenFunc func(token string) (model.Session, error)
-	GetUserIDByTokenFunc  func(token string) (uint, error)
+	AddSessionsFunc         func(session model.Session) error
+	DeleteSessionFunc       func(sessionToken string) error
+	SessionAvailTokenFunc   func(token string) (model.Session, error)
+	GetUserIDByTokenFunc    func(token string) (uint, error)
+	ListUserSessionsFunc    func(userID uint) ([]model.Session, error)
+	GetUserSessionFunc      func(userID, sessionID uint) (model.Session, error)
+	DeleteUserSessionFunc   func(userID, sessionID uint) error
+	DeleteUserSessionsFunc  func(userID uint) error
+	DeleteSessionFamilyFunc func(familyID string) error
+	TouchSessionFunc        func(sessionID uint, lastSeenAt, expiry time.Time) error
+	DeleteExpiredFunc       func(now time.Time, limit int) (int64, error)
 }
 
 // GetUserByToken implements repository.SessionsRepository.
@@ -24,18 +32,10 @@ func (m *MockSessionsRepository) GetUserIDByToken(token string) (uint, error) {
 	return m.GetUserIDByTokenFunc(token)
 }
 
-func (m *MockSessionsRepository) SessionAvailID(id uint) error {
-	return m.SessionAvailIDFunc(id)
-}
-
 func (m *MockSessionsRepository) AddSessions(session model.Session) error {
 	return m.AddSessionsFunc(session)
 }
 
-func (m *MockSessionsRepository) UpdateSessions(session model.Session) error {
-	return m.UpdateSessionsFunc(session)
-}
-
 func (m *MockSessionsRepository) DeleteSession(sessionToken string) error {
 	return m.DeleteSessionFunc(sessionToken)
 }
@@ -44,36 +44,79 @@ func (m *MockSessionsRepository) SessionAvailToken(token string) (model.Session,
 	return m.SessionAvailTokenFunc(token)
 }
 
+func (m *MockSessionsRepository) ListUserSessions(userID uint) ([]model.Session, error) {
+	return m.ListUserSessionsFunc(userID)
+}
+
+func (m *MockSessionsRepository) GetUserSession(userID, sessionID uint) (model.Session, error) {
+	return m.GetUserSessionFunc(userID, sessionID)
+}
+
+func (m *MockSessionsRepository) DeleteUserSession(userID, sessionID uint) error {
+	return m.DeleteUserSessionFunc(userID, sessionID)
+}
+
+func (m *MockSessionsRepository) DeleteUserSessions(userID uint) error {
+	return m.DeleteUserSessionsFunc(userID)
+}
+
+func (m *MockSessionsRepository) DeleteSessionFamily(familyID string) error {
+	return m.DeleteSessionFamilyFunc(familyID)
+}
+
+func (m *MockSessionsRepository) TouchSession(sessionID uint, lastSeenAt, expiry time.Time) error {
+	return m.TouchSessionFunc(sessionID, lastSeenAt, expiry)
+}
+
+func (m *MockSessionsRepository) DeleteExpiredSessions(now time.Time, limit int) (int64, error) {
+	return m.DeleteExpiredFunc(now, limit)
+}
+
+type MockRefreshTokenRepository struct {
+	AddRefreshTokenFunc         func(refreshToken model.RefreshToken) error
+	GetRefreshTokenFunc         func(token string) (model.RefreshToken, error)
+	MarkRefreshTokenUsedFunc    func(id uint, usedAt time.Time) (bool, error)
+	RevokeFamilyFunc            func(familyID string, revokedAt time.Time) error
+	RevokeUserRefreshTokensFunc func(userID uint, revokedAt time.Time) error
+}
+
+func (m *MockRefreshTokenRepository) AddRefreshToken(refreshToken model.RefreshToken) error {
+	return m.AddRefreshTokenFunc(refreshToken)
+}
+
+func (m *MockRefreshTokenRepository) GetRefreshToken(token string) (model.RefreshToken, error) {
+	return m.GetRefreshTokenFunc(token)
+}
+
+func (m *MockRefreshTokenRepository) MarkRefreshTokenUsed(id uint, usedAt time.Time) (bool, error) {
+	return m.MarkRefreshTokenUsedFunc(id, usedAt)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(familyID string, revokedAt time.Time) error {
+	return m.RevokeFamilyFunc(familyID, revokedAt)
+}
+
+func (m *MockRefreshTokenRepository) RevokeUserRefreshTokens(userID uint, revokedAt time.Time) error {
+	return m.RevokeUserRefreshTokensFunc(userID, revokedAt)
+}
+
 var _ = Describe("SessionService", func() {
 	var (
-		mockRepo       *MockSessionsRepository
-		sessionService service.SessionService
+		mockRepo        *MockSessionsRepository
+		mockRefreshRepo *MockRefreshTokenRepository
+		sessionService  service.SessionService
+		config          model.SessionConfig
 	)
 
 	BeforeEach(func() {
 		mockRepo = &MockSessionsRepository{}
-		sessionService = service.NewSessionService(mockRepo)
-	})
-
-	Describe("SessionAvailID", func() {
-		It("should return an error if session name is not available", func() {
-			mockRepo.SessionAvailIDFunc = func(id uint) error {
-				return errors.New("username not available")
-			}
-
-			err := sessionService.SessionAvailID(1)
-			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(Equal("username not available"))
-		})
-
-		It("should return nil if session name is available", func() {
-			mockRepo.SessionAvailIDFunc = func(id uint) error {
-				return nil
-			}
-
-			err := sessionService.SessionAvailID(1)
-			Expect(err).NotTo(HaveOccurred())
-		})
+		mockRefreshRepo = &MockRefreshTokenRepository{}
+		config = model.SessionConfig{
+			IdleTimeout:      30 * time.Minute,
+			AbsoluteLifetime: 5 * time.Hour,
+			RefreshLifetime:  7 * 24 * time.Hour,
+		}
+		sessionService = service.NewSessionService(mockRepo, mockRefreshRepo, config)
 	})
 
 	Describe("AddSession", func() {
@@ -97,27 +140,6 @@ var _ = Describe("SessionService", func() {
 		})
 	})
 
-	Describe("UpdateSession", func() {
-		It("should return an error if updating session fails", func() {
-			mockRepo.UpdateSessionsFunc = func(session model.Session) error {
-				return errors.New("failed to update session")
-			}
-
-			err := sessionService.UpdateSession(model.Session{UserID: 1})
-			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(Equal("failed to update session"))
-		})
-
-		It("should update session successfully", func() {
-			mockRepo.UpdateSessionsFunc = func(session model.Session) error {
-				return nil
-			}
-
-			err := sessionService.UpdateSession(model.Session{UserID: 1})
-			Expect(err).NotTo(HaveOccurred())
-		})
-	})
-
 	Describe("DeleteSession", func() {
 		It("should return an error if deleting session fails", func() {
 			mockRepo.DeleteSessionFunc = func(sessionToken string) error {
@@ -167,11 +189,56 @@ var _ = Describe("SessionService", func() {
 			mockRepo.SessionAvailTokenFunc = func(token string) (model.Session, error) {
 				return model.Session{Expiry: time.Now().Add(time.Hour)}, nil
 			}
+			mockRepo.TouchSessionFunc = func(sessionID uint, lastSeenAt, expiry time.Time) error {
+				return nil
+			}
 
 			session, err := sessionService.TokenValidity("validtoken")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(session.Expiry.After(time.Now())).To(BeTrue())
 		})
+
+		It("should slide the idle expiry without passing the absolute expiry", func() {
+			absoluteExpiry := time.Now().Add(10 * time.Minute)
+			mockRepo.SessionAvailTokenFunc = func(token string) (model.Session, error) {
+				return model.Session{Expiry: time.Now().Add(time.Minute), AbsoluteExpiry: absoluteExpiry}, nil
+			}
+			var slidExpiry time.Time
+			mockRepo.TouchSessionFunc = func(sessionID uint, lastSeenAt, expiry time.Time) error {
+				slidExpiry = expiry
+				return nil
+			}
+
+			session, err := sessionService.TokenValidity("validtoken")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(slidExpiry).To(Equal(absoluteExpiry))
+			Expect(session.Expiry).To(Equal(absoluteExpiry))
+		})
+
+		It("should return an error once the absolute expiry has passed", func() {
+			mockRepo.SessionAvailTokenFunc = func(token string) (model.Session, error) {
+				return model.Session{Expiry: time.Now().Add(time.Hour), AbsoluteExpiry: time.Now().Add(-time.Minute)}, nil
+			}
+			mockRepo.DeleteSessionFunc = func(sessionToken string) error {
+				return nil
+			}
+
+			_, err := sessionService.TokenValidity("validtoken")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not touch a session seen moments ago", func() {
+			mockRepo.SessionAvailTokenFunc = func(token string) (model.Session, error) {
+				return model.Session{Expiry: time.Now().Add(time.Hour), LastSeenAt: time.Now()}, nil
+			}
+			mockRepo.TouchSessionFunc = func(sessionID uint, lastSeenAt, expiry time.Time) error {
+				Fail("TouchSession should not be called")
+				return nil
+			}
+
+			_, err := sessionService.TokenValidity("validtoken")
+			Expect(err).NotTo(HaveOccurred())
+		})
 	})
 
 	Describe("TokenExpired", func() {
@@ -213,4 +280,214 @@ var _ = Describe("SessionService", func() {
 			Expect(user).To(Equal(uint(1)))
 		})
 	})
+
+	Describe("RevokeSession", func() {
+		It("should return ErrSessionNotFound for another user's session", func() {
+			mockRepo.GetUserSessionFunc = func(userID, sessionID uint) (model.Session, error) {
+				return model.Session{}, gorm.ErrRecordNotFound
+			}
+
+			err := sessionService.RevokeSession(1, 2)
+			Expect(err).To(MatchError(service.ErrSessionNotFound))
+		})
+
+		It("should revoke the session and its refresh token family", func() {
+			mockRepo.GetUserSessionFunc = func(userID, sessionID uint) (model.Session, error) {
+				return model.Session{FamilyID: "family-1"}, nil
+			}
+			mockRepo.DeleteUserSessionFunc = func(userID, sessionID uint) error {
+				return nil
+			}
+			var revokedFamily string
+			mockRefreshRepo.RevokeFamilyFunc = func(familyID string, revokedAt time.Time) error {
+				revokedFamily = familyID
+				return nil
+			}
+
+			err := sessionService.RevokeSession(1, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revokedFamily).To(Equal("family-1"))
+		})
+	})
+
+	Describe("RevokeAllSessions", func() {
+		It("should delete every session of the user", func() {
+			var revokedUserID uint
+			mockRepo.DeleteUserSessionsFunc = func(userID uint) error {
+				revokedUserID = userID
+				return nil
+			}
+
+			mockRefreshRepo.RevokeUserRefreshTokensFunc = func(userID uint, revokedAt time.Time) error {
+				return nil
+			}
+
+			err := sessionService.RevokeAllSessions(3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revokedUserID).To(Equal(uint(3)))
+		})
+	})
+
+	Describe("StartSession", func() {
+		It("should issue a session and a refresh token in the same family", func() {
+			var added model.Session
+			mockRepo.AddSessionsFunc = func(session model.Session) error {
+				added = session
+				return nil
+			}
+			var addedRefresh model.RefreshToken
+			mockRefreshRepo.AddRefreshTokenFunc = func(refreshToken model.RefreshToken) error {
+				addedRefresh = refreshToken
+				return nil
+			}
+
+			tokens, err := sessionService.StartSession(1, "test-agent", "127.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokens.SessionToken).To(Equal(added.Token))
+			Expect(tokens.SessionToken).To(HaveLen(43)) // 32 random bytes, base64url
+			Expect(tokens.RefreshToken).NotTo(Equal(tokens.SessionToken))
+			Expect(tokens.RefreshToken).To(Equal(addedRefresh.Token))
+			Expect(added.FamilyID).To(Equal(addedRefresh.FamilyID))
+			Expect(added.UserAgent).To(Equal("test-agent"))
+			Expect(added.AbsoluteExpiry.Sub(added.Expiry)).To(BeNumerically("~", config.AbsoluteLifetime-config.IdleTimeout, time.Second))
+		})
+	})
+
+	Describe("RefreshSession", func() {
+		var (
+			stored         model.RefreshToken
+			revokedFamily  string
+			deletedFamily  string
+			addedRefreshes []model.RefreshToken
+		)
+
+		BeforeEach(func() {
+			stored = model.RefreshToken{UserID: 1, FamilyID: "family-1", Expiry: time.Now().Add(time.Hour)}
+			revokedFamily, deletedFamily, addedRefreshes = "", "", nil
+
+			mockRefreshRepo.GetRefreshTokenFunc = func(token string) (model.RefreshToken, error) {
+				return stored, nil
+			}
+			mockRefreshRepo.MarkRefreshTokenUsedFunc = func(id uint, usedAt time.Time) (bool, error) {
+				return true, nil
+			}
+			mockRefreshRepo.RevokeFamilyFunc = func(familyID string, revokedAt time.Time) error {
+				revokedFamily = familyID
+				return nil
+			}
+			mockRefreshRepo.AddRefreshTokenFunc = func(refreshToken model.RefreshToken) error {
+				addedRefreshes = append(addedRefreshes, refreshToken)
+				return nil
+			}
+			mockRepo.DeleteSessionFamilyFunc = func(familyID string) error {
+				deletedFamily = familyID
+				return nil
+			}
+			mockRepo.AddSessionsFunc = func(session model.Session) error {
+				return nil
+			}
+		})
+
+		It("should rotate the refresh token within the family", func() {
+			tokens, err := sessionService.RefreshSession("refresh", "agent", "127.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokens.RefreshToken).NotTo(BeEmpty())
+			Expect(addedRefreshes).To(HaveLen(1))
+			Expect(addedRefreshes[0].FamilyID).To(Equal("family-1"))
+			Expect(addedRefreshes[0].Expiry).To(Equal(stored.Expiry))
+			Expect(deletedFamily).To(Equal("family-1"))
+			Expect(revokedFamily).To(BeEmpty())
+		})
+
+		It("should revoke the family when a used token is presented again", func() {
+			usedAt := time.Now().Add(-time.Minute)
+			stored.UsedAt = &usedAt
+
+			_, err := sessionService.RefreshSession("refresh", "agent", "127.0.0.1")
+			Expect(err).To(MatchError(service.ErrRefreshTokenReused))
+			Expect(revokedFamily).To(Equal("family-1"))
+			Expect(deletedFamily).To(Equal("family-1"))
+			Expect(addedRefreshes).To(BeEmpty())
+		})
+
+		It("should revoke the family when a concurrent refresh already spent the token", func() {
+			mockRefreshRepo.MarkRefreshTokenUsedFunc = func(id uint, usedAt time.Time) (bool, error) {
+				return false, nil
+			}
+
+			_, err := sessionService.RefreshSession("refresh", "agent", "127.0.0.1")
+			Expect(err).To(MatchError(service.ErrRefreshTokenReused))
+			Expect(revokedFamily).To(Equal("family-1"))
+		})
+
+		It("should reject an expired refresh token", func() {
+			stored.Expiry = time.Now().Add(-time.Minute)
+
+			_, err := sessionService.RefreshSession("refresh", "agent", "127.0.0.1")
+			Expect(err).To(MatchError(service.ErrInvalidRefreshToken))
+		})
+
+		It("should reject an unknown refresh token", func() {
+			mockRefreshRepo.GetRefreshTokenFunc = func(token string) (model.RefreshToken, error) {
+				return model.RefreshToken{}, gorm.ErrRecordNotFound
+			}
+
+			_, err := sessionService.RefreshSession("refresh", "agent", "127.0.0.1")
+			Expect(err).To(MatchError(service.ErrInvalidRefreshToken))
+		})
+	})
+
+	Describe("DeleteExpiredSessions", func() {
+		It("should keep deleting batches until a partial batch is returned", func() {
+			batches := []int64{2, 2, 1}
+			calls := 0
+			mockRepo.DeleteExpiredFunc = func(now time.Time, limit int) (int64, error) {
+				Expect(limit).To(Equal(2))
+				deleted := batches[calls]
+				calls++
+				return deleted, nil
+			}
+
+			deleted, err := sessionService.DeleteExpiredSessions(2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted).To(Equal(int64(5)))
+			Expect(calls).To(Equal(3))
+		})
+
+		It("should report rows deleted before an error", func() {
+			calls := 0
+			mockRepo.DeleteExpiredFunc = func(now time.Time, limit int) (int64, error) {
+				calls++
+				if calls == 2 {
+					return 0, errors.New("connection lost")
+				}
+				return int64(limit), nil
+			}
+
+			deleted, err := sessionService.DeleteExpiredSessions(10)
+			Expect(err).To(HaveOccurred())
+			Expect(deleted).To(Equal(int64(10)))
+		})
+	})
+
+	Describe("RunSessionReaper", func() {
+		It("should reap on every tick and stop when the context is cancelled", func() {
+			var calls atomic.Int32
+			mockRepo.DeleteExpiredFunc = func(now time.Time, limit int) (int64, error) {
+				calls.Add(1)
+				return 0, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				service.RunSessionReaper(ctx, sessionService, 5*time.Millisecond)
+				close(done)
+			}()
+
+			Eventually(calls.Load).Should(BeNumerically(">=", 2))
+			cancel()
+			Eventually(done).Should(BeClosed())
+		})
+	})
 })
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var ErrUnknownTableQACache = errors.New("unknown table QA cache backend")
+
+// TableQACache stores table QA answers so the same question about the same
+// dataset isn't sent to the model again. Keys come from tableQACacheKey.
+type TableQACache interface {
+	Get(key string) (model.TapasResponse, bool, error)
+	Set(key string, response model.TapasResponse) error
+}
+
+// NewTableQACache builds the cache named by config.Backend. The "none"
+// backend returns a nil cache, which disables caching.
+func NewTableQACache(config model.TableQACacheConfig, repo repository.TableQACacheRepository, now func() time.Time) (TableQACache, error) {
+	switch config.Backend {
+	case "memory":
+		return NewMemoryTableQACache(config, now), nil
+	case "postgres":
+		return NewPostgresTableQACache(repo, config, now), nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownTableQACache, config.Backend)
+}
+
+// tableQACacheKey identifies an answer by the dataset content, the question
+// as normalizeQuery sees it, and the provider, whose name includes its model.
+func tableQACacheKey(checksum, query, provider string) string {
+	sum := sha256.Sum256([]byte(checksum + "\x00" + normalizeQuery(query) + "\x00" + provider))
+	return hex.EncodeToString(sum[:])
+}
+
+type memoryTableQACache struct {
+	config model.TableQACacheConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+}
+
+type memoryTableQACacheEntry struct {
+	key       string
+	response  model.TapasResponse
+	expiresAt time.Time
+}
+
+// NewMemoryTableQACache keeps up to config.MaxEntries answers in process,
+// evicting the least recently used. now is the clock used for expiry; pass
+// nil to use time.Now.
+func NewMemoryTableQACache(config model.TableQACacheConfig, now func() time.Time) TableQACache {
+	if now == nil {
+		now = time.Now
+	}
+	return &memoryTableQACache{config: config, now: now, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *memoryTableQACache) Get(key string) (model.TapasResponse, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return model.TapasResponse{}, false, nil
+	}
+
+	entry := element.Value.(*memoryTableQACacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return model.TapasResponse{}, false, nil
+	}
+
+	c.order.MoveToFront(element)
+	return entry.response, true, nil
+}
+
+func (c *memoryTableQACache) Set(key string, response model.TapasResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(c.config.TTL)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*memoryTableQACacheEntry)
+		entry.response, entry.expiresAt = response, expiresAt
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&memoryTableQACacheEntry{key, response, expiresAt})
+	for c.order.Len() > c.config.MaxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryTableQACacheEntry).key)
+	}
+	return nil
+}
+
+type postgresTableQACache struct {
+	repo   repository.TableQACacheRepository
+	config model.TableQACacheConfig
+	now    func() time.Time
+}
+
+// NewPostgresTableQACache stores answers in the database, so they survive
+// restarts and are shared between instances. now is the clock used for
+// expiry; pass nil to use time.Now.
+func NewPostgresTableQACache(repo repository.TableQACacheRepository, config model.TableQACacheConfig, now func() time.Time) TableQACache {
+	if now == nil {
+		now = time.Now
+	}
+	return &postgresTableQACache{repo, config, now}
+}
+
+func (c *postgresTableQACache) Get(key string) (model.TapasResponse, bool, error) {
+	entry, err := c.repo.GetTableQACacheEntry(key, c.now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.TapasResponse{}, false, nil
+	}
+	if err != nil {
+		return model.TapasResponse{}, false, err
+	}
+	return entry.Response.Data(), true, nil
+}
+
+func (c *postgresTableQACache) Set(key string, response model.TapasResponse) error {
+	now := c.now()
+	entry := &model.TableQACacheEntry{
+		Key:       key,
+		Response:  datatypes.NewJSONType(response),
+		ExpiresAt: now.Add(c.config.TTL),
+		UpdatedAt: now,
+	}
+	if err := c.repo.SaveTableQACacheEntry(entry); err != nil {
+		return err
+	}
+	return c.repo.PruneTableQACache(now, c.config.MaxEntries)
+}
@@ -0,0 +1,229 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"gorm.io/gorm"
+)
+
+type MockTableQACacheRepository struct {
+	GetTableQACacheEntryFunc  func(key string, now time.Time) (model.TableQACacheEntry, error)
+	SaveTableQACacheEntryFunc func(entry *model.TableQACacheEntry) error
+	PruneTableQACacheFunc     func(now time.Time, maxEntries int) error
+}
+
+func (m *MockTableQACacheRepository) GetTableQACacheEntry(key string, now time.Time) (model.TableQACacheEntry, error) {
+	return m.GetTableQACacheEntryFunc(key, now)
+}
+
+func (m *MockTableQACacheRepository) SaveTableQACacheEntry(entry *model.TableQACacheEntry) error {
+	return m.SaveTableQACacheEntryFunc(entry)
+}
+
+func (m *MockTableQACacheRepository) PruneTableQACache(now time.Time, maxEntries int) error {
+	return m.PruneTableQACacheFunc(now, maxEntries)
+}
+
+var _ = Describe("TableQACache", func() {
+	var now time.Time
+
+	config := model.TableQACacheConfig{TTL: time.Hour, MaxEntries: 2}
+	answer := func(text string) model.TapasResponse {
+		return model.TapasResponse{Answer: text, Cells: []string{text}, Aggregator: "NONE"}
+	}
+	clock := func() time.Time { return now }
+
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	})
+
+	Describe("NewTableQACache", func() {
+		It("should disable caching for the none backend", func() {
+			cache, err := service.NewTableQACache(model.TableQACacheConfig{Backend: "none"}, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cache).To(BeNil())
+		})
+
+		It("should reject an unknown backend", func() {
+			_, err := service.NewTableQACache(model.TableQACacheConfig{Backend: "redis"}, nil, nil)
+			Expect(err).To(MatchError(service.ErrUnknownTableQACache))
+		})
+	})
+
+	Describe("memory", func() {
+		var cache service.TableQACache
+
+		BeforeEach(func() {
+			cache = service.NewMemoryTableQACache(config, clock)
+		})
+
+		It("should return what was stored", func() {
+			Expect(cache.Set("a", answer("TV"))).To(Succeed())
+
+			response, ok, err := cache.Get("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(response).To(Equal(answer("TV")))
+		})
+
+		It("should expire entries after the TTL", func() {
+			Expect(cache.Set("a", answer("TV"))).To(Succeed())
+
+			now = now.Add(time.Hour)
+			_, ok, err := cache.Get("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should evict the least recently used entry when full", func() {
+			Expect(cache.Set("a", answer("TV"))).To(Succeed())
+			Expect(cache.Set("b", answer("Fridge"))).To(Succeed())
+			_, _, _ = cache.Get("a")
+			Expect(cache.Set("c", answer("Heater"))).To(Succeed())
+
+			_, ok, _ := cache.Get("b")
+			Expect(ok).To(BeFalse())
+			_, ok, _ = cache.Get("a")
+			Expect(ok).To(BeTrue())
+			_, ok, _ = cache.Get("c")
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Describe("postgres", func() {
+		var (
+			repo  *MockTableQACacheRepository
+			cache service.TableQACache
+		)
+
+		BeforeEach(func() {
+			repo = &MockTableQACacheRepository{}
+			cache = service.NewPostgresTableQACache(repo, config, clock)
+		})
+
+		It("should report a missing entry as a miss", func() {
+			repo.GetTableQACacheEntryFunc = func(key string, at time.Time) (model.TableQACacheEntry, error) {
+				Expect(at).To(Equal(now))
+				return model.TableQACacheEntry{}, gorm.ErrRecordNotFound
+			}
+
+			_, ok, err := cache.Get("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should store entries with their expiry and prune the table", func() {
+			var saved *model.TableQACacheEntry
+			repo.SaveTableQACacheEntryFunc = func(entry *model.TableQACacheEntry) error {
+				saved = entry
+				return nil
+			}
+			pruned := false
+			repo.PruneTableQACacheFunc = func(at time.Time, maxEntries int) error {
+				pruned = true
+				Expect(maxEntries).To(Equal(2))
+				return nil
+			}
+
+			Expect(cache.Set("a", answer("TV"))).To(Succeed())
+			Expect(saved.Key).To(Equal("a"))
+			Expect(saved.Response.Data()).To(Equal(answer("TV")))
+			Expect(saved.ExpiresAt).To(Equal(now.Add(time.Hour)))
+			Expect(pruned).To(BeTrue())
+		})
+	})
+
+	Describe("AIService", func() {
+		var (
+			mockClient *MockHTTPClient
+			aiService  service.AIService
+			calls      int
+		)
+
+		table := map[string][]string{"Appliance": {"TV", "Fridge"}}
+
+		BeforeEach(func() {
+			calls = 0
+			mockClient = &MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					calls++
+					responseBody, _ := json.Marshal(answer("TV"))
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(bytes.NewBuffer(responseBody)),
+					}, nil
+				},
+			}
+			tableQAProvider := service.NewHuggingFaceTableQAProvider(mockClient, model.TableQAConfig{
+				APIKey: "test-token",
+				Model:  "google/tapas-base-finetuned-wtq",
+			})
+			aiService = service.NewAIService(nil, map[string]service.TableQAProvider{
+				"huggingface": tableQAProvider,
+			}, "huggingface", service.NewMemoryTableQACache(config, clock))
+		})
+
+		It("should answer a repeated question from the cache", func() {
+			_, meta, err := aiService.AnalyzeData(context.Background(), table, "checksum", "Which appliance is on?", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta).To(Equal(model.AnalysisMeta{Engine: "huggingface:google/tapas-base-finetuned-wtq", CacheMisses: 1}))
+
+			result, meta, err := aiService.AnalyzeData(context.Background(), table, "checksum", "  which appliance is ON ", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Text).To(Equal("TV"))
+			Expect(meta.CacheHits).To(Equal(1))
+			Expect(calls).To(Equal(1))
+		})
+
+		It("should not share answers between datasets", func() {
+			_, _, err := aiService.AnalyzeData(context.Background(), table, "checksum", "query", "")
+			Expect(err).NotTo(HaveOccurred())
+			_, meta, err := aiService.AnalyzeData(context.Background(), table, "other", "query", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.CacheMisses).To(Equal(1))
+			Expect(calls).To(Equal(2))
+		})
+
+		It("should bypass the cache without a checksum", func() {
+			_, _, _ = aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			_, meta, err := aiService.AnalyzeData(context.Background(), table, "", "query", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.CacheHits + meta.CacheMisses).To(BeZero())
+			Expect(calls).To(Equal(2))
+		})
+
+		It("should report the cache results of every upload query", func() {
+			_, _, err := aiService.AnalyzeFile(context.Background(), table, "checksum", service.DefaultInsightTemplates, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, meta, err := aiService.AnalyzeFile(context.Background(), table, "checksum", service.DefaultInsightTemplates, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.CacheHits).To(Equal(2))
+			Expect(meta.CacheMisses).To(BeZero())
+			Expect(calls).To(Equal(2))
+		})
+
+		It("should not cache failed answers", func() {
+			mockClient.DoFunc = func(req *http.Request) (*http.Response, error) {
+				calls++
+				return nil, errors.New("connection refused")
+			}
+
+			_, _, err := aiService.AnalyzeData(context.Background(), table, "checksum", "query", "")
+			Expect(err).To(HaveOccurred())
+			_, _, err = aiService.AnalyzeData(context.Background(), table, "checksum", "query", "")
+			Expect(err).To(HaveOccurred())
+			Expect(calls).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+var (
+	ErrUnknownTableQAProvider = errors.New("unknown table QA provider")
+	ErrUnansweredQuery        = errors.New("the question could not be answered from this table")
+)
+
+// TableQAProvider answers a natural-language question about a table. Every
+// implementation returns a TAPAS-shaped result: the selected cells plus the
+// aggregator to apply to them.
+type TableQAProvider interface {
+	Name() string
+	Answer(ctx context.Context, table map[string][]string, query string) (model.TapasResponse, error)
+}
+
+type huggingFaceTableQAProvider struct {
+	client HTTPClient
+	config model.TableQAConfig
+}
+
+// NewHuggingFaceTableQAProvider uses a TAPAS model on the Hugging Face
+// inference API.
+func NewHuggingFaceTableQAProvider(client HTTPClient, config model.TableQAConfig) TableQAProvider {
+	return &huggingFaceTableQAProvider{client, config}
+}
+
+func (p *huggingFaceTableQAProvider) Name() string {
+	return "huggingface:" + p.config.Model
+}
+
+func (p *huggingFaceTableQAProvider) Answer(ctx context.Context, table map[string][]string, query string) (model.TapasResponse, error) {
+	ctx, cancel := withTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	url := "https://api-inference.huggingface.co/models/" + p.config.Model
+	requestData := &model.TapasRequest{
+		Inputs: model.Inputs{
+			Table: table,
+			Query: query,
+		},
+	}
+
+	body, err := json.Marshal(*requestData)
+	if err != nil {
+		return model.TapasResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return model.TapasResponse{}, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-wait-for-model", "true")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return model.TapasResponse{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return model.TapasResponse{}, fmt.Errorf("%s: %w", p.Name(), readUpstreamError(res))
+	}
+
+	var tapasRes model.TapasResponse
+	if err := json.NewDecoder(res.Body).Decode(&tapasRes); err != nil {
+		return model.TapasResponse{}, err
+	}
+
+	return tapasRes, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTariffNotFound = errors.New("tariff not found")
+	ErrInvalidTariff  = errors.New("invalid tariff")
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type TariffService interface {
+	GetTariff(userID uint) (model.Tariff, error)
+	SetTariff(userID uint, req model.TariffRequest) (model.Tariff, error)
+	DeleteTariff(userID uint) error
+}
+
+type tariffService struct {
+	tariffRepo    repository.TariffRepository
+	defaultTariff model.Tariff
+}
+
+// NewTariffService creates a TariffService. Users without a tariff of their
+// own are priced with defaultTariff.
+func NewTariffService(tariffRepo repository.TariffRepository, defaultTariff model.Tariff) TariffService {
+	return &tariffService{tariffRepo, defaultTariff}
+}
+
+// GetTariff returns the user's tariff, or the default one if they have none.
+func (s *tariffService) GetTariff(userID uint) (model.Tariff, error) {
+	tariff, err := s.tariffRepo.GetUserTariff(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.defaultTariff, nil
+	}
+	return tariff, err
+}
+
+// SetTariff replaces the user's tariff. Validation failures wrap
+// ErrInvalidTariff with the reason.
+func (s *tariffService) SetTariff(userID uint, req model.TariffRequest) (model.Tariff, error) {
+	tariff := model.Tariff{
+		UserID:             userID,
+		Name:               strings.TrimSpace(req.Name),
+		Currency:           strings.ToUpper(strings.TrimSpace(req.Currency)),
+		Rate:               req.Rate,
+		Tiers:              req.Tiers,
+		Windows:            req.Windows,
+		FixedMonthlyCharge: req.FixedMonthlyCharge,
+	}
+	if err := validateTariff(tariff); err != nil {
+		return model.Tariff{}, err
+	}
+
+	if err := s.tariffRepo.SaveUserTariff(&tariff); err != nil {
+		return model.Tariff{}, err
+	}
+	return tariff, nil
+}
+
+func (s *tariffService) DeleteTariff(userID uint) error {
+	err := s.tariffRepo.DeleteUserTariff(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTariffNotFound
+	}
+	return err
+}
+
+func validateTariff(tariff model.Tariff) error {
+	switch {
+	case len(tariff.Name) > 100:
+		return fmt.Errorf("%w: name must be at most 100 characters", ErrInvalidTariff)
+	case !currencyPattern.MatchString(tariff.Currency):
+		return fmt.Errorf("%w: currency must be a three-letter ISO 4217 code", ErrInvalidTariff)
+	case tariff.Rate < 0 || tariff.FixedMonthlyCharge < 0:
+		return fmt.Errorf("%w: rates and charges can't be negative", ErrInvalidTariff)
+	case len(tariff.Tiers) > 0 && len(tariff.Windows) > 0:
+		return fmt.Errorf("%w: tiers and time-of-use windows can't be combined", ErrInvalidTariff)
+	}
+
+	for i, tier := range tariff.Tiers {
+		last := i == len(tariff.Tiers)-1
+		switch {
+		case tier.Rate < 0:
+			return fmt.Errorf("%w: rates and charges can't be negative", ErrInvalidTariff)
+		case last && tier.UpTo != nil:
+			return fmt.Errorf("%w: the last tier must not have an upper bound", ErrInvalidTariff)
+		case !last && (tier.UpTo == nil || *tier.UpTo <= 0 || (i > 0 && *tier.UpTo <= *tariff.Tiers[i-1].UpTo)):
+			return fmt.Errorf("%w: tier bounds must be positive and increasing", ErrInvalidTariff)
+		}
+	}
+
+	for _, window := range tariff.Windows {
+		start, startErr := time.Parse("15:04", window.Start)
+		end, endErr := time.Parse("15:04", window.End)
+		switch {
+		case startErr != nil || endErr != nil:
+			return fmt.Errorf("%w: window times must be HH:MM", ErrInvalidTariff)
+		case start.Equal(end):
+			return fmt.Errorf("%w: a window can't start and end at the same time", ErrInvalidTariff)
+		case window.Rate < 0:
+			return fmt.Errorf("%w: rates and charges can't be negative", ErrInvalidTariff)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package service_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"gorm.io/gorm"
+)
+
+type MockTariffRepository struct {
+	GetUserTariffFunc    func(userID uint) (model.Tariff, error)
+	SaveUserTariffFunc   func(tariff *model.Tariff) error
+	DeleteUserTariffFunc func(userID uint) error
+}
+
+func (m *MockTariffRepository) GetUserTariff(userID uint) (model.Tariff, error) {
+	return m.GetUserTariffFunc(userID)
+}
+
+func (m *MockTariffRepository) SaveUserTariff(tariff *model.Tariff) error {
+	return m.SaveUserTariffFunc(tariff)
+}
+
+func (m *MockTariffRepository) DeleteUserTariff(userID uint) error {
+	return m.DeleteUserTariffFunc(userID)
+}
+
+var _ = Describe("TariffService", func() {
+	var (
+		mockRepo      *MockTariffRepository
+		tariffService service.TariffService
+	)
+
+	defaultTariff := model.Tariff{Name: "PLN R-1", Currency: "IDR", Rate: 1444.70}
+	bound := func(kWh float64) *float64 { return &kWh }
+
+	BeforeEach(func() {
+		mockRepo = &MockTariffRepository{
+			SaveUserTariffFunc: func(tariff *model.Tariff) error { return nil },
+		}
+		tariffService = service.NewTariffService(mockRepo, defaultTariff)
+	})
+
+	Describe("GetTariff", func() {
+		It("should fall back to the default tariff", func() {
+			mockRepo.GetUserTariffFunc = func(userID uint) (model.Tariff, error) {
+				return model.Tariff{}, gorm.ErrRecordNotFound
+			}
+
+			Expect(tariffService.GetTariff(1)).To(Equal(defaultTariff))
+		})
+	})
+
+	Describe("SetTariff", func() {
+		It("should store a valid tariff for the user", func() {
+			var saved *model.Tariff
+			mockRepo.SaveUserTariffFunc = func(tariff *model.Tariff) error {
+				saved = tariff
+				return nil
+			}
+
+			tariff, err := tariffService.SetTariff(1, model.TariffRequest{
+				Name:     " Home ",
+				Currency: "idr",
+				Tiers:    []model.TariffTier{{UpTo: bound(100), Rate: 1000}, {Rate: 1500}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tariff.Currency).To(Equal("IDR"))
+			Expect(tariff.Name).To(Equal("Home"))
+			Expect(saved.UserID).To(Equal(uint(1)))
+		})
+
+		DescribeTable("should reject invalid tariffs",
+			func(req model.TariffRequest) {
+				_, err := tariffService.SetTariff(1, req)
+				Expect(err).To(MatchError(service.ErrInvalidTariff))
+			},
+			Entry("a bad currency", model.TariffRequest{Currency: "rupiah", Rate: 1}),
+			Entry("a negative rate", model.TariffRequest{Currency: "IDR", Rate: -1}),
+			Entry("a negative fixed charge", model.TariffRequest{Currency: "IDR", FixedMonthlyCharge: -1}),
+			Entry("tiers and windows together", model.TariffRequest{
+				Currency: "IDR",
+				Tiers:    []model.TariffTier{{Rate: 1}},
+				Windows:  []model.TariffWindow{{Start: "17:00", End: "22:00", Rate: 2}},
+			}),
+			Entry("a bounded last tier", model.TariffRequest{Currency: "IDR", Tiers: []model.TariffTier{{UpTo: bound(100), Rate: 1}}}),
+			Entry("decreasing tier bounds", model.TariffRequest{Currency: "IDR", Tiers: []model.TariffTier{
+				{UpTo: bound(100), Rate: 1}, {UpTo: bound(50), Rate: 2}, {Rate: 3},
+			}}),
+			Entry("a malformed window", model.TariffRequest{Currency: "IDR", Windows: []model.TariffWindow{{Start: "5pm", End: "22:00", Rate: 2}}}),
+			Entry("an empty window", model.TariffRequest{Currency: "IDR", Windows: []model.TariffWindow{{Start: "17:00", End: "17:00", Rate: 2}}}),
+		)
+	})
+
+	Describe("DeleteTariff", func() {
+		It("should report a missing tariff", func() {
+			mockRepo.DeleteUserTariffFunc = func(userID uint) error { return gorm.ErrRecordNotFound }
+
+			Expect(tariffService.DeleteTariff(1)).To(MatchError(service.ErrTariffNotFound))
+		})
+	})
+})
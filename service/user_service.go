@@ -2,32 +2,267 @@ package service
 
 import (
 	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
 
 	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/repository"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+	"gorm.io/gorm"
 )
 
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything past 72 bytes
+)
+
+var (
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrUsernameTaken   = errors.New("username is already taken")
+	ErrWeakPassword    = errors.New("password is too weak")
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidRole     = errors.New("invalid role")
+
+	ErrAuthenticationFailed = errors.New("authentication failed")
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidationError wraps the registration errors above together with a
+// message per offending field, suitable for showing next to form inputs.
+type ValidationError struct {
+	Errs   []error
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
 type UserService interface {
 	Register(user model.User) error
-	Login(username, password string) (model.User, error)
+	Login(username, password, ip string) (model.User, error)
+	GetUser(id uint) (model.User, error)
+	ListUsers() ([]model.User, error)
+	SetRole(id uint, role string) error
+	SetDisabled(id uint, disabled bool) error
+	EnsureAdmin(username string) error
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo   repository.UserRepository
+	loginGuard LoginGuard
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo}
+func NewUserService(userRepo repository.UserRepository, loginGuard LoginGuard) UserService {
+	return &userService{userRepo, loginGuard}
 }
 
 func (s *userService) Register(user model.User) error {
-	return s.userRepo.Add(user)
+	user.Username = strings.TrimSpace(user.Username)
+
+	validationErr := &ValidationError{Fields: make(map[string]string)}
+	if msg := validateUsername(user.Username); msg != "" {
+		validationErr.Errs = append(validationErr.Errs, ErrInvalidUsername)
+		validationErr.Fields["username"] = msg
+	}
+	if msg := validatePassword(user.Username, user.Password); msg != "" {
+		validationErr.Errs = append(validationErr.Errs, ErrWeakPassword)
+		validationErr.Fields["password"] = msg
+	}
+	if len(validationErr.Errs) > 0 {
+		return validationErr
+	}
+
+	// Roles are only ever granted by an admin, never at sign-up
+	user.Role = model.RoleUser
+	user.Disabled = false
+
+	taken, err := s.userRepo.UsernameExists(user.Username)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return usernameTakenError()
+	}
+
+	if err := s.userRepo.Add(user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return usernameTakenError()
+		}
+		return err
+	}
+
+	return nil
 }
 
-func (s *userService) Login(username, password string) (model.User, error) {
-	user, err := s.userRepo.Authenticate(username, password)
+// Login checks the credentials, refusing with a *LoginBlockedError while the
+// username or ip is being throttled after earlier failures.
+func (s *userService) Login(username, password, ip string) (model.User, error) {
+	if err := s.loginGuard.Allow(username, ip); err != nil {
+		return model.User{}, err
+	}
+
+	user, err := s.authenticate(username, password)
 	if err != nil {
-		return model.User{}, errors.New("authentication failed")
+		if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, utility.ErrPasswordMismatch) {
+			return model.User{}, err
+		}
+		if err := s.loginGuard.RecordFailure(username, ip); err != nil {
+			return model.User{}, err
+		}
+		return model.User{}, ErrAuthenticationFailed
+	}
+
+	if err := s.loginGuard.RecordSuccess(username); err != nil {
+		return model.User{}, err
+	}
+
+	if user.Disabled {
+		return model.User{}, ErrAccountDisabled
 	}
 	return user, nil
 }
+
+// authenticate checks password against the user's stored password, upgrading
+// legacy plaintext rows and outdated hash costs once it matches. Unknown
+// usernames still pay for a bcrypt comparison, so they can't be told apart
+// by timing.
+func (s *userService) authenticate(username, password string) (model.User, error) {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utility.VerifyDummyPassword(password)
+		}
+		return model.User{}, err
+	}
+
+	needsRehash, err := utility.VerifyPassword(user.Password, password)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if needsRehash {
+		hash, err := utility.HashPassword(password)
+		if err != nil {
+			log.Printf("Rehash password error for user %d: %v", user.ID, err)
+		} else if err := s.userRepo.UpdatePassword(user.ID, hash); err != nil {
+			log.Printf("Update password hash error for user %d: %v", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+func (s *userService) GetUser(id uint) (model.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.User{}, ErrUserNotFound
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (s *userService) ListUsers() ([]model.User, error) {
+	return s.userRepo.List()
+}
+
+func (s *userService) SetRole(id uint, role string) error {
+	if !IsValidRole(role) {
+		return ErrInvalidRole
+	}
+	if _, err := s.GetUser(id); err != nil {
+		return err
+	}
+	return s.userRepo.UpdateRole(id, role)
+}
+
+func (s *userService) SetDisabled(id uint, disabled bool) error {
+	if _, err := s.GetUser(id); err != nil {
+		return err
+	}
+	return s.userRepo.UpdateDisabled(id, disabled)
+}
+
+// EnsureAdmin grants the admin role to an existing user. It is used at
+// startup to bootstrap the first administrator.
+func (s *userService) EnsureAdmin(username string) error {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if user.Role == model.RoleAdmin {
+		return nil
+	}
+	return s.userRepo.UpdateRole(user.ID, model.RoleAdmin)
+}
+
+func IsValidRole(role string) bool {
+	switch role {
+	case model.RoleUser, model.RoleAnalyst, model.RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func usernameTakenError() error {
+	return &ValidationError{Errs: []error{ErrUsernameTaken}, Fields: map[string]string{"username": "Username is already taken"}}
+}
+
+func validateUsername(username string) string {
+	switch {
+	case username == "":
+		return "Username is required"
+	case len(username) < minUsernameLength || len(username) > maxUsernameLength:
+		return "Username must be between 3 and 30 characters"
+	case !usernamePattern.MatchString(username):
+		return "Username may only contain letters, numbers, '.', '_' and '-', and must start with a letter or number"
+	}
+	return ""
+}
+
+func validatePassword(username, password string) string {
+	if len(password) < minPasswordLength {
+		return "Password must be at least 8 characters"
+	}
+	if len(password) > maxPasswordLength {
+		return "Password must be at most 72 characters"
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return "Password must contain at least one letter and one number"
+	}
+
+	if strings.EqualFold(password, username) {
+		return "Password must not be the same as the username"
+	}
+
+	return ""
+}
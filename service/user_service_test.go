@@ -2,35 +2,85 @@ package service_test
 
 import (
 	"errors"
+	"os"
+	"strconv"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/z4fL/fp-ai-golang-neurons/model"
 	"github.com/z4fL/fp-ai-golang-neurons/service"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type MockUserRepository struct {
-	AddFunc          func(user model.User) error
-	AuthenticateFunc func(username, password string) (model.User, error)
+	AddFunc            func(user model.User) error
+	UsernameExistsFunc func(username string) (bool, error)
+	GetByIDFunc        func(id uint) (model.User, error)
+	GetByUsernameFunc  func(username string) (model.User, error)
+	ListFunc           func() ([]model.User, error)
+	UpdateRoleFunc     func(id uint, role string) error
+	UpdateDisabledFunc func(id uint, disabled bool) error
+	UpdatePasswordFunc func(id uint, hash string) error
 }
 
 func (m *MockUserRepository) Add(user model.User) error {
 	return m.AddFunc(user)
 }
 
-func (m *MockUserRepository) Authenticate(username, password string) (model.User, error) {
-	return m.AuthenticateFunc(username, password)
+func (m *MockUserRepository) UsernameExists(username string) (bool, error) {
+	return m.UsernameExistsFunc(username)
+}
+
+func (m *MockUserRepository) GetByID(id uint) (model.User, error) {
+	return m.GetByIDFunc(id)
+}
+
+func (m *MockUserRepository) GetByUsername(username string) (model.User, error) {
+	return m.GetByUsernameFunc(username)
+}
+
+func (m *MockUserRepository) List() ([]model.User, error) {
+	return m.ListFunc()
+}
+
+func (m *MockUserRepository) UpdateRole(id uint, role string) error {
+	return m.UpdateRoleFunc(id, role)
+}
+
+func (m *MockUserRepository) UpdateDisabled(id uint, disabled bool) error {
+	return m.UpdateDisabledFunc(id, disabled)
+}
+
+func (m *MockUserRepository) UpdatePassword(id uint, hash string) error {
+	return m.UpdatePasswordFunc(id, hash)
 }
 
 var _ = Describe("UserService", func() {
 	var (
 		mockRepo    *MockUserRepository
+		lockouts    *[]model.LoginLockout
 		userService service.UserService
 	)
 
 	BeforeEach(func() {
-		mockRepo = &MockUserRepository{}
-		userService = service.NewUserService(mockRepo)
+		mockRepo = &MockUserRepository{
+			UsernameExistsFunc: func(username string) (bool, error) {
+				return false, nil
+			},
+		}
+
+		var attemptRepo *MockLoginAttemptRepository
+		attemptRepo, _, lockouts = newInMemoryLoginAttemptRepository()
+		loginGuard := service.NewLoginGuard(attemptRepo, model.LoginGuardConfig{
+			MaxFailures:      2,
+			MaxFailuresPerIP: 10,
+			LockoutDuration:  time.Minute,
+			FailureWindow:    time.Minute,
+		}, nil)
+		userService = service.NewUserService(mockRepo, loginGuard)
 	})
 
 	Describe("Register", func() {
@@ -39,7 +89,7 @@ var _ = Describe("UserService", func() {
 				return errors.New("registration failed")
 			}
 
-			err := userService.Register(model.User{Username: "testuser", Password: "password"})
+			err := userService.Register(model.User{Username: "testuser", Password: "passw0rd"})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("registration failed"))
 		})
@@ -49,30 +99,244 @@ var _ = Describe("UserService", func() {
 				return nil
 			}
 
-			err := userService.Register(model.User{Username: "testuser", Password: "password"})
+			err := userService.Register(model.User{Username: "testuser", Password: "passw0rd"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should always register new users with the user role", func() {
+			var added model.User
+			mockRepo.AddFunc = func(user model.User) error {
+				added = user
+				return nil
+			}
+
+			err := userService.Register(model.User{Username: "testuser", Password: "passw0rd", Role: model.RoleAdmin})
 			Expect(err).NotTo(HaveOccurred())
+			Expect(added.Role).To(Equal(model.RoleUser))
+		})
+
+		It("should reject an invalid username", func() {
+			err := userService.Register(model.User{Username: "-x", Password: "passw0rd"})
+			Expect(err).To(MatchError(service.ErrInvalidUsername))
+
+			var validationErr *service.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Fields).To(HaveKey("username"))
+		})
+
+		It("should reject a weak password", func() {
+			err := userService.Register(model.User{Username: "testuser", Password: "password"})
+			Expect(err).To(MatchError(service.ErrWeakPassword))
+
+			var validationErr *service.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Fields).To(HaveKey("password"))
+		})
+
+		It("should report every invalid field at once", func() {
+			err := userService.Register(model.User{Username: "-x", Password: "short"})
+			Expect(err).To(MatchError(service.ErrInvalidUsername))
+			Expect(err).To(MatchError(service.ErrWeakPassword))
+
+			var validationErr *service.ValidationError
+			Expect(errors.As(err, &validationErr)).To(BeTrue())
+			Expect(validationErr.Fields).To(HaveKey("username"))
+			Expect(validationErr.Fields).To(HaveKeyWithValue("password", "Password must be at least 8 characters"))
+		})
+
+		It("should return ErrUsernameTaken if the username exists", func() {
+			mockRepo.UsernameExistsFunc = func(username string) (bool, error) {
+				return true, nil
+			}
+
+			err := userService.Register(model.User{Username: "testuser", Password: "passw0rd"})
+			Expect(err).To(MatchError(service.ErrUsernameTaken))
+		})
+
+		It("should map a duplicate key on insert to ErrUsernameTaken", func() {
+			mockRepo.AddFunc = func(user model.User) error {
+				return gorm.ErrDuplicatedKey
+			}
+
+			err := userService.Register(model.User{Username: "testuser", Password: "passw0rd"})
+			Expect(err).To(MatchError(service.ErrUsernameTaken))
 		})
 	})
 
 	Describe("Login", func() {
-		It("should return an error if authentication fails", func() {
-			mockRepo.AuthenticateFunc = func(username, password string) (model.User, error) {
-				return model.User{}, errors.New("authentication failed")
+		var storedUser model.User
+
+		BeforeEach(func() {
+			os.Setenv("PASSWORD_HASH_COST", strconv.Itoa(bcrypt.MinCost))
+			DeferCleanup(os.Unsetenv, "PASSWORD_HASH_COST")
+
+			hash, err := utility.HashPassword("passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			storedUser = model.User{Username: "testuser", Password: hash}
+			storedUser.ID = 1
+
+			mockRepo.GetByUsernameFunc = func(username string) (model.User, error) {
+				if username != storedUser.Username {
+					return model.User{}, gorm.ErrRecordNotFound
+				}
+				return storedUser, nil
 			}
+			mockRepo.UpdatePasswordFunc = func(id uint, hash string) error {
+				Fail("the password hash should not have been rewritten")
+				return nil
+			}
+		})
 
-			_, err := userService.Login("testuser", "wrongpassword")
+		It("should return an error if authentication fails", func() {
+			_, err := userService.Login("testuser", "wrongpassword", "10.0.0.1")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("authentication failed"))
 		})
 
-		It("should login a user successfully", func() {
-			mockRepo.AuthenticateFunc = func(username, password string) (model.User, error) {
-				return model.User{Username: "testuser", Password: "password"}, nil
+		It("should treat an unknown username as a failed attempt", func() {
+			_, err := userService.Login("nobody", "passw0rd", "10.0.0.1")
+			Expect(err).To(MatchError(service.ErrAuthenticationFailed))
+		})
+
+		It("should not count a database error as a failed attempt", func() {
+			mockRepo.GetByUsernameFunc = func(username string) (model.User, error) {
+				return model.User{}, errors.New("connection refused")
 			}
 
-			user, err := userService.Login("testuser", "password")
+			for i := 0; i < 3; i++ {
+				_, err := userService.Login("testuser", "passw0rd", "10.0.0.1")
+				Expect(err).To(MatchError("connection refused"))
+			}
+			Expect(*lockouts).To(BeEmpty())
+		})
+
+		It("should refuse further attempts once the username is locked out", func() {
+			lookups := 0
+			mockRepo.GetByUsernameFunc = func(username string) (model.User, error) {
+				lookups++
+				return storedUser, nil
+			}
+
+			for i := 0; i < 2; i++ {
+				_, err := userService.Login("testuser", "wrongpassword", "10.0.0.1")
+				Expect(err).To(MatchError(service.ErrAuthenticationFailed))
+			}
+
+			_, err := userService.Login("testuser", "passw0rd", "10.0.0.1")
+			Expect(err).To(MatchError(service.ErrAccountLocked))
+			Expect(lookups).To(Equal(2))
+			Expect(*lockouts).To(HaveLen(1))
+		})
+
+		It("should login a user successfully", func() {
+			user, err := userService.Login("testuser", "passw0rd", "10.0.0.1")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(user.Username).To(Equal("testuser"))
 		})
+
+		It("should replace a legacy plaintext password with a hash", func() {
+			storedUser.Password = "passw0rd"
+			var saved string
+			mockRepo.UpdatePasswordFunc = func(id uint, hash string) error {
+				Expect(id).To(Equal(uint(1)))
+				saved = hash
+				return nil
+			}
+
+			_, err := userService.Login("testuser", "passw0rd", "10.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(utility.IsPasswordHash(saved)).To(BeTrue())
+			Expect(utility.VerifyPassword(saved, "passw0rd")).To(BeFalse())
+		})
+
+		It("should rehash a password stored with another cost", func() {
+			os.Setenv("PASSWORD_HASH_COST", strconv.Itoa(bcrypt.MinCost+1))
+			var saved string
+			mockRepo.UpdatePasswordFunc = func(id uint, hash string) error {
+				saved = hash
+				return nil
+			}
+
+			_, err := userService.Login("testuser", "passw0rd", "10.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bcrypt.Cost([]byte(saved))).To(Equal(bcrypt.MinCost + 1))
+		})
+
+		It("should still log in when the upgraded hash can't be saved", func() {
+			storedUser.Password = "passw0rd"
+			mockRepo.UpdatePasswordFunc = func(id uint, hash string) error {
+				return errors.New("connection refused")
+			}
+
+			_, err := userService.Login("testuser", "passw0rd", "10.0.0.1")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should reject a disabled account", func() {
+			storedUser.Disabled = true
+
+			_, err := userService.Login("testuser", "passw0rd", "10.0.0.1")
+			Expect(err).To(MatchError(service.ErrAccountDisabled))
+		})
+	})
+
+	Describe("SetRole", func() {
+		It("should reject an unknown role", func() {
+			err := userService.SetRole(1, "superuser")
+			Expect(err).To(MatchError(service.ErrInvalidRole))
+		})
+
+		It("should return ErrUserNotFound for a missing user", func() {
+			mockRepo.GetByIDFunc = func(id uint) (model.User, error) {
+				return model.User{}, gorm.ErrRecordNotFound
+			}
+
+			err := userService.SetRole(1, model.RoleAnalyst)
+			Expect(err).To(MatchError(service.ErrUserNotFound))
+		})
+
+		It("should update the role of an existing user", func() {
+			mockRepo.GetByIDFunc = func(id uint) (model.User, error) {
+				return model.User{}, nil
+			}
+			var updatedRole string
+			mockRepo.UpdateRoleFunc = func(id uint, role string) error {
+				updatedRole = role
+				return nil
+			}
+
+			err := userService.SetRole(1, model.RoleAnalyst)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedRole).To(Equal(model.RoleAnalyst))
+		})
+	})
+
+	Describe("EnsureAdmin", func() {
+		It("should promote an existing user to admin", func() {
+			mockRepo.GetByUsernameFunc = func(username string) (model.User, error) {
+				user := model.User{Username: username, Role: model.RoleUser}
+				user.ID = 5
+				return user, nil
+			}
+			var promotedID uint
+			mockRepo.UpdateRoleFunc = func(id uint, role string) error {
+				promotedID = id
+				Expect(role).To(Equal(model.RoleAdmin))
+				return nil
+			}
+
+			err := userService.EnsureAdmin("ops")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(promotedID).To(Equal(uint(5)))
+		})
+
+		It("should return ErrUserNotFound if the user does not exist", func() {
+			mockRepo.GetByUsernameFunc = func(username string) (model.User, error) {
+				return model.User{}, gorm.ErrRecordNotFound
+			}
+
+			err := userService.EnsureAdmin("ops")
+			Expect(err).To(MatchError(service.ErrUserNotFound))
+		})
 	})
 })
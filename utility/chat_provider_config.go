@@ -0,0 +1,53 @@
+package utility
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const (
+	defaultChatProvider    = "huggingface"
+	defaultChatModel       = "microsoft/Phi-3.5-mini-instruct"
+	defaultChatTemperature = 0.2
+	defaultChatMaxTokens   = 500
+	defaultChatTimeout     = 2 * time.Minute
+)
+
+// GetChatProviderConfig reads the chat provider settings from the
+// environment. The Hugging Face provider falls back to HUGGINGFACE_TOKEN when
+// CHAT_API_KEY is not set.
+func GetChatProviderConfig() model.ChatProviderConfig {
+	provider := strings.ToLower(os.Getenv("CHAT_PROVIDER"))
+	if provider == "" {
+		provider = defaultChatProvider
+	}
+
+	apiKey := os.Getenv("CHAT_API_KEY")
+	if apiKey == "" && provider == "huggingface" {
+		apiKey = os.Getenv("HUGGINGFACE_TOKEN")
+	}
+
+	chatModel := os.Getenv("CHAT_MODEL")
+	if chatModel == "" {
+		chatModel = defaultChatModel
+	}
+
+	temperature, err := strconv.ParseFloat(os.Getenv("CHAT_TEMPERATURE"), 64)
+	if err != nil || temperature < 0 {
+		temperature = defaultChatTemperature
+	}
+
+	return model.ChatProviderConfig{
+		Provider:    provider,
+		BaseURL:     os.Getenv("CHAT_BASE_URL"),
+		APIKey:      apiKey,
+		Model:       chatModel,
+		Temperature: temperature,
+		MaxTokens:   intFromEnv("CHAT_MAX_TOKENS", defaultChatMaxTokens),
+		Timeout:     durationFromEnv("CHAT_TIMEOUT", defaultChatTimeout),
+	}
+}
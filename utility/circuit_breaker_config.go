@@ -0,0 +1,32 @@
+package utility
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const (
+	defaultAICircuitWindow      = time.Minute
+	defaultAICircuitMinRequests = 5
+	defaultAICircuitFailureRate = 0.5
+	defaultAICircuitCoolDown    = 30 * time.Second
+)
+
+// GetCircuitBreakerConfig reads the circuit breaker settings for model
+// calls from the environment, falling back to the defaults above.
+func GetCircuitBreakerConfig() model.CircuitBreakerConfig {
+	failureRate, err := strconv.ParseFloat(os.Getenv("AI_CIRCUIT_FAILURE_RATE"), 64)
+	if err != nil || failureRate <= 0 || failureRate > 1 {
+		failureRate = defaultAICircuitFailureRate
+	}
+
+	return model.CircuitBreakerConfig{
+		Window:      durationFromEnv("AI_CIRCUIT_WINDOW", defaultAICircuitWindow),
+		MinRequests: intFromEnv("AI_CIRCUIT_MIN_REQUESTS", defaultAICircuitMinRequests),
+		FailureRate: failureRate,
+		CoolDown:    durationFromEnv("AI_CIRCUIT_COOL_DOWN", defaultAICircuitCoolDown),
+	}
+}
@@ -0,0 +1,43 @@
+package utility
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ClientIP returns the address of the client that sent r. X-Forwarded-For is
+// only honored when TRUST_PROXY_HEADERS is "true", since clients can set it
+// to anything when the server is not behind a trusted reverse proxy. Even
+// then only the entry added by the outermost trusted proxy is used, counting
+// TRUSTED_PROXY_HOPS entries from the right, as anything to its left was
+// written by the client.
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if hops := TrustedProxyHops(); hops <= len(forwarded) {
+			if ip := net.ParseIP(strings.TrimSpace(forwarded[len(forwarded)-hops])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// TrustedProxyHops returns how many reverse proxies sit in front of the
+// server, each appending to X-Forwarded-For. It can be set with
+// TRUSTED_PROXY_HOPS and defaults to 1.
+func TrustedProxyHops() int {
+	hops, err := strconv.Atoi(os.Getenv("TRUSTED_PROXY_HOPS"))
+	if err != nil || hops < 1 {
+		return 1
+	}
+	return hops
+}
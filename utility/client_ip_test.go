@@ -0,0 +1,56 @@
+package utility_test
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+var _ = Describe("ClientIP", func() {
+	newRequest := func(forwarded string) string {
+		r := httptest.NewRequest("POST", "/login", nil)
+		r.RemoteAddr = "192.0.2.1:54321"
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+		return utility.ClientIP(r)
+	}
+
+	It("should ignore X-Forwarded-For unless proxy headers are trusted", func() {
+		Expect(newRequest("203.0.113.7")).To(Equal("192.0.2.1"))
+	})
+
+	Context("behind a trusted proxy", func() {
+		BeforeEach(func() {
+			os.Setenv("TRUST_PROXY_HEADERS", "true")
+			DeferCleanup(os.Unsetenv, "TRUST_PROXY_HEADERS")
+		})
+
+		It("should use the address the proxy appended", func() {
+			Expect(newRequest("203.0.113.7")).To(Equal("203.0.113.7"))
+			Expect(newRequest("2001:db8::1")).To(Equal("2001:db8::1"))
+		})
+
+		It("should ignore leading hops forged by the client", func() {
+			Expect(newRequest("198.51.100.99, 203.0.113.7")).To(Equal("203.0.113.7"))
+			Expect(newRequest("198.51.100.1 , 198.51.100.2, 203.0.113.7 ")).To(Equal("203.0.113.7"))
+		})
+
+		It("should skip the hops added by inner proxies", func() {
+			os.Setenv("TRUSTED_PROXY_HOPS", "2")
+			DeferCleanup(os.Unsetenv, "TRUSTED_PROXY_HOPS")
+
+			Expect(newRequest("198.51.100.99, 203.0.113.7, 10.0.0.1")).To(Equal("203.0.113.7"))
+			Expect(newRequest("10.0.0.1")).To(Equal("192.0.2.1"))
+		})
+
+		It("should fall back to the remote address when the header is not an IP", func() {
+			Expect(newRequest(strings.Repeat("a", 100))).To(Equal("192.0.2.1"))
+			Expect(newRequest("203.0.113.7, unknown")).To(Equal("192.0.2.1"))
+		})
+	})
+})
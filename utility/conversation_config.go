@@ -0,0 +1,17 @@
+package utility
+
+import "github.com/z4fL/fp-ai-golang-neurons/model"
+
+const (
+	defaultChatHistoryTokens = 1500
+	defaultChatSummaryTokens = 200
+)
+
+// GetConversationConfig reads the chat history budgets from the
+// environment, falling back to the defaults above.
+func GetConversationConfig() model.ConversationConfig {
+	return model.ConversationConfig{
+		HistoryTokens: intFromEnv("CHAT_HISTORY_TOKENS", defaultChatHistoryTokens),
+		SummaryTokens: intFromEnv("CHAT_SUMMARY_TOKENS", defaultChatSummaryTokens),
+	}
+}
@@ -0,0 +1,19 @@
+package utility
+
+import (
+	"os"
+	"time"
+)
+
+// defaultDataTimeZone matches the TimeZone db.Connect gives the database.
+const defaultDataTimeZone = "Asia/Jakarta"
+
+// GetDataLocation returns the DATA_TIMEZONE location, in which dataset
+// timestamps without an offset are read and time series are bucketed.
+func GetDataLocation() (*time.Location, error) {
+	name := os.Getenv("DATA_TIMEZONE")
+	if name == "" {
+		name = defaultDataTimeZone
+	}
+	return time.LoadLocation(name)
+}
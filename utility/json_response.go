@@ -18,3 +18,18 @@ func JSONResponse(w http.ResponseWriter, statusCode int, status string, answer a
 
 	json.NewEncoder(w).Encode(&response)
 }
+
+// JSONResponseWithMeta is JSONResponse with metadata about how the answer was
+// produced, sent alongside it as "meta".
+func JSONResponseWithMeta(w http.ResponseWriter, statusCode int, status string, answer, meta any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := model.Response{
+		Status: status,
+		Answer: answer,
+		Meta:   meta,
+	}
+
+	json.NewEncoder(w).Encode(&response)
+}
@@ -0,0 +1,39 @@
+package utility
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const (
+	defaultLoginMaxFailures      = 5
+	defaultLoginMaxFailuresPerIP = 20
+	defaultLoginBackoffBase      = time.Second
+	defaultLoginBackoffMax       = 30 * time.Second
+	defaultLoginLockoutDuration  = 15 * time.Minute
+	defaultLoginFailureWindow    = 15 * time.Minute
+)
+
+// GetLoginGuardConfig reads the login throttling settings from the
+// environment, falling back to the defaults above.
+func GetLoginGuardConfig() model.LoginGuardConfig {
+	return model.LoginGuardConfig{
+		MaxFailures:      intFromEnv("LOGIN_MAX_FAILURES", defaultLoginMaxFailures),
+		MaxFailuresPerIP: intFromEnv("LOGIN_MAX_FAILURES_PER_IP", defaultLoginMaxFailuresPerIP),
+		BaseDelay:        durationFromEnv("LOGIN_BACKOFF_BASE", defaultLoginBackoffBase),
+		MaxDelay:         durationFromEnv("LOGIN_BACKOFF_MAX", defaultLoginBackoffMax),
+		LockoutDuration:  durationFromEnv("LOGIN_LOCKOUT_DURATION", defaultLoginLockoutDuration),
+		FailureWindow:    durationFromEnv("LOGIN_FAILURE_WINDOW", defaultLoginFailureWindow),
+	}
+}
+
+func intFromEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,202 @@
+package utility
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrEmptyCell       = errors.New("empty cell")
+	ErrNotANumber      = errors.New("not a number")
+	ErrMalformedNumber = errors.New("misplaced thousands separator")
+	ErrUnknownUnit     = errors.New("unknown unit")
+)
+
+// numberPattern splits a cell into sign, digits with their separators, and
+// an optional unit or percent sign.
+var numberPattern = regexp.MustCompile(`^([+-]?)\s*(\d[\d.,' \x{00a0}\x{202f}]*?)\s*(%|[a-zA-Z]+)?$`)
+
+// digitGroupSpaces are the characters some locales use to group thousands.
+var digitGroupSpaces = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "")
+
+// unitPrefixes scales the energy and power units by their SI prefix. The
+// prefix is case-sensitive, since "mWh" and "MWh" are 10⁹ apart; "K" is
+// accepted for kilo as it means nothing else here.
+var unitPrefixes = map[string]float64{"": 1, "m": 0.001, "k": 1000, "K": 1000, "M": 1000000, "G": 1000000000}
+
+// unitSymbols are the energy and power units, matched in any case, with the
+// unit they are normalized to. Watt-hours come first so "Wh" isn't read as W.
+var unitSymbols = []struct{ symbol, unit string }{
+	{"wh", "kWh"},
+	{"w", "kW"},
+}
+
+// Number is a parsed cell. Energy is expressed in kWh and power in kW;
+// other units are kept as written.
+type Number struct {
+	Value float64
+	Unit  string
+}
+
+// ParseNumber reads cells such as "1,234.5", "1.234,5", "3,5", "12 kWh",
+// "1 500 W" or "45%". decimalMark ('.' or ',') settles a lone separator
+// followed by exactly three digits, like "1,234", which is a thousands
+// separator unless it is the decimal mark; see DetectDecimalMark.
+func ParseNumber(cell string, decimalMark rune) (Number, error) {
+	sign, digits, unit, err := splitNumber(cell)
+	if err != nil {
+		return Number{}, err
+	}
+
+	normalized, err := normalizeDigits(digits, decimalMark)
+	if err != nil {
+		return Number{}, err
+	}
+
+	value, err := strconv.ParseFloat(sign+normalized, 64)
+	if err != nil {
+		return Number{}, ErrNotANumber
+	}
+
+	if _, _, ok := canonicalUnit(unit); unit != "" && !ok {
+		return Number{}, ErrUnknownUnit
+	}
+	return ConvertUnit(value, unit), nil
+}
+
+// ConvertUnit normalizes value given in unit; see Number. Units other than
+// energy, power and percentages are kept as written.
+func ConvertUnit(value float64, unit string) Number {
+	if canonical, factor, ok := canonicalUnit(unit); ok {
+		return Number{value * factor, canonical}
+	}
+	return Number{value, unit}
+}
+
+// canonicalUnit returns the unit a value in unit is normalized to and the
+// factor to get there, if unit is a percentage or an energy or power unit.
+func canonicalUnit(unit string) (string, float64, bool) {
+	if unit == "%" {
+		return "%", 1, true
+	}
+	for _, s := range unitSymbols {
+		if len(unit) < len(s.symbol) || !strings.EqualFold(unit[len(unit)-len(s.symbol):], s.symbol) {
+			continue
+		}
+		if scale, ok := unitPrefixes[unit[:len(unit)-len(s.symbol)]]; ok {
+			// Normalized units are kilo
+			return s.unit, scale / 1000, true
+		}
+	}
+	return "", 0, false
+}
+
+// DetectDecimalMark guesses the decimal mark used by cells from the ones
+// that are unambiguous, such as "3,5" or "1.234,56", falling back to '.'.
+func DetectDecimalMark(cells []string) rune {
+	dot, comma := 0, 0
+	for _, cell := range cells {
+		_, digits, _, err := splitNumber(cell)
+		if err != nil {
+			continue
+		}
+
+		switch decimal, _ := separators(digits, 0); decimal {
+		case ".":
+			dot++
+		case ",":
+			comma++
+		}
+	}
+
+	if comma > dot {
+		return ','
+	}
+	return '.'
+}
+
+func splitNumber(cell string) (sign, digits, unit string, err error) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return "", "", "", ErrEmptyCell
+	}
+
+	match := numberPattern.FindStringSubmatch(cell)
+	if match == nil {
+		return "", "", "", ErrNotANumber
+	}
+	return match[1], digitGroupSpaces.Replace(match[2]), match[3], nil
+}
+
+// separators works out which of '.' and ',' in digits is the decimal mark
+// and which groups thousands. Either may be "". decimalMark 0 leaves an
+// ambiguous separator undecided.
+func separators(digits string, decimalMark rune) (decimal, group string) {
+	lastDot, lastComma := strings.LastIndex(digits, "."), strings.LastIndex(digits, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			return ".", ","
+		}
+		return ",", "."
+	case lastComma >= 0:
+		return loneSeparator(digits, ",", decimalMark)
+	case lastDot >= 0:
+		return loneSeparator(digits, ".", decimalMark)
+	}
+	return "", ""
+}
+
+// loneSeparator decides what sep means when it is the only kind of
+// separator in digits.
+func loneSeparator(digits, sep string, decimalMark rune) (decimal, group string) {
+	if strings.Count(digits, sep) > 1 {
+		return "", sep
+	}
+
+	before, after, _ := strings.Cut(digits, sep)
+	if len(after) != 3 || before == "0" {
+		return sep, ""
+	}
+
+	// "1,234" or "1.234"
+	switch decimalMark {
+	case rune(sep[0]):
+		return sep, ""
+	case 0:
+		return "", ""
+	}
+	return "", sep
+}
+
+// normalizeDigits rewrites digits in the form strconv.ParseFloat expects.
+func normalizeDigits(digits string, decimalMark rune) (string, error) {
+	decimal, group := separators(digits, decimalMark)
+
+	integer, fraction := digits, ""
+	if decimal != "" {
+		i := strings.LastIndex(digits, decimal)
+		integer, fraction = digits[:i], digits[i+1:]
+		if fraction == "" || strings.ContainsAny(fraction, ".,") {
+			return "", ErrNotANumber
+		}
+	}
+
+	if group != "" {
+		groups := strings.Split(integer, group)
+		for i, g := range groups {
+			if strings.ContainsAny(g, ".,") || (i == 0 && (len(g) == 0 || len(g) > 3)) || (i > 0 && len(g) != 3) {
+				return "", ErrMalformedNumber
+			}
+		}
+		integer = strings.Join(groups, "")
+	}
+
+	if fraction == "" {
+		return integer, nil
+	}
+	return fmt.Sprintf("%s.%s", integer, fraction), nil
+}
@@ -0,0 +1,76 @@
+package utility_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+var _ = Describe("ParseNumber", func() {
+	DescribeTable("parses",
+		func(cell string, decimalMark rune, value float64, unit string) {
+			number, err := utility.ParseNumber(cell, decimalMark)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number.Value).To(BeNumerically("~", value, 1e-9))
+			Expect(number.Unit).To(Equal(unit))
+		},
+		Entry("an integer", "42", '.', 42.0, ""),
+		Entry("a plain decimal", "3.5", '.', 3.5, ""),
+		Entry("a negative number", "-1.25", '.', -1.25, ""),
+		Entry("an explicit plus sign", "+7", '.', 7.0, ""),
+		Entry("surrounding spaces", "  8 ", '.', 8.0, ""),
+		Entry("English thousands", "1,234.5", '.', 1234.5, ""),
+		Entry("several English thousands", "1,234,567", '.', 1234567.0, ""),
+		Entry("Indonesian thousands", "1.234,5", ',', 1234.5, ""),
+		Entry("several Indonesian thousands", "1.234.567", ',', 1234567.0, ""),
+		Entry("an Indonesian decimal comma", "3,5", '.', 3.5, ""),
+		Entry("a decimal comma after zero", "0,125", '.', 0.125, ""),
+		Entry("an ambiguous comma with a dot decimal mark", "1,234", '.', 1234.0, ""),
+		Entry("an ambiguous comma with a comma decimal mark", "1,234", ',', 1.234, ""),
+		Entry("an ambiguous dot with a comma decimal mark", "1.234", ',', 1234.0, ""),
+		Entry("an ambiguous dot with a dot decimal mark", "1.234", '.', 1.234, ""),
+		Entry("space grouping", "1 234,5", ',', 1234.5, ""),
+		Entry("no-break space grouping", "1 234", '.', 1234.0, ""),
+		Entry("apostrophe grouping", "1'234.5", '.', 1234.5, ""),
+		Entry("a percentage", "45%", '.', 45.0, "%"),
+		Entry("a spaced percentage", "12,5 %", ',', 12.5, "%"),
+		Entry("kWh", "12 kWh", '.', 12.0, "kWh"),
+		Entry("lower-case kwh", "12kwh", '.', 12.0, "kWh"),
+		Entry("Wh to kWh", "1,500 Wh", '.', 1.5, "kWh"),
+		Entry("MWh to kWh", "2.5 MWh", '.', 2500.0, "kWh"),
+		Entry("GWh to kWh", "1 GWh", '.', 1000000.0, "kWh"),
+		Entry("W to kW", "750 W", '.', 0.75, "kW"),
+		Entry("kW", "3,2 kW", ',', 3.2, "kW"),
+		Entry("MW to kW", "1.2 MW", '.', 1200.0, "kW"),
+		Entry("upper-case KWH", "12 KWH", '.', 12.0, "kWh"),
+		Entry("mWh as milliwatt-hours", "500 mWh", '.', 0.0005, "kWh"),
+		Entry("mW as milliwatts", "250 mW", '.', 0.00025, "kW"),
+	)
+
+	DescribeTable("rejects",
+		func(cell string, expected error) {
+			_, err := utility.ParseNumber(cell, '.')
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("an empty cell", "   ", utility.ErrEmptyCell),
+		Entry("text", "n/a", utility.ErrNotANumber),
+		Entry("a unit alone", "kWh", utility.ErrNotANumber),
+		Entry("a trailing separator", "12.", utility.ErrNotANumber),
+		Entry("scattered separators", "1.2.3,4,5", utility.ErrMalformedNumber),
+		Entry("a short thousands group", "1,23,456", utility.ErrMalformedNumber),
+		Entry("a long leading group", "1234,567,890", utility.ErrMalformedNumber),
+		Entry("an unknown trailing word", "3 pcs", utility.ErrUnknownUnit),
+		Entry("a prefix that isn't SI", "2 gwh", utility.ErrUnknownUnit),
+	)
+
+	DescribeTable("DetectDecimalMark",
+		func(cells []string, mark rune) {
+			Expect(utility.DetectDecimalMark(cells)).To(Equal(mark))
+		},
+		Entry("dot decimals", []string{"1.5", "2.25"}, '.'),
+		Entry("comma decimals", []string{"1,5", "2,25"}, ','),
+		Entry("Indonesian thousands with decimals", []string{"1.234,5", "1,234"}, ','),
+		Entry("only ambiguous cells", []string{"1,234", "5,678"}, '.'),
+		Entry("no numbers", []string{"TV", ""}, '.'),
+	)
+})
@@ -0,0 +1,85 @@
+package utility
+
+import (
+	"crypto/subtle"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// PasswordHashCost returns the bcrypt cost used for new hashes. It can be
+// tuned with PASSWORD_HASH_COST and falls back to bcrypt.DefaultCost.
+func PasswordHashCost() int {
+	cost, err := strconv.Atoi(os.Getenv("PASSWORD_HASH_COST"))
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash reports whether stored looks like a bcrypt hash rather than
+// a legacy plaintext password.
+func IsPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// VerifyPassword checks password against the stored value, which may be a
+// bcrypt hash or a legacy plaintext password. needsRehash is true when the
+// password matched but the stored value should be replaced by a fresh hash,
+// either because it is plaintext or because its cost differs from
+// PasswordHashCost.
+func VerifyPassword(stored, password string) (needsRehash bool, err error) {
+	if !IsPasswordHash(stored) {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+			return false, ErrPasswordMismatch
+		}
+		return true, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, ErrPasswordMismatch
+	}
+
+	cost, err := bcrypt.Cost([]byte(stored))
+	if err != nil {
+		return false, err
+	}
+
+	return cost != PasswordHashCost(), nil
+}
+
+// dummyHashes holds a bcrypt hash per cost for VerifyDummyPassword.
+var dummyHashes sync.Map
+
+// VerifyDummyPassword compares password against a fixed hash at
+// PasswordHashCost and always fails with ErrPasswordMismatch. Logins for
+// unknown users call it so they take as long as a wrong password, and
+// response times don't reveal which usernames exist.
+func VerifyDummyPassword(password string) error {
+	cost := PasswordHashCost()
+	hash, ok := dummyHashes.Load(cost)
+	if !ok {
+		generated, err := bcrypt.GenerateFromPassword([]byte("not a real password"), cost)
+		if err != nil {
+			return err
+		}
+		hash, _ = dummyHashes.LoadOrStore(cost, generated)
+	}
+
+	bcrypt.CompareHashAndPassword(hash.([]byte), []byte(password))
+	return ErrPasswordMismatch
+}
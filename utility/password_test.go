@@ -0,0 +1,91 @@
+package utility_test
+
+import (
+	"os"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("Password", func() {
+	BeforeEach(func() {
+		os.Setenv("PASSWORD_HASH_COST", strconv.Itoa(bcrypt.MinCost))
+		DeferCleanup(os.Unsetenv, "PASSWORD_HASH_COST")
+	})
+
+	Describe("PasswordHashCost", func() {
+		It("should fall back to the default cost when unset or out of range", func() {
+			os.Setenv("PASSWORD_HASH_COST", "99")
+			Expect(utility.PasswordHashCost()).To(Equal(bcrypt.DefaultCost))
+
+			os.Unsetenv("PASSWORD_HASH_COST")
+			Expect(utility.PasswordHashCost()).To(Equal(bcrypt.DefaultCost))
+		})
+	})
+
+	Describe("HashPassword", func() {
+		It("should produce a bcrypt hash at the configured cost", func() {
+			hash, err := utility.HashPassword("passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).NotTo(Equal("passw0rd"))
+			Expect(utility.IsPasswordHash(hash)).To(BeTrue())
+			Expect(bcrypt.Cost([]byte(hash))).To(Equal(bcrypt.MinCost))
+		})
+	})
+
+	Describe("IsPasswordHash", func() {
+		It("should tell bcrypt hashes from plaintext", func() {
+			Expect(utility.IsPasswordHash("$2a$04$abcdefghijklmnopqrstuv")).To(BeTrue())
+			Expect(utility.IsPasswordHash("$2y$10$abcdefghijklmnopqrstuv")).To(BeTrue())
+			Expect(utility.IsPasswordHash("passw0rd")).To(BeFalse())
+		})
+	})
+
+	Describe("VerifyPassword", func() {
+		It("should accept the right password for a current hash", func() {
+			hash, err := utility.HashPassword("passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+
+			needsRehash, err := utility.VerifyPassword(hash, "passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(needsRehash).To(BeFalse())
+		})
+
+		It("should reject the wrong password for a hash", func() {
+			hash, err := utility.HashPassword("passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = utility.VerifyPassword(hash, "wrongpassword")
+			Expect(err).To(MatchError(utility.ErrPasswordMismatch))
+		})
+
+		It("should accept a legacy plaintext password and ask for a rehash", func() {
+			needsRehash, err := utility.VerifyPassword("passw0rd", "passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(needsRehash).To(BeTrue())
+
+			_, err = utility.VerifyPassword("passw0rd", "Passw0rd")
+			Expect(err).To(MatchError(utility.ErrPasswordMismatch))
+		})
+
+		It("should ask for a rehash once the configured cost changes", func() {
+			hash, err := utility.HashPassword("passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+
+			os.Setenv("PASSWORD_HASH_COST", strconv.Itoa(bcrypt.MinCost+1))
+			needsRehash, err := utility.VerifyPassword(hash, "passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(needsRehash).To(BeTrue())
+		})
+	})
+
+	Describe("VerifyDummyPassword", func() {
+		It("should reject every password", func() {
+			Expect(utility.VerifyDummyPassword("passw0rd")).To(MatchError(utility.ErrPasswordMismatch))
+			Expect(utility.VerifyDummyPassword("not a real password")).To(MatchError(utility.ErrPasswordMismatch))
+		})
+	})
+})
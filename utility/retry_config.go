@@ -0,0 +1,25 @@
+package utility
+
+import (
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const (
+	defaultAIRetryMaxAttempts = 4
+	defaultAIRetryBaseDelay   = 500 * time.Millisecond
+	defaultAIRetryMaxDelay    = 10 * time.Second
+	defaultAIRetryBudget      = 30 * time.Second
+)
+
+// GetRetryConfig reads the retry settings for model calls from the
+// environment, falling back to the defaults above.
+func GetRetryConfig() model.RetryConfig {
+	return model.RetryConfig{
+		MaxAttempts: intFromEnv("AI_RETRY_MAX_ATTEMPTS", defaultAIRetryMaxAttempts),
+		BaseDelay:   durationFromEnv("AI_RETRY_BASE_DELAY", defaultAIRetryBaseDelay),
+		MaxDelay:    durationFromEnv("AI_RETRY_MAX_DELAY", defaultAIRetryMaxDelay),
+		Budget:      durationFromEnv("AI_RETRY_BUDGET", defaultAIRetryBudget),
+	}
+}
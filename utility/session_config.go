@@ -0,0 +1,34 @@
+package utility
+
+import (
+	"os"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const (
+	defaultSessionIdleTimeout      = 30 * time.Minute
+	defaultSessionAbsoluteLifetime = 5 * time.Hour
+	defaultRefreshTokenLifetime    = 7 * 24 * time.Hour
+	defaultSessionReaperInterval   = 10 * time.Minute
+)
+
+// GetSessionConfig reads session lifetimes from the environment as Go
+// durations (e.g. "30m", "5h"), falling back to the defaults above.
+func GetSessionConfig() model.SessionConfig {
+	return model.SessionConfig{
+		IdleTimeout:      durationFromEnv("SESSION_IDLE_TIMEOUT", defaultSessionIdleTimeout),
+		AbsoluteLifetime: durationFromEnv("SESSION_ABSOLUTE_LIFETIME", defaultSessionAbsoluteLifetime),
+		RefreshLifetime:  durationFromEnv("REFRESH_TOKEN_LIFETIME", defaultRefreshTokenLifetime),
+		ReaperInterval:   durationFromEnv("SESSION_REAPER_INTERVAL", defaultSessionReaperInterval),
+	}
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
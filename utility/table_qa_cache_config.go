@@ -0,0 +1,30 @@
+package utility
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const (
+	defaultTableQACacheBackend    = "memory"
+	defaultTableQACacheTTL        = 24 * time.Hour
+	defaultTableQACacheMaxEntries = 1000
+)
+
+// GetTableQACacheConfig reads the table QA cache settings from the
+// environment, falling back to the defaults above.
+func GetTableQACacheConfig() model.TableQACacheConfig {
+	backend := strings.ToLower(os.Getenv("TABLE_QA_CACHE"))
+	if backend == "" {
+		backend = defaultTableQACacheBackend
+	}
+
+	return model.TableQACacheConfig{
+		Backend:    backend,
+		TTL:        durationFromEnv("TABLE_QA_CACHE_TTL", defaultTableQACacheTTL),
+		MaxEntries: intFromEnv("TABLE_QA_CACHE_MAX_ENTRIES", defaultTableQACacheMaxEntries),
+	}
+}
@@ -0,0 +1,36 @@
+package utility
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+const (
+	defaultTableQAProvider = "huggingface"
+	defaultTableQAModel    = "google/tapas-base-finetuned-wtq"
+	defaultTableQATimeout  = time.Minute
+)
+
+// GetTableQAConfig reads the table QA settings from the environment. The
+// TAPAS model is called with HUGGINGFACE_TOKEN.
+func GetTableQAConfig() model.TableQAConfig {
+	provider := strings.ToLower(os.Getenv("TABLE_QA_PROVIDER"))
+	if provider == "" {
+		provider = defaultTableQAProvider
+	}
+
+	tableQAModel := os.Getenv("TABLE_QA_MODEL")
+	if tableQAModel == "" {
+		tableQAModel = defaultTableQAModel
+	}
+
+	return model.TableQAConfig{
+		Provider: provider,
+		Model:    tableQAModel,
+		APIKey:   os.Getenv("HUGGINGFACE_TOKEN"),
+		Timeout:  durationFromEnv("TABLE_QA_TIMEOUT", defaultTableQATimeout),
+	}
+}
@@ -1,103 +1,263 @@
 package utility
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
 )
 
 const ErrNoNumericValuesFound = "no numeric values found"
 
+var (
+	ErrNoNumericValues   = errors.New(ErrNoNumericValuesFound)
+	ErrNoCells           = errors.New("no cells were selected")
+	ErrUnknownAggregator = errors.New("unknown aggregator")
+)
+
+// unitPattern finds a unit written after a column name, as in
+// "Energy_Consumption (kWh)" or "Cost [USD]".
+var unitPattern = regexp.MustCompile(`[(\[]\s*([^()\[\]]+?)\s*[)\]]\s*$`)
+
+// TapasProcessor applies a TAPAS aggregator to the selected cells. Columns
+// lists the table's column names in the order the coordinates refer to; it
+// is only needed to report a unit. Cells are parsed with ParseNumber, using
+// DecimalMark or, when it is 0, the mark DetectDecimalMark finds.
 type TapasProcessor struct {
-	Cells []string
+	Cells       []string
+	Coordinates [][]int
+	Columns     []string
+	DecimalMark rune
 }
 
-func (tp *TapasProcessor) CountUniqueCells() (int, string) {
-	result := make(map[string]bool)
-	for _, room := range tp.Cells {
-		result[room] = true
+// Aggregate applies aggregator (NONE, COUNT, SUM, AVERAGE, MIN or MAX) to the
+// cells and describes the result. It fails with ErrNoCells when nothing was
+// selected, ErrNoNumericValues when a numeric aggregator has no numbers to
+// work on, and ErrUnknownAggregator for anything else.
+func (tp *TapasProcessor) Aggregate(aggregator string) (model.TableAnswer, error) {
+	answer := model.TableAnswer{
+		Aggregator:  aggregator,
+		Cells:       tp.Cells,
+		Coordinates: tp.Coordinates,
+	}
+	if len(tp.Cells) == 0 {
+		return answer, ErrNoCells
 	}
 
-	var uniqueValue []string
-	for value := range result {
-		uniqueValue = append(uniqueValue, value)
+	var label string
+	switch aggregator {
+	case "NONE":
+		answer.Unit = tp.Unit()
+		answer.Text = strings.Join(uniqueCells(tp.Cells), ", ")
+		if len(tp.Cells) == 1 && answer.Unit != "" {
+			answer.Text += " " + answer.Unit
+		}
+		return answer, nil
+	case "COUNT":
+		count, list := tp.CountUniqueCells()
+		value := float64(count)
+		answer.Value = &value
+		answer.Text = fmt.Sprintf("Count: %d, List: %s", count, list)
+		return answer, nil
+	case "SUM":
+		label = "Sum"
+	case "AVERAGE":
+		label = "Average"
+	case "MIN":
+		label = "Min"
+	case "MAX":
+		label = "Max"
+	default:
+		return answer, fmt.Errorf("%w: %q", ErrUnknownAggregator, aggregator)
 	}
 
-	sort.Strings(uniqueValue) // Sort the unique values alphabetically
+	numbers, unit, skipped := tp.numbers()
+	answer.Skipped = skipped
+	if len(numbers) == 0 {
+		return answer, ErrNoNumericValues
+	}
+
+	value := aggregate(aggregator, numbers)
+	answer.Value = &value
+	answer.Unit = unit
+	answer.Text = label + ": " + FormatNumber(value)
+	if unit != "" {
+		answer.Text += " " + unit
+	}
+	return answer, nil
+}
 
-	var builder strings.Builder
-	for i, value := range uniqueValue {
-		if i > 0 {
-			builder.WriteString(", ")
+// Column returns the name of the column the cells come from, or "" if they
+// span several columns.
+func (tp *TapasProcessor) Column() string {
+	column := -1
+	for _, coordinate := range tp.Coordinates {
+		if len(coordinate) != 2 || (column != -1 && coordinate[1] != column) {
+			return ""
 		}
-		builder.WriteString(value)
+		column = coordinate[1]
 	}
-	valueList := builder.String()
+	if column < 0 || column >= len(tp.Columns) {
+		return ""
+	}
+	return tp.Columns[column]
+}
 
-	return len(result), valueList // Number of unique items
+// Unit returns the unit named in the header of the column the cells come
+// from, or "" if they span several columns or the header names none.
+func (tp *TapasProcessor) Unit() string {
+	return ColumnUnit(tp.Column())
 }
 
-func (tp *TapasProcessor) Sum() float64 {
-	total := 0.0
-	for _, cell := range tp.Cells {
-		if num, err := strconv.ParseFloat(cell, 64); err == nil {
-			total += num
-		}
+// ColumnUnit returns the unit named at the end of a column header, as in
+// "Energy_Consumption (kWh)", or "".
+func ColumnUnit(column string) string {
+	if match := unitPattern.FindStringSubmatch(column); match != nil {
+		return match[1]
 	}
-	return total
+	return ""
+}
+
+func (tp *TapasProcessor) CountUniqueCells() (int, string) {
+	uniqueValue := uniqueCells(tp.Cells)
+	sort.Strings(uniqueValue) // Sort the unique values alphabetically
+
+	return len(uniqueValue), strings.Join(uniqueValue, ", ") // Number of unique items
 }
 
+// Sum adds up the numeric cells, ignoring the rest.
+func (tp *TapasProcessor) Sum() float64 {
+	numbers, _, _ := tp.numbers()
+	return aggregate("SUM", numbers)
+}
+
+// Average returns the mean of the numeric cells, or 0 if there are none.
 func (tp *TapasProcessor) Average() float64 {
-	total := 0.0
-	count := 0
-	for _, cell := range tp.Cells {
-		if num, err := strconv.ParseFloat(cell, 64); err == nil {
-			total += num
-			count++
-		} else {
-			fmt.Printf("Error parsing cell value '%s': %v\n", cell, err)
-		}
-	}
-	if count == 0 {
-		return 0.0
+	numbers, _, _ := tp.numbers()
+	if len(numbers) == 0 {
+		return 0
 	}
-	return float64(total) / float64(count)
+	return aggregate("AVERAGE", numbers)
 }
 
 // Max returns the maximum numeric value from the Cells slice.
 // If no numeric values are found, it returns an error.
 func (tp *TapasProcessor) Max() (float64, error) {
-	maxValue := math.Inf(-1)
-	found := false
-	for _, cell := range tp.Cells {
-		if num, err := strconv.ParseFloat(cell, 64); err == nil {
-			if !found || num > maxValue {
-				maxValue = num
-				found = true
-			}
+	numbers, _, _ := tp.numbers()
+	if len(numbers) == 0 {
+		return 0, ErrNoNumericValues
+	}
+	return aggregate("MAX", numbers), nil
+}
+
+func (tp *TapasProcessor) Min() (float64, error) {
+	numbers, _, _ := tp.numbers()
+	if len(numbers) == 0 {
+		return 0, ErrNoNumericValues
+	}
+	return aggregate("MIN", numbers), nil
+}
+
+// numbers parses the cells and returns the values of those in the most
+// common unit, that unit, and the other cells with the reason they were left
+// out. Cells without a unit take the one in the column header.
+func (tp *TapasProcessor) numbers() ([]float64, string, []model.SkippedCell) {
+	decimalMark := tp.DecimalMark
+	if decimalMark == 0 {
+		decimalMark = DetectDecimalMark(tp.Cells)
+	}
+	headerUnit := tp.Unit()
+
+	parsed := make([]Number, len(tp.Cells))
+	errs := make([]error, len(tp.Cells))
+	unitCounts := make(map[string]int)
+	for i, cell := range tp.Cells {
+		parsed[i], errs[i] = ParseNumber(cell, decimalMark)
+		if errs[i] != nil {
+			continue
 		}
+		if parsed[i].Unit == "" && headerUnit != "" {
+			parsed[i] = ConvertUnit(parsed[i].Value, headerUnit)
+		}
+		unitCounts[parsed[i].Unit]++
 	}
-	if !found {
-		return 0, fmt.Errorf(ErrNoNumericValuesFound)
+
+	// The most common unit wins; ties go to the one seen first
+	unit, best := "", 0
+	for i := range parsed {
+		if errs[i] == nil && unitCounts[parsed[i].Unit] > best {
+			unit, best = parsed[i].Unit, unitCounts[parsed[i].Unit]
+		}
 	}
-	return maxValue, nil
+
+	var numbers []float64
+	var skipped []model.SkippedCell
+	for i, cell := range tp.Cells {
+		switch {
+		case errs[i] != nil:
+			skipped = append(skipped, model.SkippedCell{Cell: cell, Reason: errs[i].Error()})
+		case parsed[i].Unit != unit:
+			skipped = append(skipped, model.SkippedCell{Cell: cell, Reason: fmt.Sprintf("unit %s does not match %s", unitName(parsed[i].Unit), unitName(unit))})
+		default:
+			numbers = append(numbers, parsed[i].Value)
+		}
+	}
+	return numbers, unit, skipped
 }
 
-func (tp *TapasProcessor) Min() (float64, error) {
-	minValue := math.Inf(1)
-	found := false
-	for _, cell := range tp.Cells {
-		if num, err := strconv.ParseFloat(cell, 64); err == nil {
-			if !found || num < minValue {
-				minValue = num
-				found = true
-			}
+// aggregate applies a numeric aggregator to at least one number.
+func aggregate(aggregator string, numbers []float64) float64 {
+	switch aggregator {
+	case "MIN":
+		minValue := math.Inf(1)
+		for _, num := range numbers {
+			minValue = min(minValue, num)
 		}
+		return minValue
+	case "MAX":
+		maxValue := math.Inf(-1)
+		for _, num := range numbers {
+			maxValue = max(maxValue, num)
+		}
+		return maxValue
+	}
+
+	total := 0.0
+	for _, num := range numbers {
+		total += num
+	}
+	if aggregator == "AVERAGE" {
+		return total / float64(len(numbers))
+	}
+	return total
+}
+
+func unitName(unit string) string {
+	if unit == "" {
+		return "none"
 	}
-	if !found {
-		return 0, fmt.Errorf("no numeric values found")
+	return unit
+}
+
+// FormatNumber rounds value to two decimals and drops trailing zeros.
+func FormatNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// uniqueCells drops repeated cells, keeping the first occurrence of each.
+func uniqueCells(cells []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, cell := range cells {
+		if !seen[cell] {
+			seen[cell] = true
+			unique = append(unique, cell)
+		}
 	}
-	return minValue, nil
+	return unique
 }
@@ -0,0 +1,130 @@
+package utility_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+	"github.com/z4fL/fp-ai-golang-neurons/utility"
+)
+
+var _ = Describe("TapasProcessor", func() {
+	columns := []string{"Appliance", "Energy_Consumption (kWh)", "Room"}
+	energy := func(rows ...int) [][]int {
+		coordinates := make([][]int, 0, len(rows))
+		for _, row := range rows {
+			coordinates = append(coordinates, []int{row, 1})
+		}
+		return coordinates
+	}
+
+	DescribeTable("Aggregate",
+		func(aggregator string, cells []string, coordinates [][]int, value *float64, unit, text string) {
+			processor := utility.TapasProcessor{Cells: cells, Coordinates: coordinates, Columns: columns}
+
+			answer, err := processor.Aggregate(aggregator)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(answer.Aggregator).To(Equal(aggregator))
+			Expect(answer.Value).To(Equal(value))
+			Expect(answer.Unit).To(Equal(unit))
+			Expect(answer.Text).To(Equal(text))
+			Expect(answer.Cells).To(Equal(cells))
+			Expect(answer.Coordinates).To(Equal(coordinates))
+		},
+		Entry("NONE with one text cell", "NONE", []string{"Heater"}, [][]int{{2, 0}}, nil, "", "Heater"),
+		Entry("NONE with one numeric cell", "NONE", []string{"4.0"}, energy(1), nil, "kWh", "4.0 kWh"),
+		Entry("NONE with several cells", "NONE", []string{"Oven", "Fridge", "Oven"}, [][]int{{0, 0}, {1, 0}, {4, 0}}, nil, "", "Oven, Fridge"),
+		Entry("COUNT counts the distinct cells it lists", "COUNT", []string{"Oven", "Fridge", "Oven"}, [][]int{{0, 0}, {1, 0}, {4, 0}}, ptr(2.0), "", "Count: 2, List: Fridge, Oven"),
+		Entry("SUM", "SUM", []string{"1.5", "4.0", "0.8"}, energy(0, 1, 2), ptr(6.3), "kWh", "Sum: 6.3 kWh"),
+		Entry("SUM skips non-numeric cells", "SUM", []string{"1.5", "n/a", "2"}, energy(0, 1, 2), ptr(3.5), "kWh", "Sum: 3.5 kWh"),
+		Entry("AVERAGE", "AVERAGE", []string{"1", "2", "4"}, energy(0, 1, 2), ptr(7.0/3), "kWh", "Average: 2.33 kWh"),
+		Entry("MIN", "MIN", []string{"1.5", "0.8", "4.0"}, energy(0, 1, 2), ptr(0.8), "kWh", "Min: 0.8 kWh"),
+		Entry("MAX", "MAX", []string{"1.5", "0.8", "4.0"}, energy(0, 1, 2), ptr(4.0), "kWh", "Max: 4 kWh"),
+		Entry("MAX with negative values", "MAX", []string{"-3", "-1.25"}, energy(0, 1), ptr(-1.25), "kWh", "Max: -1.25 kWh"),
+		Entry("numbers padded with spaces", "SUM", []string{" 1 ", "2"}, energy(0, 1), ptr(3.0), "kWh", "Sum: 3 kWh"),
+		Entry("no unit when cells span columns", "MAX", []string{"1", "2"}, [][]int{{0, 1}, {0, 2}}, ptr(2.0), "", "Max: 2"),
+		Entry("no unit without coordinates", "MIN", []string{"1", "2"}, nil, ptr(1.0), "", "Min: 1"),
+		Entry("English thousands", "SUM", []string{"1,234.5", "0.5"}, energy(0, 1), ptr(1235.0), "kWh", "Sum: 1235 kWh"),
+		Entry("Indonesian decimal commas", "AVERAGE", []string{"3,5", "1,5"}, energy(0, 1), ptr(2.5), "kWh", "Average: 2.5 kWh"),
+		Entry("units in the cells", "SUM", []string{"12 kWh", "500 Wh"}, energy(0, 1), ptr(12.5), "kWh", "Sum: 12.5 kWh"),
+		Entry("percentages", "MAX", []string{"45%", "60 %"}, nil, ptr(60.0), "%", "Max: 60 %"),
+	)
+
+	It("should convert plain cells with the unit in the header", func() {
+		processor := utility.TapasProcessor{
+			Cells:       []string{"1500", "500"},
+			Coordinates: [][]int{{0, 0}, {1, 0}},
+			Columns:     []string{"Power (W)"},
+		}
+
+		answer, err := processor.Aggregate("SUM")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*answer.Value).To(Equal(2.0))
+		Expect(answer.Unit).To(Equal("kW"))
+	})
+
+	It("should honor a configured decimal mark", func() {
+		processor := utility.TapasProcessor{Cells: []string{"1.234", "1.000"}, DecimalMark: ','}
+
+		answer, err := processor.Aggregate("SUM")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*answer.Value).To(Equal(2234.0))
+	})
+
+	It("should report the cells it skipped and why", func() {
+		processor := utility.TapasProcessor{Cells: []string{"12 kWh", "n/a", "", "3 kW", "1.5 kWh"}}
+
+		answer, err := processor.Aggregate("SUM")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*answer.Value).To(Equal(13.5))
+		Expect(answer.Unit).To(Equal("kWh"))
+		Expect(answer.Skipped).To(Equal([]model.SkippedCell{
+			{Cell: "n/a", Reason: "not a number"},
+			{Cell: "", Reason: "empty cell"},
+			{Cell: "3 kW", Reason: "unit kW does not match kWh"},
+		}))
+	})
+
+	It("should report skipped cells when none could be used", func() {
+		processor := utility.TapasProcessor{Cells: []string{"TV"}}
+
+		answer, err := processor.Aggregate("MAX")
+		Expect(err).To(MatchError(utility.ErrNoNumericValues))
+		Expect(answer.Skipped).To(Equal([]model.SkippedCell{{Cell: "TV", Reason: "not a number"}}))
+	})
+
+	DescribeTable("Aggregate errors",
+		func(aggregator string, cells []string, expected error) {
+			processor := utility.TapasProcessor{Cells: cells}
+
+			_, err := processor.Aggregate(aggregator)
+			Expect(err).To(MatchError(expected))
+		},
+		Entry("no cells", "NONE", nil, utility.ErrNoCells),
+		Entry("SUM without numbers", "SUM", []string{"Heater"}, utility.ErrNoNumericValues),
+		Entry("AVERAGE without numbers", "AVERAGE", []string{"Heater"}, utility.ErrNoNumericValues),
+		Entry("MIN without numbers", "MIN", []string{"Heater"}, utility.ErrNoNumericValues),
+		Entry("MAX without numbers", "MAX", []string{"Heater"}, utility.ErrNoNumericValues),
+		Entry("unknown aggregator", "MEDIAN", []string{"1"}, utility.ErrUnknownAggregator),
+	)
+
+	DescribeTable("Unit",
+		func(column, unit string) {
+			processor := utility.TapasProcessor{Coordinates: [][]int{{0, 0}}, Columns: []string{column}}
+			Expect(processor.Unit()).To(Equal(unit))
+		},
+		Entry("parentheses", "Energy_Consumption (kWh)", "kWh"),
+		Entry("brackets", "Cost [USD]", "USD"),
+		Entry("no unit", "Appliance", ""),
+		Entry("parentheses not at the end", "Usage (kWh) per day", ""),
+	)
+
+	It("should format numbers without trailing zeros", func() {
+		Expect(utility.FormatNumber(2)).To(Equal("2"))
+		Expect(utility.FormatNumber(2.5)).To(Equal("2.5"))
+		Expect(utility.FormatNumber(2.456)).To(Equal("2.46"))
+	})
+})
+
+func ptr(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,37 @@
+package utility
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/z4fL/fp-ai-golang-neurons/model"
+)
+
+// The default tariff is PLN's R-1 household rate for 1,300 VA and above.
+const (
+	defaultTariffName     = "PLN R-1"
+	defaultTariffCurrency = "IDR"
+	defaultTariffRate     = 1444.70
+)
+
+// GetDefaultTariff reads the flat tariff used for users who haven't set
+// their own from the environment, falling back to the defaults above.
+func GetDefaultTariff() model.Tariff {
+	rate, err := strconv.ParseFloat(os.Getenv("TARIFF_RATE"), 64)
+	if err != nil || rate < 0 {
+		rate = defaultTariffRate
+	}
+
+	currency := strings.ToUpper(os.Getenv("TARIFF_CURRENCY"))
+	if currency == "" {
+		currency = defaultTariffCurrency
+	}
+
+	name := os.Getenv("TARIFF_NAME")
+	if name == "" {
+		name = defaultTariffName
+	}
+
+	return model.Tariff{Name: name, Currency: currency, Rate: rate}
+}